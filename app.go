@@ -10,8 +10,10 @@ import (
 	"image/png"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 
@@ -24,12 +26,17 @@ import (
 // MaxBatchSize limits the number of images that can be processed in one batch.
 const MaxBatchSize = 1000
 
+// sampleImageName stands in for the product's file name when overlays are
+// measured without one.
+const sampleImageName = "IMG_0001.jpg"
+
 // Event name constants.
 const (
-	EventProgress  = "progress"
-	EventComplete  = "complete"
-	EventError     = "error"
-	EventCancelled = "cancelled"
+	EventProgress   = "progress"
+	EventComplete   = "complete"
+	EventError      = "error"
+	EventCancelled  = "cancelled"
+	EventDataSource = "datasource"
 )
 
 // App struct holds the application state and services.
@@ -64,6 +71,23 @@ func NewApp(fonts embed.FS) *App {
 // startup is called when the app starts. The context is saved for runtime methods.
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+
+	// User fonts extend the glyph fallback chain after the embedded fonts
+	if dir, err := appConfigDir(); err == nil {
+		if _, err := a.fontManager.LoadFontDir(filepath.Join(dir, "fonts")); err != nil {
+			fmt.Printf("Warning: failed to load user fonts: %v\n", err)
+		}
+		a.templateSvc.SetProfilePath(filepath.Join(dir, "profile.json"))
+	}
+}
+
+// appConfigDir returns the per-user config directory for the app.
+func appConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config dir: %w", err)
+	}
+	return filepath.Join(dir, "vibe-imageborder"), nil
 }
 
 // validatePath validates and cleans a file path.
@@ -154,6 +178,20 @@ func (a *App) SelectTemplateFile() (string, error) {
 	return validatePath(file)
 }
 
+// SelectDataSourceFile opens file dialog for a CSV or XLSX data source.
+func (a *App) SelectDataSourceFile() (string, error) {
+	file, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Select Data Source",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Spreadsheets", Pattern: "*.csv;*.xlsx"},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return validatePath(file)
+}
+
 // SelectOutputFolder opens folder selection dialog.
 func (a *App) SelectOutputFolder() (string, error) {
 	folder, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
@@ -196,19 +234,131 @@ func (a *App) GetTemplateBackground(path string) (string, error) {
 	return a.templateSvc.GetBackground(path)
 }
 
+// UpdateOverlayPosition moves one overlay to x,y and saves the template.
+func (a *App) UpdateOverlayPosition(path, key string, x, y int) error {
+	if x < 0 || y < 0 {
+		return fmt.Errorf("invalid position: %d,%d", x, y)
+	}
+	return a.updateOverlay(path, key, func(o *models.TextOverlay) {
+		o.Position = fmt.Sprintf("%d,%d", x, y)
+	})
+}
+
+// UpdateOverlayFontSize changes one overlay's font size and saves the template.
+func (a *App) UpdateOverlayFontSize(path, key string, size int) error {
+	if size <= 0 {
+		return fmt.Errorf("invalid font size: %d", size)
+	}
+	return a.updateOverlay(path, key, func(o *models.TextOverlay) {
+		o.FontSize = size
+	})
+}
+
+// UpdateOverlayColor changes one overlay's color and saves the template.
+func (a *App) UpdateOverlayColor(path, key, color string) error {
+	if strings.TrimSpace(color) == "" {
+		return fmt.Errorf("color required")
+	}
+	if !imgservice.IsAutoColor(color) {
+		if _, err := imgservice.ParsePaint(color); err != nil {
+			return err
+		}
+	}
+	return a.updateOverlay(path, key, func(o *models.TextOverlay) {
+		o.Color = strings.TrimSpace(color)
+	})
+}
+
+// updateOverlay validates the template path and persists one overlay change.
+func (a *App) updateOverlay(path, key string, update func(*models.TextOverlay)) error {
+	cleanPath, err := validatePath(path)
+	if err != nil {
+		return err
+	}
+	if cleanPath == "" {
+		return fmt.Errorf("no template selected")
+	}
+	if err := a.templateSvc.UpdateOverlay(cleanPath, key, update); err != nil {
+		return fmt.Errorf("failed to update template: %w", err)
+	}
+	return nil
+}
+
+// GetShopProfile returns the shop profile fields used for [shop.*] placeholders.
+func (a *App) GetShopProfile() (map[string]string, error) {
+	profile, err := a.templateSvc.GetProfile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shop profile: %w", err)
+	}
+	return profile, nil
+}
+
+// SaveShopProfile replaces the shop profile fields, e.g. {"hotline": "0909 123 456"}.
+func (a *App) SaveShopProfile(profile map[string]string) error {
+	if err := a.templateSvc.SaveProfile(profile); err != nil {
+		return fmt.Errorf("failed to save shop profile: %w", err)
+	}
+	return nil
+}
+
+// MeasureOverlays returns where each overlay's text lands on a width x height frame.
+// Dynamic placeholders are filled from a sample image, [file.name] with
+// sampleImageName; overlays needing EXIF tags are returned unmeasured.
+func (a *App) MeasureOverlays(templatePath string, values map[string]string, width, height int) ([]models.OverlayBox, error) {
+	if templatePath == "" {
+		return []models.OverlayBox{}, nil
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid frame size: %dx%d", width, height)
+	}
+
+	overlays, err := a.templateSvc.GetOverlays(templatePath, values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template overlays: %w", err)
+	}
+
+	sample := template.ImageInfo{Path: sampleImageName, Index: 1, Total: 1, Width: width, Height: height, Time: time.Now()}
+	resolved := template.ResolveDynamic(overlays, sample)
+
+	boxes, err := a.textRenderer.Measure(resolved, width, height)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure overlays: %w", err)
+	}
+	var unmeasured []string
+	for key := range overlays {
+		if _, ok := resolved[key]; !ok {
+			unmeasured = append(unmeasured, key)
+		}
+	}
+	sort.Strings(unmeasured)
+	for _, key := range unmeasured {
+		boxes = append(boxes, models.OverlayBox{Key: key, Unmeasured: true})
+	}
+	return boxes, nil
+}
+
 // GeneratePreview creates preview of first image.
 func (a *App) GeneratePreview(req models.ProcessRequest) (string, error) {
+	result, err := a.GeneratePreviewResult(req)
+	if err != nil {
+		return "", err
+	}
+	return result.Image, nil
+}
+
+// GeneratePreviewResult creates preview of first image along with rendering warnings.
+func (a *App) GeneratePreviewResult(req models.ProcessRequest) (*models.PreviewResult, error) {
 	if len(req.ProductImages) == 0 {
-		return "", fmt.Errorf("no product images selected")
+		return nil, fmt.Errorf("no product images selected")
 	}
-	if req.FrameImage == "" {
-		return "", fmt.Errorf("no frame image selected")
+	if req.FrameImage == "" && len(req.Frames) == 0 {
+		return nil, fmt.Errorf("no frame image selected")
 	}
 
 	// Validate format
 	validFormats := map[string]bool{"png": true, "jpg": true, "jpeg": true, "webp": true, "": true}
 	if !validFormats[strings.ToLower(req.Format)] {
-		return "", fmt.Errorf("invalid format: %s", req.Format)
+		return nil, fmt.Errorf("invalid format: %s", req.Format)
 	}
 
 	// Validate quality
@@ -219,50 +369,73 @@ func (a *App) GeneratePreview(req models.ProcessRequest) (string, error) {
 	// Validate template path exists if provided
 	if req.TemplatePath != "" {
 		if _, err := os.Stat(req.TemplatePath); err != nil {
-			return "", fmt.Errorf("template file not found: %w", err)
+			return nil, fmt.Errorf("template file not found: %w", err)
 		}
 	}
 
-	// Load images
-	product, err := a.imageSvc.LoadImage(req.ProductImages[0])
+	// Load images; frames first, as a downscaled product must still cover them
+	frames, err := a.loadFrames(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to load product: %w", err)
+		return nil, err
 	}
-
-	frame, err := a.imageSvc.LoadImage(req.FrameImage)
+	limits, err := productLimits(req, frames)
+	if err != nil {
+		return nil, err
+	}
+	product, release, err := a.imageSvc.LoadImageContext(a.ctx, req.ProductImages[0], limits, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to load frame: %w", err)
+		return nil, fmt.Errorf("failed to load product: %w", err)
 	}
+	defer release()
 
-	// Get background and overlays with proper error handling
-	var bgColor string
-	var overlays map[string]models.TextOverlay
-
-	if req.TemplatePath != "" {
-		bgColor, err = a.templateSvc.GetBackground(req.TemplatePath)
-		if err != nil {
-			return "", fmt.Errorf("failed to get template background: %w", err)
-		}
+	// Preview shows the first variant, with the first image's own values
+	rows, err := loadDataSource(req, req.ProductImages[:1])
+	if err != nil {
+		return nil, err
+	}
+	variants, err := a.prepareVariants(req, rows)
+	if err != nil {
+		return nil, err
+	}
+	variant := variants[0]
+	overlays, values, err := a.imageOverlays(variant, req.ProductImages[0])
+	if err != nil {
+		return nil, err
+	}
 
-		overlays, err = a.templateSvc.GetOverlays(req.TemplatePath, req.FieldValues)
-		if err != nil {
-			return "", fmt.Errorf("failed to get template overlays: %w", err)
-		}
+	// Preview shows the first image of the batch in its first frame
+	info := template.ImageInfo{
+		Path:   req.ProductImages[0],
+		Index:  1,
+		Total:  len(req.ProductImages),
+		Width:  product.Bounds().Dx(),
+		Height: product.Bounds().Dy(),
+		Time:   time.Now(),
+		EXIF:   a.readEXIF(req.ProductImages[0]),
 	}
+	chosen := selectFrames(frames, req.FrameMode, info, values)
+	if len(chosen) == 0 {
+		return nil, fmt.Errorf("no frame fits %s", filepath.Base(req.ProductImages[0]))
+	}
+	overlays = template.ResolveDynamic(overlays, info)
 
 	// Composite
-	result, err := a.compositor.CompositeWithText(product, frame, bgColor, overlays, a.textRenderer)
+	result, err := a.compositor.CompositeWithText(product, chosen[0].image, variant.bgColor, overlays, a.textRenderer)
 	if err != nil {
-		return "", fmt.Errorf("failed to composite: %w", err)
+		return nil, fmt.Errorf("failed to composite: %w", err)
 	}
 
 	// Encode to base64 PNG
 	var buf bytes.Buffer
 	if err := png.Encode(&buf, result.Image); err != nil {
-		return "", fmt.Errorf("failed to encode: %w", err)
+		return nil, fmt.Errorf("failed to encode: %w", err)
 	}
 
-	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+	return &models.PreviewResult{
+		Image:      "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+		Warnings:   result.Warnings,
+		AutoColors: result.AutoColors,
+	}, nil
 }
 
 // ProcessBatch processes all images with progress events.
@@ -285,12 +458,21 @@ func (a *App) ProcessBatch(req models.ProcessRequest) error {
 	}
 
 	// Validate required fields
-	if req.FrameImage == "" {
+	if req.FrameImage == "" && len(req.Frames) == 0 {
 		return fmt.Errorf("frame image required")
 	}
 	if req.OutputDir == "" {
 		return fmt.Errorf("output directory required")
 	}
+	if req.Workers < 0 || req.Workers > MaxWorkers {
+		return fmt.Errorf("workers must be between 0 and %d", MaxWorkers)
+	}
+	if req.PixelBudget < 0 {
+		return fmt.Errorf("invalid pixel budget: %d", req.PixelBudget)
+	}
+	if _, err := imageLimits(req); err != nil {
+		return err
+	}
 
 	// Check if already processing
 	a.processingLock.Lock()
@@ -306,64 +488,82 @@ func (a *App) ProcessBatch(req models.ProcessRequest) error {
 	a.processingLock.Unlock()
 
 	defer func() {
+		cancel()
 		a.processingLock.Lock()
 		a.cancelFunc = nil
 		a.isProcessing = false
 		a.processingLock.Unlock()
 	}()
 
-	// Load frame once
-	frame, err := a.imageSvc.LoadImage(req.FrameImage)
+	// Load the data source once and report its misses before anything is
+	// loaded or drawn; a source requiring every image matched stops here
+	rows, err := loadDataSource(req, req.ProductImages)
+	if err == nil && rows != nil {
+		runtime.EventsEmit(a.ctx, EventDataSource, &rows.Report)
+		if req.DataSource.RequireMatch {
+			err = rows.CheckMatched()
+		}
+	}
 	if err != nil {
 		runtime.EventsEmit(a.ctx, EventError, map[string]string{"message": sanitizeError(err)})
 		return err
 	}
 
-	// Get template data with proper error handling
-	var bgColor string
-	var overlays map[string]models.TextOverlay
-	if req.TemplatePath != "" {
-		bgColor, err = a.templateSvc.GetBackground(req.TemplatePath)
-		if err != nil {
-			runtime.EventsEmit(a.ctx, EventError, map[string]string{"message": sanitizeError(err)})
-			return fmt.Errorf("failed to get template background: %w", err)
-		}
-		overlays, err = a.templateSvc.GetOverlays(req.TemplatePath, req.FieldValues)
-		if err != nil {
-			runtime.EventsEmit(a.ctx, EventError, map[string]string{"message": sanitizeError(err)})
-			return fmt.Errorf("failed to get template overlays: %w", err)
-		}
+	// Load frames once
+	frames, err := a.loadFrames(req)
+	if err != nil {
+		runtime.EventsEmit(a.ctx, EventError, map[string]string{"message": sanitizeError(err)})
+		return err
 	}
 
-	total := len(req.ProductImages)
-	var failures []string
+	// Get template data for every variant, all sharing the loaded rows
+	variants, err := a.prepareVariants(req, rows)
+	if err != nil {
+		runtime.EventsEmit(a.ctx, EventError, map[string]string{"message": sanitizeError(err)})
+		return err
+	}
 
-	for i, productPath := range req.ProductImages {
-		// Check for cancellation
-		select {
-		case <-ctx.Done():
-			runtime.EventsEmit(a.ctx, EventCancelled, nil)
-			return nil
-		default:
-		}
+	// Every frame of every variant of every product counts as one output
+	total := len(req.ProductImages) * len(variants) * framesPerOutput(frames, req.FrameMode)
+	current := 0
+	var failures []string
+	var warnings []string
+	autoColors := make(map[string]map[string]string)
 
-		// Process single image
-		err := a.processSingleImage(productPath, frame, bgColor, overlays, req)
+	// Workers wait for room in the app's pixel budget before decoding a
+	// product, and in a tighter one of the batch's own if it asks for one
+	var budget *imgservice.PixelBudget
+	if req.PixelBudget > 0 {
+		budget = imgservice.NewPixelBudget(req.PixelBudget)
+	}
+	usage := budget
+	if usage == nil {
+		usage = a.imageSvc.PixelBudget()
+	}
 
-		success := err == nil
-		if !success {
-			failures = append(failures, filepath.Base(productPath))
-			fmt.Printf("Error processing %s: %v\n", productPath, err)
+	a.runBatch(ctx, req, frames, variants, budget, func(output batchOutput) {
+		if !output.progress.Success {
+			failures = append(failures, output.label)
+		}
+		for _, w := range output.progress.Warnings {
+			warnings = append(warnings, output.label+": "+w)
+		}
+		if len(output.progress.AutoColors) > 0 {
+			autoColors[output.label] = output.progress.AutoColors
 		}
 
 		// Emit progress
-		progress := models.ProcessProgress{
-			Current: i + 1,
-			Total:   total,
-			File:    filepath.Base(productPath),
-			Success: success,
-		}
+		current++
+		progress := output.progress
+		progress.Current, progress.Total = current, total
+		progress.PixelsInUse, progress.PixelBudget = usage.Usage()
 		runtime.EventsEmit(a.ctx, EventProgress, progress)
+	})
+
+	// Workers have stopped; a cancelled batch ends without a summary
+	if ctx.Err() != nil {
+		runtime.EventsEmit(a.ctx, EventCancelled, nil)
+		return nil
 	}
 
 	// Emit completion with summary
@@ -372,33 +572,122 @@ func (a *App) ProcessBatch(req models.ProcessRequest) error {
 		"totalProcessed": total - len(failures),
 		"totalFailed":    len(failures),
 		"failures":       failures,
+		"warnings":       warnings,
+		"autoColors":     autoColors,
 	}
 	runtime.EventsEmit(a.ctx, EventComplete, result)
 	return nil
 }
 
+// PreviewDataSource matches the data source rows to the product images
+// without processing anything, so misses can be fixed before ProcessBatch.
+func (a *App) PreviewDataSource(req models.ProcessRequest) (*models.DataSourceReport, error) {
+	if req.DataSource == nil {
+		return nil, fmt.Errorf("no data source selected")
+	}
+	rows, err := loadDataSource(req, req.ProductImages)
+	if err != nil {
+		return nil, err
+	}
+	return &rows.Report, nil
+}
+
+// PreviewFilenameValues shows the field values each product file name yields
+// from the request's file name pattern, or the template's.
+func (a *App) PreviewFilenameValues(req models.ProcessRequest) ([]models.FilenameValues, error) {
+	pattern := req.FilenamePattern
+	if pattern == "" && req.TemplatePath != "" {
+		config, err := a.templateSvc.LoadTemplate(req.TemplatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load template: %w", err)
+		}
+		pattern = config.FilenamePattern
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("no filename pattern set")
+	}
+	re, err := template.CompileFilenamePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.FilenameValues, 0, len(req.ProductImages))
+	for _, path := range req.ProductImages {
+		values := template.FilenameValues(re, path)
+		matched := values != nil
+		if !matched {
+			values = map[string]string{}
+		}
+		results = append(results, models.FilenameValues{
+			File:    filepath.Base(path),
+			Matched: matched,
+			Values:  values,
+		})
+	}
+	return results, nil
+}
+
+// PreviewSidecarValues shows the sidecar JSON found beside each product
+// image and which of its values win under the request's precedence.
+func (a *App) PreviewSidecarValues(req models.ProcessRequest) ([]models.SidecarValues, error) {
+	var config *models.TemplateConfig
+	if req.TemplatePath != "" {
+		var err error
+		if config, err = a.templateSvc.LoadTemplate(req.TemplatePath); err != nil {
+			return nil, fmt.Errorf("failed to load template: %w", err)
+		}
+	}
+	rows, err := loadDataSource(req, req.ProductImages)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := loadImageValues(req, config, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.SidecarValues, 0, len(req.ProductImages))
+	for _, path := range req.ProductImages {
+		report, err := sources.sidecarReport(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		results = append(results, report)
+	}
+	return results, nil
+}
+
+// processSingleImage renders one variant of a loaded product in one frame and
+// saves it. Overlays are the variant's for this image; info carries the
+// product's size and EXIF for dynamic placeholders.
 func (a *App) processSingleImage(
+	product image.Image,
 	productPath string,
-	frame image.Image,
-	bgColor string,
+	frame *batchFrame,
+	variant *batchVariant,
 	overlays map[string]models.TextOverlay,
+	info template.ImageInfo,
 	req models.ProcessRequest,
-) error {
-	product, err := a.imageSvc.LoadImage(productPath)
-	if err != nil {
-		return err
-	}
+) (*imgservice.CompositeResult, error) {
+	// Fill [file.name], [batch.index] and the other per-image placeholders
+	overlays = template.ResolveDynamic(overlays, info)
 
-	result, err := a.compositor.CompositeWithText(product, frame, bgColor, overlays, a.textRenderer)
+	result, err := a.compositor.CompositeWithText(product, frame.image, variant.bgColor, overlays, a.textRenderer)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Generate output filename
 	baseName := filepath.Base(productPath)
 	ext := filepath.Ext(baseName)
 	nameWithoutExt := baseName[:len(baseName)-len(ext)]
-	outputName := nameWithoutExt + "_framed"
+	outputName := nameWithoutExt
+	for _, part := range []string{variant.name, frame.name} {
+		if part != "" {
+			outputName += "_" + part
+		}
+	}
+	outputName += "_framed"
 
 	// Determine output format
 	outputFormat := req.Format
@@ -406,7 +695,11 @@ func (a *App) processSingleImage(
 		outputFormat = "png"
 	}
 
-	// Generate unique output path to avoid collisions
+	// Generate unique output path to avoid collisions. The file is created
+	// exclusively, so parallel workers never pick the same name.
+	if err := os.MkdirAll(req.OutputDir, 0755); err != nil {
+		return result, fmt.Errorf("failed to create output dir: %w", err)
+	}
 	outputPath := filepath.Join(req.OutputDir, outputName)
 	counter := 0
 	for {
@@ -415,17 +708,67 @@ func (a *App) processSingleImage(
 			testPath = fmt.Sprintf("%s_%d", outputPath, counter)
 		}
 		fullPath := testPath + "." + outputFormat
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		file, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			file.Close()
 			outputPath = testPath
 			break
 		}
+		if !os.IsExist(err) {
+			return result, fmt.Errorf("failed to create output file: %w", err)
+		}
 		counter++
 		if counter > 1000 {
-			return fmt.Errorf("too many duplicate files for %s", baseName)
+			return result, fmt.Errorf("too many duplicate files for %s", baseName)
+		}
+	}
+
+	if req.Lossless && strings.EqualFold(req.Format, "webp") {
+		err = a.imageSvc.SaveWebP(result.Image, outputPath, imgservice.WebPOptions{Lossless: true})
+	} else {
+		err = a.imageSvc.SaveImage(result.Image, outputPath, req.Format, req.Quality)
+	}
+	if err != nil {
+		os.Remove(outputPath + "." + outputFormat)
+		return result, err
+	}
+	return result, nil
+}
+
+// imageLimits returns the largest product and frame size req accepts.
+func imageLimits(req models.ProcessRequest) (imgservice.Limits, error) {
+	for _, size := range []int{req.MaxImageWidth, req.MaxImageHeight} {
+		if size < 0 || size > imgservice.MaxImageLimit {
+			return imgservice.Limits{}, fmt.Errorf("image size limit must be between 0 and %d", imgservice.MaxImageLimit)
 		}
 	}
+	return imgservice.Limits{MaxWidth: req.MaxImageWidth, MaxHeight: req.MaxImageHeight}, nil
+}
 
-	return a.imageSvc.SaveImage(result.Image, outputPath, req.Format, req.Quality)
+// productLimits returns the limits for req's product images. With
+// DownscaleLarge, oversize baseline JPEGs are reduced to just above the
+// largest frame; other oversize images are still rejected.
+func productLimits(req models.ProcessRequest, frames []*batchFrame) (imgservice.Limits, error) {
+	limits, err := imageLimits(req)
+	if err != nil {
+		return limits, err
+	}
+	limits.Downscale = req.DownscaleLarge
+	for _, frame := range frames {
+		limits.TargetWidth = max(limits.TargetWidth, frame.image.Bounds().Dx())
+		limits.TargetHeight = max(limits.TargetHeight, frame.image.Bounds().Dy())
+	}
+	return limits, nil
+}
+
+// readEXIF returns the image's EXIF tags. Unreadable metadata only hides
+// overlays that use [exif.*] placeholders, so it is logged, not returned.
+func (a *App) readEXIF(path string) map[string]string {
+	tags, err := a.imageSvc.ReadEXIF(path)
+	if err != nil {
+		fmt.Printf("Warning: failed to read EXIF of %s: %v\n", filepath.Base(path), err)
+	}
+	return tags
 }
 
 // CancelProcessing cancels ongoing batch processing.
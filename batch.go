@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	goruntime "runtime"
+	"sync"
+	"time"
+
+	imgservice "vibe-imageborder/internal/image"
+	"vibe-imageborder/internal/models"
+	"vibe-imageborder/internal/template"
+)
+
+// batchMemoryBudget bounds the decoded images the workers of a batch hold
+// at once, which caps the worker count on machines with many CPUs.
+const batchMemoryBudget = 2 << 30
+
+// bytesPerPixel is the size of a decoded RGBA pixel.
+const bytesPerPixel = 4
+
+// MaxWorkers limits the workers a request can ask for.
+const MaxWorkers = 64
+
+// batchOutput is the outcome of one output of a batch.
+type batchOutput struct {
+	label    string                 // names the output in failures and warnings
+	progress models.ProcessProgress // Current and Total are set when emitted
+}
+
+// productOutputs are the outputs of the product at index in the batch.
+type productOutputs struct {
+	index   int
+	outputs []batchOutput
+}
+
+// batchWorkers returns how many products a batch renders at once: the
+// requested count, or one per CPU if 0, capped so that every worker can hold
+// a product of the largest size the request accepts plus a resized product and canvas the size of
+// the largest frame within batchMemoryBudget.
+func batchWorkers(requested, products int, limits imgservice.Limits, frames []*batchFrame) int {
+	workers := requested
+	if workers <= 0 {
+		workers = goruntime.NumCPU()
+	}
+
+	framePixels := 0
+	for _, frame := range frames {
+		framePixels = max(framePixels, frame.image.Bounds().Dx()*frame.image.Bounds().Dy())
+	}
+	width, height := imgservice.MaxImageWidth, imgservice.MaxImageHeight
+	if limits.MaxWidth > 0 {
+		width = limits.MaxWidth
+	}
+	if limits.MaxHeight > 0 {
+		height = limits.MaxHeight
+	}
+	productPixels := int64(width) * int64(height)
+	perWorker := int64(bytesPerPixel) * (productPixels + 2*int64(framePixels))
+	workers = min(workers, int(batchMemoryBudget/perWorker), products)
+	return max(workers, 1)
+}
+
+// runBatch renders the products of req on a pool of workers, holding no
+// more decoded product pixels than the image service's budget and budget,
+// if not nil, allow, and hands their outputs to emit
+// in product order, so progress counts up steadily whatever order the
+// workers finish in. It returns once every worker has stopped; after ctx is
+// cancelled, workers stop before their next output and nothing more is
+// emitted.
+func (a *App) runBatch(
+	ctx context.Context,
+	req models.ProcessRequest,
+	frames []*batchFrame,
+	variants []*batchVariant,
+	budget *imgservice.PixelBudget,
+	emit func(batchOutput),
+) {
+	limits, _ := productLimits(req, frames) // validated by ProcessBatch
+	workers := batchWorkers(req.Workers, len(req.ProductImages), limits, frames)
+	started := time.Now()
+
+	jobs := make(chan int)
+	results := make(chan productOutputs)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				outputs := a.renderProduct(ctx, req, i, started, limits, frames, variants, budget)
+				select {
+				case results <- productOutputs{index: i, outputs: outputs}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range req.ProductImages {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Hold outputs that finish early until those before them are emitted
+	pending := make(map[int][]batchOutput)
+	next := 0
+	for result := range results {
+		if ctx.Err() != nil {
+			continue
+		}
+		pending[result.index] = result.outputs
+		for outputs, ok := pending[next]; ok; outputs, ok = pending[next] {
+			delete(pending, next)
+			next++
+			for _, output := range outputs {
+				emit(output)
+			}
+		}
+	}
+}
+
+// renderProduct loads the product at index i once and renders every frame
+// of every variant of it. It returns early, with the outputs done so far,
+// when ctx is cancelled.
+func (a *App) renderProduct(
+	ctx context.Context,
+	req models.ProcessRequest,
+	i int,
+	started time.Time,
+	limits imgservice.Limits,
+	frames []*batchFrame,
+	variants []*batchVariant,
+	budget *imgservice.PixelBudget,
+) []batchOutput {
+	if ctx.Err() != nil {
+		return nil
+	}
+	productPath := req.ProductImages[i]
+	perVariant := framesPerOutput(frames, req.FrameMode)
+
+	// Load each product once for all its variants, once its pixels fit the budget
+	product, release, loadErr := a.imageSvc.LoadImageContext(ctx, productPath, limits, budget)
+	if loadErr == nil {
+		defer release()
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	info := template.ImageInfo{Path: productPath, Index: i + 1, Total: len(req.ProductImages), Time: started}
+	if loadErr == nil {
+		info.Width, info.Height = product.Bounds().Dx(), product.Bounds().Dy()
+		info.EXIF = a.readEXIF(productPath)
+	}
+
+	outputs := make([]batchOutput, 0, len(variants)*perVariant)
+	for _, variant := range variants {
+		// Pick the frames once the image's values are known; a nil
+		// frame fails its output
+		chosen := make([]*batchFrame, perVariant)
+		err := loadErr
+		var overlays map[string]models.TextOverlay
+		if err == nil {
+			var values map[string]string
+			overlays, values, err = a.imageOverlays(variant, productPath)
+			if err == nil {
+				copy(chosen, selectFrames(frames, req.FrameMode, info, values))
+				if chosen[0] == nil {
+					err = fmt.Errorf("no frame fits")
+				}
+			}
+		}
+
+		for _, frame := range chosen {
+			if ctx.Err() != nil {
+				return outputs
+			}
+			frameName, frameFile := "", ""
+			if frame != nil {
+				frameName, frameFile = frame.name, filepath.Base(frame.Path)
+			}
+			label := outputLabel(filepath.Base(productPath), variant.name, frameName)
+
+			// Process single image
+			outErr := err
+			var composite *imgservice.CompositeResult
+			if outErr == nil {
+				composite, outErr = a.processSingleImage(product, productPath, frame, variant, overlays, info, req)
+			}
+			if outErr != nil {
+				fmt.Printf("Error processing %s: %v\n", label, outErr)
+			}
+
+			output := batchOutput{label: label, progress: models.ProcessProgress{
+				File:    filepath.Base(productPath),
+				Variant: variant.name,
+				Frame:   frameFile,
+				Success: outErr == nil,
+			}}
+			if composite != nil {
+				output.progress.Warnings, output.progress.AutoColors = composite.Warnings, composite.AutoColors
+			}
+			outputs = append(outputs, output)
+		}
+	}
+	return outputs
+}
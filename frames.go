@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"vibe-imageborder/internal/models"
+	"vibe-imageborder/internal/template"
+)
+
+// squareTolerance is how far width / height may be from 1 for an image to
+// count as square, so 1000x1010 product shots still match "square" frames.
+const squareTolerance = 0.02
+
+// batchFrame is a frame of a batch, loaded once, with its rules.
+type batchFrame struct {
+	models.Frame
+	name  string // output file name part, empty unless every frame is used
+	image image.Image
+}
+
+// loadFrames validates the request's frames and loads each file once.
+// FrameImage, if set, comes last without rules, so it catches the images
+// no other frame fits.
+func (a *App) loadFrames(req models.ProcessRequest) ([]*batchFrame, error) {
+	switch req.FrameMode {
+	case "", models.FrameMatch, models.FrameAll:
+	default:
+		return nil, fmt.Errorf("unknown frame mode: %s", req.FrameMode)
+	}
+
+	rules := append([]models.Frame(nil), req.Frames...)
+	if req.FrameImage != "" {
+		rules = append(rules, models.Frame{Path: req.FrameImage})
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("frame image required")
+	}
+
+	limits, err := imageLimits(req)
+	if err != nil {
+		return nil, err
+	}
+	named := req.FrameMode == models.FrameAll && len(rules) > 1
+	loaded := make(map[string]image.Image, len(rules))
+	frames := make([]*batchFrame, 0, len(rules))
+	for _, rule := range rules {
+		if err := validateFrame(rule); err != nil {
+			return nil, err
+		}
+		img, ok := loaded[rule.Path]
+		if !ok {
+			if img, err = a.imageSvc.LoadImageLimits(rule.Path, limits); err != nil {
+				return nil, fmt.Errorf("failed to load frame %s: %w", filepath.Base(rule.Path), err)
+			}
+			loaded[rule.Path] = img
+		}
+
+		frame := &batchFrame{Frame: rule, image: img}
+		if named {
+			frame.name = rule.Name
+			if frame.name == "" {
+				frame.name = strings.TrimSuffix(filepath.Base(rule.Path), filepath.Ext(rule.Path))
+			}
+			frame.name = safeFileName(frame.name)
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// validateFrame checks one frame's rules.
+func validateFrame(frame models.Frame) error {
+	if frame.Path == "" {
+		return fmt.Errorf("frame path required")
+	}
+	name := filepath.Base(frame.Path)
+	switch frame.Orientation {
+	case "", models.OrientationPortrait, models.OrientationLandscape, models.OrientationSquare:
+	default:
+		return fmt.Errorf("frame %s: unknown orientation: %s", name, frame.Orientation)
+	}
+	if frame.MinAspect < 0 || frame.MaxAspect < 0 || (frame.MaxAspect > 0 && frame.MinAspect > frame.MaxAspect) {
+		return fmt.Errorf("frame %s: invalid aspect range %g-%g", name, frame.MinAspect, frame.MaxAspect)
+	}
+	if frame.Value != "" && frameField(frame.Field) == "" {
+		return fmt.Errorf("frame %s: value %q needs a field", name, frame.Value)
+	}
+	return nil
+}
+
+// frameField accepts a rule's field as "category" or "[category]".
+func frameField(field string) string {
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(field), "[]"))
+}
+
+// orientation classifies a width / height ratio.
+func orientation(aspect float64) string {
+	switch {
+	case math.Abs(aspect-1) <= squareTolerance:
+		return models.OrientationSquare
+	case aspect > 1:
+		return models.OrientationLandscape
+	default:
+		return models.OrientationPortrait
+	}
+}
+
+// fits reports whether a product of width x height with the given field
+// values meets the frame's rules.
+func (f *batchFrame) fits(width, height int, values map[string]string) bool {
+	if f.Orientation != "" || f.MinAspect > 0 || f.MaxAspect > 0 {
+		if width <= 0 || height <= 0 {
+			return false
+		}
+		aspect := float64(width) / float64(height)
+		if f.Orientation != "" && orientation(aspect) != f.Orientation {
+			return false
+		}
+		if (f.MinAspect > 0 && aspect < f.MinAspect) || (f.MaxAspect > 0 && aspect > f.MaxAspect) {
+			return false
+		}
+	}
+
+	field := frameField(f.Field)
+	if field == "" {
+		return true
+	}
+	value := strings.TrimSpace(values[field])
+	for _, want := range strings.Split(f.Value, "|") {
+		if strings.EqualFold(value, strings.TrimSpace(want)) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectFrames returns the frames one product is rendered with: every frame
+// in "all" mode, else the first whose rules it meets, or nil if none does.
+// Rules see the image's field values and its [file.*], [image.*] and [exif.*]
+// placeholders.
+func selectFrames(frames []*batchFrame, mode string, info template.ImageInfo, values map[string]string) []*batchFrame {
+	if mode == models.FrameAll {
+		return frames
+	}
+	values = template.MergeValues(info.Values(), values)
+	for _, frame := range frames {
+		if frame.fits(info.Width, info.Height, values) {
+			return []*batchFrame{frame}
+		}
+	}
+	return nil
+}
+
+// framesPerOutput is how many frames each product variant is rendered with.
+func framesPerOutput(frames []*batchFrame, mode string) int {
+	if mode == models.FrameAll {
+		return len(frames)
+	}
+	return 1
+}
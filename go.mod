@@ -4,16 +4,16 @@ go 1.24.0
 
 require (
 	github.com/disintegration/imaging v1.6.2
-	github.com/fogleman/gg v1.3.0
+	github.com/go-text/typesetting v0.3.0
 	github.com/wailsapp/wails/v2 v2.11.0
 	golang.org/x/image v0.34.0
+	golang.org/x/text v0.32.0
 )
 
 require (
 	github.com/bep/debounce v1.2.1 // indirect
 	github.com/go-ole/go-ole v1.3.0 // indirect
 	github.com/godbus/dbus/v5 v5.1.0 // indirect
-	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/jchv/go-winloader v0.0.0-20210711035445-715c2860da7e // indirect
@@ -37,7 +37,6 @@ require (
 	golang.org/x/crypto v0.33.0 // indirect
 	golang.org/x/net v0.35.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
-	golang.org/x/text v0.32.0 // indirect
 )
 
 // replace github.com/wailsapp/wails/v2 v2.11.0 => C:\Users\thuan\go\pkg\mod
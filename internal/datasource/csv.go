@@ -0,0 +1,70 @@
+// Package datasource provides CSV reading.
+package datasource
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// utf8BOM starts CSV files saved by Excel as "CSV UTF-8".
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ReadCSV reads a CSV file whose first record is the header.
+// Excel's byte order mark is skipped, and the delimiter is a semicolon
+// instead of a comma when the header has more of them, as Excel writes
+// for locales that use the decimal comma.
+func ReadCSV(path string) (*Table, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data source: %w", err)
+	}
+	data = bytes.TrimPrefix(data, utf8BOM)
+
+	firstLine, _, _ := bufio.NewReader(bytes.NewReader(data)).ReadLine()
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	if strings.Count(string(firstLine), ";") > strings.Count(string(firstLine), ",") {
+		reader.Comma = ';'
+	}
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("data source is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	table := &Table{Header: header}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if isBlank(record) {
+			continue
+		}
+		table.Rows = append(table.Rows, Row{Line: line, Values: record})
+	}
+	return table, nil
+}
+
+// isBlank reports whether every cell of a record is empty.
+func isBlank(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
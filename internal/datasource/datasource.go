@@ -0,0 +1,185 @@
+// Package datasource provides per-product field values read from CSV and XLSX sheets.
+package datasource
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"vibe-imageborder/internal/models"
+)
+
+// Table is a sheet read from a data source. The header row names the fields.
+type Table struct {
+	Header []string
+	Rows   []Row
+}
+
+// Row is one data row of a table.
+type Row struct {
+	Line   int // 1-based row number in the sheet, the header being row 1
+	Values []string
+}
+
+// Result holds the field values matched to each image and the match report.
+type Result struct {
+	Values map[string]map[string]string // image path -> field values
+	Report models.DataSourceReport
+}
+
+// maxListed is how many unmatched images CheckMatched names.
+const maxListed = 5
+
+// CheckMatched returns an error naming the images no row matched, if any.
+func (r *Result) CheckMatched() error {
+	unmatched := r.Report.UnmatchedImages
+	if len(unmatched) == 0 {
+		return nil
+	}
+	listed := strings.Join(unmatched[:min(len(unmatched), maxListed)], ", ")
+	if len(unmatched) > maxListed {
+		listed += fmt.Sprintf(" and %d more", len(unmatched)-maxListed)
+	}
+	return fmt.Errorf("%d image(s) have no data source row: %s", len(unmatched), listed)
+}
+
+// defaultColumns is the match column used for each mode when none is given.
+var defaultColumns = map[string]string{
+	models.MatchFilename: "file",
+	models.MatchSKU:      "sku",
+	models.MatchGlob:     "pattern",
+}
+
+// Read loads a .csv or .xlsx file. Sheet selects an xlsx sheet by name;
+// the first sheet is used if it is empty.
+func Read(path, sheet string) (*Table, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ReadCSV(path)
+	case ".xlsx":
+		return ReadXLSX(path, sheet)
+	}
+	return nil, fmt.Errorf("unsupported data source: %s", filepath.Base(path))
+}
+
+// Load reads source and matches its rows to images.
+func Load(source models.DataSource, images []string) (*Result, error) {
+	table, err := Read(source.Path, source.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	return Match(table, images, source.MatchBy, source.Column)
+}
+
+// Match pairs table rows with images. Rows are tried in order and the first
+// row matching an image gives its values; empty cells are left out so the
+// shared field values show through. File names and SKUs match regardless
+// of case.
+func Match(table *Table, images []string, matchBy, column string) (*Result, error) {
+	if matchBy == "" {
+		matchBy = models.MatchFilename
+	}
+	matchBy = strings.ToLower(matchBy)
+	if _, ok := defaultColumns[matchBy]; !ok {
+		return nil, fmt.Errorf("unknown match mode: %s", matchBy)
+	}
+	if column == "" {
+		column = defaultColumns[matchBy]
+	}
+	col := table.column(column)
+	if col < 0 {
+		return nil, fmt.Errorf("column %q not found", column)
+	}
+
+	result := &Result{
+		Values: make(map[string]map[string]string),
+		Report: models.DataSourceReport{
+			Rows:            len(table.Rows),
+			UnmatchedImages: []string{},
+			UnmatchedRows:   []string{},
+		},
+	}
+	rowUsed := make([]bool, len(table.Rows))
+	for _, image := range images {
+		name := filepath.Base(image)
+		matched := false
+		for i, row := range table.Rows {
+			key := strings.TrimSpace(row.cell(col))
+			if key == "" {
+				continue
+			}
+			ok, err := matches(matchBy, key, name)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", row.Line, err)
+			}
+			if ok {
+				result.Values[image] = table.values(row)
+				rowUsed[i], matched = true, true
+				break
+			}
+		}
+		if matched {
+			result.Report.MatchedImages++
+		} else {
+			result.Report.UnmatchedImages = append(result.Report.UnmatchedImages, name)
+		}
+	}
+
+	for i, row := range table.Rows {
+		if !rowUsed[i] {
+			result.Report.UnmatchedRows = append(result.Report.UnmatchedRows,
+				fmt.Sprintf("row %d (%s)", row.Line, strings.TrimSpace(row.cell(col))))
+		}
+	}
+	return result, nil
+}
+
+// matches reports whether a row key selects the image file name.
+func matches(matchBy, key, name string) (bool, error) {
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	switch matchBy {
+	case models.MatchSKU:
+		// "SKU123" matches SKU123.jpg, SKU123_front.jpg and SKU123-2.png
+		if len(stem) < len(key) || !strings.EqualFold(stem[:len(key)], key) {
+			return false, nil
+		}
+		return len(stem) == len(key) || strings.ContainsRune("_- .", rune(stem[len(key)])), nil
+	case models.MatchGlob:
+		ok, err := filepath.Match(strings.ToLower(key), strings.ToLower(name))
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", key, err)
+		}
+		return ok, nil
+	}
+	return strings.EqualFold(key, name) || strings.EqualFold(key, stem), nil
+}
+
+// column returns the index of the header named name, ignoring case, or -1.
+func (t *Table) column(name string) int {
+	for i, header := range t.Header {
+		if strings.EqualFold(strings.TrimSpace(header), strings.TrimSpace(name)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// values returns a row's non-empty cells keyed by header.
+func (t *Table) values(row Row) map[string]string {
+	values := make(map[string]string, len(t.Header))
+	for i, header := range t.Header {
+		header = strings.TrimSpace(header)
+		if value := strings.TrimSpace(row.cell(i)); header != "" && value != "" {
+			values[header] = value
+		}
+	}
+	return values
+}
+
+// cell returns the i-th value of the row, or "" past its end.
+func (r Row) cell(i int) string {
+	if i < len(r.Values) {
+		return r.Values[i]
+	}
+	return ""
+}
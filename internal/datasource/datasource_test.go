@@ -0,0 +1,140 @@
+package datasource
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vibe-imageborder/internal/models"
+)
+
+func writeCSV(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "products.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	return path
+}
+
+func TestReadCSV(t *testing.T) {
+	path := writeCSV(t, "\xEF\xBB\xBFfile;barcode;price\nghe-go.jpg;893456;1.200\n;;\n\"ban, tron.png\";893457;\"2,5\"\n")
+	table, err := ReadCSV(path)
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+	if len(table.Header) != 3 || table.Header[0] != "file" {
+		t.Fatalf("Expected BOM stripped and semicolon header, got %q", table.Header)
+	}
+	if len(table.Rows) != 2 {
+		t.Fatalf("Expected blank row skipped, got %d rows", len(table.Rows))
+	}
+	if row := table.Rows[1]; row.Line != 4 || row.Values[0] != "ban, tron.png" || row.Values[2] != "2,5" {
+		t.Errorf("Unexpected row %+v", row)
+	}
+}
+
+func TestMatchFilename(t *testing.T) {
+	table := &Table{
+		Header: []string{"File", "barcode", "price"},
+		Rows: []Row{
+			{Line: 2, Values: []string{"ghe-go.jpg", "893456", "1.200"}},
+			{Line: 3, Values: []string{"BAN-TRON", "893457"}},
+			{Line: 4, Values: []string{"tu-ao.jpg", "893458", "900"}},
+		},
+	}
+	images := []string{
+		filepath.Join("in", "ghe-go.jpg"),
+		filepath.Join("in", "ban-tron.png"),
+		filepath.Join("in", "den.jpg"),
+	}
+	result, err := Match(table, images, "", "")
+	if err != nil {
+		t.Fatalf("Match failed: %v", err)
+	}
+
+	if got := result.Values[images[0]]; got["barcode"] != "893456" || got["price"] != "1.200" || got["File"] != "ghe-go.jpg" {
+		t.Errorf("Unexpected values %v", got)
+	}
+	// Matched by stem regardless of case; the empty price is left out
+	got := result.Values[images[1]]
+	if got["barcode"] != "893457" {
+		t.Errorf("Expected stem match, got %v", got)
+	}
+	if _, ok := got["price"]; ok {
+		t.Error("Expected empty cell to be left out")
+	}
+
+	report := result.Report
+	if report.Rows != 3 || report.MatchedImages != 2 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if len(report.UnmatchedImages) != 1 || report.UnmatchedImages[0] != "den.jpg" {
+		t.Errorf("Expected den.jpg unmatched, got %v", report.UnmatchedImages)
+	}
+	if len(report.UnmatchedRows) != 1 || report.UnmatchedRows[0] != "row 4 (tu-ao.jpg)" {
+		t.Errorf("Expected row 4 unmatched, got %v", report.UnmatchedRows)
+	}
+
+	if err := result.CheckMatched(); err == nil || !strings.Contains(err.Error(), "1 image(s) have no data source row: den.jpg") {
+		t.Errorf("Expected den.jpg named, got %v", err)
+	}
+	if err := (&Result{}).CheckMatched(); err != nil {
+		t.Errorf("Expected no error when every image matched, got %v", err)
+	}
+}
+
+func TestMatchSKUAndGlob(t *testing.T) {
+	images := []string{"SKU12_front.jpg", "sku12-2.png", "SKU123.jpg", "ban-go.jpg"}
+
+	skus := &Table{
+		Header: []string{"sku", "price"},
+		Rows:   []Row{{Line: 2, Values: []string{"SKU12", "100"}}, {Line: 3, Values: []string{"SKU123", "200"}}},
+	}
+	result, err := Match(skus, images, models.MatchSKU, "")
+	if err != nil {
+		t.Fatalf("Match failed: %v", err)
+	}
+	for image, price := range map[string]string{"SKU12_front.jpg": "100", "sku12-2.png": "100", "SKU123.jpg": "200"} {
+		if got := result.Values[image]["price"]; got != price {
+			t.Errorf("%s: expected price %s, got %q", image, price, got)
+		}
+	}
+
+	// The first matching row wins
+	globs := &Table{
+		Header: []string{"pattern", "group"},
+		Rows:   []Row{{Line: 2, Values: []string{"sku12*", "a"}}, {Line: 3, Values: []string{"*.jpg", "b"}}},
+	}
+	result, err = Match(globs, images, models.MatchGlob, "")
+	if err != nil {
+		t.Fatalf("Match failed: %v", err)
+	}
+	expected := map[string]string{"SKU12_front.jpg": "a", "sku12-2.png": "a", "SKU123.jpg": "a", "ban-go.jpg": "b"}
+	for image, group := range expected {
+		if got := result.Values[image]["group"]; got != group {
+			t.Errorf("%s: expected group %s, got %q", image, group, got)
+		}
+	}
+
+	if _, err := Match(globs, images, models.MatchGlob, "missing"); err == nil {
+		t.Error("Expected error for unknown column")
+	}
+	if _, err := Match(globs, images, "regex", "pattern"); err == nil {
+		t.Error("Expected error for unknown match mode")
+	}
+}
+
+func TestRead(t *testing.T) {
+	if _, err := Read("products.ods", ""); err == nil {
+		t.Error("Expected error for unsupported file type")
+	}
+	table, err := Read(writeCSV(t, "file,price\na.jpg,10\n"), "")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(table.Rows) != 1 || table.Rows[0].Values[1] != "10" {
+		t.Errorf("Unexpected table %+v", table)
+	}
+}
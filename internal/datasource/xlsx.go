@@ -0,0 +1,248 @@
+// Package datasource provides XLSX reading without third-party libraries.
+package datasource
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxXLSXPart bounds the uncompressed size of one workbook part.
+const maxXLSXPart = 64 << 20
+
+// xlsxWorkbook is xl/workbook.xml.
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// xlsxRels is xl/_rels/workbook.xml.rels.
+type xlsxRels struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is a shared or inline string: plain text or rich text runs.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+// xlsxSharedStrings is xl/sharedStrings.xml.
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+// xlsxSheet is a worksheet; only cell values are read.
+type xlsxSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadXLSX reads the named sheet of an .xlsx workbook, or the first sheet if
+// name is empty. The first non-empty row is the header. Cells hold their
+// displayed text for strings and their stored value for numbers; dates come
+// through as Excel serial numbers, so store them as text in the sheet.
+func ReadXLSX(filePath, name string) (*Table, error) {
+	archive, err := zip.OpenReader(filepath.Clean(filePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workbook: %w", err)
+	}
+	defer archive.Close()
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := findSheet(files, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared xlsxSharedStrings
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodePart(f, &shared); err != nil {
+			return nil, fmt.Errorf("invalid shared strings: %w", err)
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("sheet part %s missing", sheetPath)
+	}
+	var sheet xlsxSheet
+	if err := decodePart(f, &sheet); err != nil {
+		return nil, fmt.Errorf("invalid sheet: %w", err)
+	}
+
+	table := &Table{}
+	for i, row := range sheet.Rows {
+		line := row.R
+		if line == 0 {
+			line = i + 1
+		}
+		var values []string
+		for j, c := range row.Cells {
+			col := j
+			if c.Ref != "" {
+				if col, err = columnIndex(c.Ref); err != nil {
+					return nil, fmt.Errorf("row %d: %w", line, err)
+				}
+			}
+			value, err := cellText(c.Type, c.Value, c.Inline, shared.Items)
+			if err != nil {
+				return nil, fmt.Errorf("cell %s: %w", c.Ref, err)
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+			values[col] = value
+		}
+		if isBlank(values) {
+			continue
+		}
+		if table.Header == nil {
+			table.Header = values
+			continue
+		}
+		table.Rows = append(table.Rows, Row{Line: line, Values: values})
+	}
+	if table.Header == nil {
+		return nil, fmt.Errorf("data source is empty")
+	}
+	return table, nil
+}
+
+// findSheet returns the zip path of the named sheet, or the first one.
+func findSheet(files map[string]*zip.File, name string) (string, error) {
+	f, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", fmt.Errorf("not an xlsx workbook")
+	}
+	var workbook xlsxWorkbook
+	if err := decodePart(f, &workbook); err != nil {
+		return "", fmt.Errorf("invalid workbook: %w", err)
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("workbook has no sheets")
+	}
+
+	id := workbook.Sheets[0].ID
+	if name != "" {
+		id = ""
+		for _, sheet := range workbook.Sheets {
+			if strings.EqualFold(sheet.Name, name) {
+				id = sheet.ID
+				break
+			}
+		}
+		if id == "" {
+			return "", fmt.Errorf("sheet %q not found", name)
+		}
+	}
+
+	var rels xlsxRels
+	if f, ok := files["xl/_rels/workbook.xml.rels"]; ok {
+		if err := decodePart(f, &rels); err != nil {
+			return "", fmt.Errorf("invalid workbook relationships: %w", err)
+		}
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != id {
+			continue
+		}
+		// Targets are relative to xl/ unless absolute within the package
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", fmt.Errorf("sheet relationship %s not found", id)
+}
+
+// decodePart unmarshals one XML part of the workbook.
+func decodePart(f *zip.File, v interface{}) error {
+	if f.UncompressedSize64 > maxXLSXPart {
+		return fmt.Errorf("%s too large", f.Name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(io.LimitReader(rc, maxXLSXPart)).Decode(v)
+}
+
+// cellText returns the text of a cell of the given type.
+func cellText(typ, value string, inline xlsxText, shared []xlsxText) (string, error) {
+	switch typ {
+	case "s":
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 || i >= len(shared) {
+			return "", fmt.Errorf("invalid shared string index %q", value)
+		}
+		return shared[i].String(), nil
+	case "inlineStr":
+		return inline.String(), nil
+	case "b":
+		if value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case "", "n":
+		// Long numbers such as barcodes may be stored as 8.934567890123E+12
+		if strings.ContainsAny(value, "eE") {
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				return strconv.FormatFloat(f, 'f', -1, 64), nil
+			}
+		}
+	}
+	return value, nil
+}
+
+// columnIndex returns the 0-based column of a cell reference such as "AB12".
+func columnIndex(ref string) (int, error) {
+	col := 0
+	n := 0
+	for _, r := range strings.ToUpper(ref) {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+	}
+	if n == 0 || n > 3 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return col - 1, nil
+}
@@ -0,0 +1,112 @@
+package datasource
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeXLSX writes a minimal workbook with the given parts.
+func writeXLSX(t *testing.T, parts map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "products.xlsx")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create workbook: %v", err)
+	}
+	defer file.Close()
+
+	w := zip.NewWriter(file)
+	for name, content := range parts {
+		part, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		part.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to write workbook: %v", err)
+	}
+	return path
+}
+
+const testWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Notes" sheetId="1" r:id="rId1"/><sheet name="Giá" sheetId="2" r:id="rId2"/></sheets>
+</workbook>`
+
+const testRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`
+
+const testSharedStrings = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="4" uniqueCount="4">
+<si><t>file</t></si>
+<si><t>price</t></si>
+<si><r><t>Ghế </t></r><r><rPr><b/></rPr><t>gỗ</t></r></si>
+<si><t>ghe-go.jpg</t></si>
+</sst>`
+
+const testSheet2 = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>name</t></is></c><c r="D1" t="inlineStr"><is><t>barcode</t></is></c></row>
+<row r="3"><c r="A3" t="s"><v>3</v></c><c r="B3"><v>1200</v></c><c r="C3" t="s"><v>2</v></c><c r="D3"><v>8.934567890123E+12</v></c></row>
+<row r="4"><c r="A4" t="str"><v>ban.png</v></c><c r="D4" t="b"><v>1</v></c></row>
+</sheetData>
+</worksheet>`
+
+func TestReadXLSX(t *testing.T) {
+	path := writeXLSX(t, map[string]string{
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testRels,
+		"xl/sharedStrings.xml":       testSharedStrings,
+		"xl/worksheets/sheet1.xml":   `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData/></worksheet>`,
+		"xl/worksheets/sheet2.xml":   testSheet2,
+	})
+
+	table, err := ReadXLSX(path, "giá")
+	if err != nil {
+		t.Fatalf("ReadXLSX failed: %v", err)
+	}
+	expectedHeader := []string{"file", "price", "name", "barcode"}
+	for i, h := range expectedHeader {
+		if i >= len(table.Header) || table.Header[i] != h {
+			t.Fatalf("Expected header %v, got %v", expectedHeader, table.Header)
+		}
+	}
+	if len(table.Rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(table.Rows))
+	}
+	row := table.Rows[0]
+	if row.Line != 3 || row.Values[0] != "ghe-go.jpg" || row.Values[1] != "1200" || row.Values[2] != "Ghế gỗ" || row.Values[3] != "8934567890123" {
+		t.Errorf("Unexpected row %+v", row)
+	}
+	// Missing cells are empty; columns follow the cell references
+	if row := table.Rows[1]; row.Values[0] != "ban.png" || row.Values[1] != "" || row.Values[3] != "TRUE" {
+		t.Errorf("Unexpected row %+v", row)
+	}
+
+	// The first sheet is empty
+	if _, err := ReadXLSX(path, ""); err == nil {
+		t.Error("Expected error for empty first sheet")
+	}
+	if _, err := ReadXLSX(path, "Missing"); err == nil {
+		t.Error("Expected error for unknown sheet")
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := map[string]int{"A1": 0, "Z9": 25, "AA10": 26, "AB2": 27}
+	for ref, expected := range tests {
+		if got, err := columnIndex(ref); err != nil || got != expected {
+			t.Errorf("%s: expected %d, got %d (%v)", ref, expected, got, err)
+		}
+	}
+	if _, err := columnIndex("12"); err == nil {
+		t.Error("Expected error for reference without column")
+	}
+}
@@ -0,0 +1,120 @@
+// Package image provides a pixel budget bounding the decoded images held at once.
+package image
+
+import (
+	"context"
+	"image"
+	"sync"
+)
+
+// DefaultPixelBudget allows four images of the maximum size, about 1 GiB
+// of decoded RGBA pixels.
+const DefaultPixelBudget = 4 * MaxImageWidth * MaxImageHeight
+
+// PixelBudget is a semaphore counted in pixels bounding the decoded images
+// held at once. Each Service owns one shared by all its loads; a batch may
+// add a tighter one of its own. An image larger than the whole budget is let
+// through once nothing else holds pixels, so it waits rather than failing.
+type PixelBudget struct {
+	mu      sync.Mutex
+	limit   int64
+	inUse   int64
+	changed chan struct{} // closed and replaced whenever inUse drops
+}
+
+// NewPixelBudget returns a budget of limit pixels; 0 or less gives
+// DefaultPixelBudget.
+func NewPixelBudget(limit int64) *PixelBudget {
+	if limit <= 0 {
+		limit = DefaultPixelBudget
+	}
+	return &PixelBudget{limit: limit, changed: make(chan struct{})}
+}
+
+// acquire blocks until n pixels fit the budget or ctx is done.
+func (b *PixelBudget) acquire(ctx context.Context, n int64) error {
+	for {
+		b.mu.Lock()
+		if b.inUse == 0 || b.inUse+n <= b.limit {
+			b.inUse += n
+			b.mu.Unlock()
+			return nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release returns n pixels and wakes the loads waiting for them.
+func (b *PixelBudget) release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inUse -= n
+	b.broadcast()
+}
+
+// broadcast wakes every waiter. Callers hold mu.
+func (b *PixelBudget) broadcast() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Usage returns the pixels in use and the limit.
+func (b *PixelBudget) Usage() (inUse, limit int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inUse, b.limit
+}
+
+// PixelBudget returns the budget shared by every LoadImageContext call.
+func (s *Service) PixelBudget() *PixelBudget {
+	return s.budget
+}
+
+// LoadImageContext loads an image like LoadImageLimits, but first waits
+// until its pixels, after any downscaling, fit batch, if not nil, and then
+// the service's own budget. The caller must call release once done with the
+// image. Waiting ends early with ctx's error.
+func (s *Service) LoadImageContext(ctx context.Context, path string, limits Limits, batch *PixelBudget) (img image.Image, release func(), err error) {
+	file, header, err := openImage(path, limits)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	// Budgets are always taken batch first, so two loads can't deadlock
+	pixels := int64(header.width) * int64(header.height)
+	budgets := []*PixelBudget{s.budget}
+	if batch != nil {
+		budgets = []*PixelBudget{batch, s.budget}
+	}
+	for i, budget := range budgets {
+		if err := budget.acquire(ctx, pixels); err != nil {
+			for _, held := range budgets[:i] {
+				held.release(pixels)
+			}
+			return nil, nil, err
+		}
+	}
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			for _, budget := range budgets {
+				budget.release(pixels)
+			}
+		})
+	}
+
+	img, err = decodeImage(file, header, limits)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return img, release, nil
+}
@@ -0,0 +1,131 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"image"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPixelBudgetBlocksUntilRelease(t *testing.T) {
+	budget := NewPixelBudget(100)
+	ctx := context.Background()
+	if err := budget.acquire(ctx, 60); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		budget.acquire(ctx, 60)
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Expected second acquire to wait for the budget")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	budget.release(60)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected second acquire after release")
+	}
+	if inUse, limit := budget.Usage(); inUse != 60 || limit != 100 {
+		t.Errorf("Expected 60/100 in use, got %d/%d", inUse, limit)
+	}
+}
+
+func TestPixelBudgetOversizeImage(t *testing.T) {
+	// An image over the whole budget still loads when nothing else does
+	budget := NewPixelBudget(100)
+	if err := budget.acquire(context.Background(), 500); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := budget.acquire(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected wait to end with the context, got %v", err)
+	}
+}
+
+func TestPixelBudgetCancel(t *testing.T) {
+	budget := NewPixelBudget(10)
+	budget.acquire(context.Background(), 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- budget.acquire(ctx, 5) }()
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected cancel to end the wait")
+	}
+	if inUse, _ := budget.Usage(); inUse != 10 {
+		t.Errorf("Expected cancelled acquire to take nothing, got %d in use", inUse)
+	}
+}
+
+func TestLoadImageContext(t *testing.T) {
+	svc := NewService()
+	budget := NewPixelBudget(5000)
+	path := filepath.Join(t.TempDir(), "product.png")
+	if err := svc.SaveImage(image.NewRGBA(image.Rect(0, 0, 60, 50)), path, "png", 0); err != nil {
+		t.Fatalf("SaveImage failed: %v", err)
+	}
+
+	img, release, err := svc.LoadImageContext(context.Background(), path, Limits{}, budget)
+	if err != nil {
+		t.Fatalf("LoadImageContext failed: %v", err)
+	}
+	if img.Bounds().Dx() != 60 {
+		t.Errorf("Expected width 60, got %d", img.Bounds().Dx())
+	}
+	if inUse, limit := budget.Usage(); inUse != 3000 || limit != 5000 {
+		t.Errorf("Expected 3000/5000 in use, got %d/%d", inUse, limit)
+	}
+
+	// A second copy does not fit until the first is released
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := svc.LoadImageContext(ctx, path, Limits{}, budget); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected load to wait for the budget, got %v", err)
+	}
+
+	// Another batch has room of its own, but still shares the service's
+	svc.budget = NewPixelBudget(5000)
+	svc.budget.acquire(context.Background(), 3000)
+	other := NewPixelBudget(5000)
+	if _, _, err := svc.LoadImageContext(ctx, path, Limits{}, other); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected load to wait for the service budget, got %v", err)
+	}
+	if inUse, _ := other.Usage(); inUse != 0 {
+		t.Errorf("Expected a failed load to return its batch budget, got %d", inUse)
+	}
+	svc.budget.release(3000)
+	_, releaseOther, err := svc.LoadImageContext(context.Background(), path, Limits{}, nil)
+	if err != nil {
+		t.Fatalf("Expected a load without a batch budget to fit, got %v", err)
+	}
+	if inUse, _ := svc.PixelBudget().Usage(); inUse != 3000 {
+		t.Errorf("Expected 3000 in the service budget, got %d", inUse)
+	}
+	releaseOther()
+
+	release()
+	release() // releasing twice is harmless
+	if inUse, _ := budget.Usage(); inUse != 0 {
+		t.Errorf("Expected budget free after release, got %d", inUse)
+	}
+
+	if _, limit := NewPixelBudget(0).Usage(); limit != DefaultPixelBudget {
+		t.Errorf("Expected default budget, got %d", limit)
+	}
+}
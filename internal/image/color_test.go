@@ -0,0 +1,80 @@
+package image
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"vibe-imageborder/internal/models"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected color.NRGBA
+	}{
+		{"white", color.NRGBA{255, 255, 255, 255}},
+		{"WHITE", color.NRGBA{255, 255, 255, 255}},
+		{"black", color.NRGBA{0, 0, 0, 255}},
+		{"red", color.NRGBA{255, 0, 0, 255}},
+		{"green", color.NRGBA{0, 128, 0, 255}},
+		{"lime", color.NRGBA{0, 255, 0, 255}},
+		{"rebeccapurple", color.NRGBA{102, 51, 153, 255}},
+		{"transparent", color.NRGBA{0, 0, 0, 0}},
+		{"#ff0000", color.NRGBA{255, 0, 0, 255}},
+		{"ff0000", color.NRGBA{255, 0, 0, 255}},
+		{"#f1eeea", color.NRGBA{241, 238, 234, 255}},
+		{"#0F0", color.NRGBA{0, 255, 0, 255}},
+		{"#0f08", color.NRGBA{0, 255, 0, 136}},
+		{"#00000080", color.NRGBA{0, 0, 0, 128}},
+		{"rgb(255, 128, 0)", color.NRGBA{255, 128, 0, 255}},
+		{"rgba(255,0,0,0.5)", color.NRGBA{255, 0, 0, 128}},
+		{"rgb(100% 0% 0% / 25%)", color.NRGBA{255, 0, 0, 64}},
+		{"rgb(300, -5, 0)", color.NRGBA{255, 0, 0, 255}},
+		{"hsl(120, 100%, 50%)", color.NRGBA{0, 255, 0, 255}},
+		{"hsla(240deg 100% 50% / 0.5)", color.NRGBA{0, 0, 255, 128}},
+		{"hsl(0.5turn, 100%, 25%)", color.NRGBA{0, 128, 128, 255}},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseColor(tt.input)
+		if err != nil {
+			t.Errorf("ParseColor(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got := color.NRGBAModel.Convert(c).(color.NRGBA); got != tt.expected {
+			t.Errorf("ParseColor(%q) = %v, expected %v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestParseColorInvalid(t *testing.T) {
+	tests := []string{"", "unknown", "#12", "#ggg", "rgb(1,2)", "rgb(a,b,c)", "hsl(x, 50%, 50%)", "cmyk(0,0,0,0)"}
+
+	for _, input := range tests {
+		if _, err := ParseColor(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestValidateColors(t *testing.T) {
+	overlays := map[string]models.TextOverlay{
+		"ok":   {Text: "<color=rgb(0,0,0)>x</color>", Color: "white"},
+		"bad":  {Text: "x", Color: "whte"},
+		"span": {Text: "<color=#zzz>x</color>", Color: "black"},
+	}
+	if err := ValidateColors("linear(90deg,#fff,#000)", map[string]models.TextOverlay{"ok": overlays["ok"]}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	err := ValidateColors("nope", overlays)
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+	for _, want := range []string{"background", "bad", "span"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in error: %v", want, err)
+		}
+	}
+}
@@ -21,9 +21,11 @@ func NewCompositor(service *Service) *Compositor {
 
 // CompositeResult holds the composited image.
 type CompositeResult struct {
-	Image  image.Image
-	Width  int
-	Height int
+	Image      image.Image
+	Width      int
+	Height     int
+	Warnings   []string
+	AutoColors map[string]string // overlay key, or table key and cell as in "specs[0,1]", -> color chosen for ColorAuto
 }
 
 // Composite combines product and frame images.
@@ -35,11 +37,8 @@ func (c *Compositor) Composite(product, frame image.Image, bgColor string) *Comp
 
 	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
 
-	// Apply background color if specified
-	if bgColor != "" {
-		bg := c.service.CreateBlankCanvas(width, height, bgColor)
-		draw.Draw(canvas, canvas.Bounds(), bg, image.Point{}, draw.Src)
-	}
+	// Apply background color or gradient if specified
+	warnings := fillBackground(canvas, bgColor)
 
 	// Resize product to fill frame dimensions (scale up if smaller)
 	resizedProduct := c.service.ResizeToFill(product, width, height)
@@ -51,9 +50,10 @@ func (c *Compositor) Composite(product, frame image.Image, bgColor string) *Comp
 	draw.Draw(canvas, canvas.Bounds(), frame, image.Point{}, draw.Over)
 
 	return &CompositeResult{
-		Image:  canvas,
-		Width:  width,
-		Height: height,
+		Image:    canvas,
+		Width:    width,
+		Height:   height,
+		Warnings: warnings,
 	}
 }
 
@@ -66,10 +66,7 @@ func (c *Compositor) CompositeWithPosition(product, frame image.Image, bgColor s
 	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
 
 	// Apply background if specified
-	if bgColor != "" {
-		bg := c.service.CreateBlankCanvas(width, height, bgColor)
-		draw.Draw(canvas, canvas.Bounds(), bg, image.Point{}, draw.Src)
-	}
+	warnings := fillBackground(canvas, bgColor)
 
 	// Resize product to fill frame dimensions (scale up if smaller)
 	resizedProduct := c.service.ResizeToFill(product, width, height)
@@ -86,10 +83,25 @@ func (c *Compositor) CompositeWithPosition(product, frame image.Image, bgColor s
 	}
 
 	return &CompositeResult{
-		Image:  canvas,
-		Width:  width,
-		Height: height,
+		Image:    canvas,
+		Width:    width,
+		Height:   height,
+		Warnings: warnings,
+	}
+}
+
+// fillBackground paints bg over the whole canvas.
+// An invalid paint leaves the canvas transparent and returns a warning.
+func fillBackground(canvas *image.RGBA, bg string) []string {
+	if bg == "" {
+		return nil
+	}
+	paint, err := ParsePaint(bg)
+	if err != nil {
+		return []string{fmt.Sprintf("background: %v", err)}
 	}
+	draw.Draw(canvas, canvas.Bounds(), paint.Image(canvas.Bounds()), image.Point{}, draw.Src)
+	return nil
 }
 
 // ToRGBA converts image to RGBA for drawing operations.
@@ -116,11 +128,13 @@ func (c *Compositor) CompositeWithText(
 
 	// Then draw text overlays
 	if textRenderer != nil && len(overlays) > 0 {
-		imgWithText, err := textRenderer.DrawOverlays(result.Image, overlays)
+		textResult, err := textRenderer.RenderOverlays(result.Image, overlays)
 		if err != nil {
 			return nil, fmt.Errorf("failed to draw text: %w", err)
 		}
-		result.Image = imgWithText
+		result.Image = textResult.Image
+		result.Warnings = append(result.Warnings, textResult.Warnings...)
+		result.AutoColors = textResult.AutoColors
 	}
 
 	return result, nil
@@ -137,3 +137,25 @@ func TestCompositeSaveResult(t *testing.T) {
 		t.Errorf("Expected 150x150, got %dx%d", w, h)
 	}
 }
+
+func TestCompositeGradientBackground(t *testing.T) {
+	comp := NewCompositor(NewService())
+
+	product := image.NewRGBA(image.Rect(0, 0, 10, 10)) // fully transparent
+	frame := image.NewRGBA(image.Rect(0, 0, 100, 100))
+
+	result := comp.Composite(product, frame, "linear(180deg,#ffffff,#000000)")
+	if len(result.Warnings) != 0 {
+		t.Fatalf("Unexpected warnings: %v", result.Warnings)
+	}
+	top, _, _, _ := result.Image.At(50, 0).RGBA()
+	bottom, _, _, _ := result.Image.At(50, 99).RGBA()
+	if top>>8 < 245 || bottom>>8 > 10 {
+		t.Errorf("Expected white to black background, got %d to %d", top>>8, bottom>>8)
+	}
+
+	result = comp.Composite(product, frame, "linear(90deg,#ffffff)")
+	if len(result.Warnings) != 1 {
+		t.Errorf("Expected warning for invalid gradient, got %v", result.Warnings)
+	}
+}
@@ -0,0 +1,114 @@
+// Package image provides automatic text color selection by contrast.
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"vibe-imageborder/internal/models"
+)
+
+// ColorAuto as an overlay color picks the overlay's light or dark color,
+// whichever contrasts best with the pixels under the text.
+const ColorAuto = "auto"
+
+// Default colors for ColorAuto when the overlay sets no pair.
+const (
+	defaultLightColor = "white"
+	defaultDarkColor  = "black"
+)
+
+// maxContrastSamples caps how many pixels are read under a text box.
+const maxContrastSamples = 10000
+
+// IsAutoColor reports whether value requests automatic contrast.
+func IsAutoColor(value string) bool {
+	return strings.EqualFold(strings.TrimSpace(value), ColorAuto)
+}
+
+// autoPair returns the overlay's light and dark colors with defaults applied.
+func autoPair(overlay models.TextOverlay) (light, dark string) {
+	light, dark = overlay.LightColor, overlay.DarkColor
+	if strings.TrimSpace(light) == "" {
+		light = defaultLightColor
+	}
+	if strings.TrimSpace(dark) == "" {
+		dark = defaultDarkColor
+	}
+	return light, dark
+}
+
+// autoColor returns the light or dark color with the higher WCAG contrast
+// ratio against the mean luminance of canvas pixels inside box.
+func autoColor(canvas *image.RGBA, box image.Rectangle, overlay models.TextOverlay) (string, error) {
+	light, dark := autoPair(overlay)
+	lightColor, err := ParseColor(light)
+	if err != nil {
+		return "", fmt.Errorf("invalid light color: %w", err)
+	}
+	darkColor, err := ParseColor(dark)
+	if err != nil {
+		return "", fmt.Errorf("invalid dark color: %w", err)
+	}
+
+	background := meanLuminance(canvas, box)
+	if contrastRatio(relativeLuminance(lightColor), background) >= contrastRatio(relativeLuminance(darkColor), background) {
+		return light, nil
+	}
+	return dark, nil
+}
+
+// meanLuminance returns the average relative luminance of opaque-ish pixels
+// in rect, sampling on a grid so large boxes stay cheap.
+// An empty or fully transparent area counts as white.
+func meanLuminance(img *image.RGBA, rect image.Rectangle) float64 {
+	rect = rect.Intersect(img.Bounds())
+	if rect.Empty() {
+		return 1
+	}
+	step := int(math.Ceil(math.Sqrt(float64(rect.Dx()*rect.Dy()) / maxContrastSamples)))
+	if step < 1 {
+		step = 1
+	}
+
+	var sum float64
+	var n int
+	for y := rect.Min.Y; y < rect.Max.Y; y += step {
+		for x := rect.Min.X; x < rect.Max.X; x += step {
+			c := img.RGBAAt(x, y)
+			if c.A == 0 {
+				continue
+			}
+			sum += relativeLuminance(c)
+			n++
+		}
+	}
+	if n == 0 {
+		return 1
+	}
+	return sum / float64(n)
+}
+
+// relativeLuminance returns the WCAG 2 relative luminance of c, ignoring alpha.
+func relativeLuminance(c color.Color) float64 {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	linear := func(v uint8) float64 {
+		s := float64(v) / 255
+		if s <= 0.04045 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(n.R) + 0.7152*linear(n.G) + 0.0722*linear(n.B)
+}
+
+// contrastRatio returns the WCAG contrast ratio between two luminances, 1 to 21.
+func contrastRatio(a, b float64) float64 {
+	if a < b {
+		a, b = b, a
+	}
+	return (a + 0.05) / (b + 0.05)
+}
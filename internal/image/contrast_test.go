@@ -0,0 +1,111 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+
+	"vibe-imageborder/internal/models"
+)
+
+func TestContrastRatio(t *testing.T) {
+	white, black := relativeLuminance(color.White), relativeLuminance(color.Black)
+	if ratio := contrastRatio(white, black); math.Abs(ratio-21) > 0.01 {
+		t.Errorf("Expected 21:1 for white on black, got %.2f", ratio)
+	}
+	if ratio := contrastRatio(black, black); ratio != 1 {
+		t.Errorf("Expected 1:1 for equal colors, got %.2f", ratio)
+	}
+}
+
+func TestAutoColor(t *testing.T) {
+	tests := []struct {
+		name       string
+		background color.Color
+		overlay    models.TextOverlay
+		expected   string
+	}{
+		{"light product", color.White, models.TextOverlay{}, "black"},
+		{"dark product", color.RGBA{20, 20, 40, 255}, models.TextOverlay{}, "white"},
+		{"custom pair on light", color.RGBA{240, 230, 200, 255}, models.TextOverlay{LightColor: "#ffcc00", DarkColor: "#333333"}, "#333333"},
+		{"custom pair on dark", color.Black, models.TextOverlay{LightColor: "#ffcc00", DarkColor: "#333333"}, "#ffcc00"},
+	}
+
+	tr := newTestTextRenderer()
+	for _, tt := range tests {
+		img := image.NewRGBA(image.Rect(0, 0, 300, 100))
+		draw.Draw(img, img.Bounds(), image.NewUniform(tt.background), image.Point{}, draw.Src)
+
+		overlay := tt.overlay
+		overlay.Text, overlay.Position, overlay.FontSize, overlay.Color = "Giá 500K", "10,10", 40, "auto"
+		result, err := tr.RenderOverlays(img, map[string]models.TextOverlay{"price": overlay})
+		if err != nil {
+			t.Fatalf("%s: RenderOverlays failed: %v", tt.name, err)
+		}
+		if got := result.AutoColors["price"]; got != tt.expected {
+			t.Errorf("%s: expected %s, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestAutoColorSamplesOnlyUnderText(t *testing.T) {
+	// Left half white, right half black; text sits on the right
+	img := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(200, 0, 400, 100), image.Black, image.Point{}, draw.Src)
+
+	overlays := map[string]models.TextOverlay{
+		"price": {Text: "500K", Position: "220,20", FontSize: 40, Color: "AUTO"},
+	}
+	result, err := newTestTextRenderer().RenderOverlays(img, overlays)
+	if err != nil {
+		t.Fatalf("RenderOverlays failed: %v", err)
+	}
+	if result.AutoColors["price"] != "white" {
+		t.Errorf("Expected white on the black half, got %q", result.AutoColors["price"])
+	}
+}
+
+func TestAutoColorTableCells(t *testing.T) {
+	// Dark top row, light below: each cell picks its own color
+	img := image.NewRGBA(image.Rect(0, 0, 450, 150))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 450, 52), image.Black, image.Point{}, draw.Src)
+
+	table := testTable()
+	table.Color, table.Border, table.Zebra = "auto", "", ""
+	result, err := newTestTextRenderer().RenderOverlays(img, map[string]models.TextOverlay{"specs": table})
+	if err != nil {
+		t.Fatalf("RenderOverlays failed: %v", err)
+	}
+	expected := map[string]string{
+		"specs[0,0]": "white", "specs[0,1]": "white",
+		"specs[1,0]": "black", "specs[1,1]": "black",
+		"specs[2,0]": "black", "specs[2,1]": "black", "specs[2,2]": "black",
+	}
+	for key, want := range expected {
+		if got := result.AutoColors[key]; got != want {
+			t.Errorf("%s: expected %s, got %q", key, want, got)
+		}
+	}
+	if len(result.AutoColors) != len(expected) {
+		t.Errorf("Expected %d cell colors, got %v", len(expected), result.AutoColors)
+	}
+}
+
+func TestValidateAutoColorPair(t *testing.T) {
+	overlays := map[string]models.TextOverlay{
+		"ok":  {Text: "x", Color: "auto"},
+		"bad": {Text: "x", Color: "auto", DarkColor: "charcoal"},
+	}
+	err := ValidateColors("", overlays)
+	if err == nil {
+		t.Fatal("Expected error for invalid dark color")
+	}
+	delete(overlays, "bad")
+	if err := ValidateColors("", overlays); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
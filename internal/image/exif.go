@@ -0,0 +1,450 @@
+// Package image provides EXIF metadata reading for JPEG, PNG and WebP files.
+package image
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxEXIFSize bounds the metadata block read from a PNG or WebP chunk.
+const maxEXIFSize = 1 << 20
+
+// maxIFDEntries bounds the entries read from one IFD of a corrupt file.
+const maxIFDEntries = 1000
+
+// TIFF tags that point at the Exif and GPS sub-IFDs.
+const (
+	tagExifIFD = 0x8769
+	tagGPSIFD  = 0x8825
+)
+
+// exifTags names the IFD0 and Exif IFD tags returned by ReadEXIF.
+var exifTags = map[uint16]string{
+	0x010F: "Make",
+	0x0110: "Model",
+	0x0112: "Orientation",
+	0x0131: "Software",
+	0x0132: "DateTime",
+	0x013B: "Artist",
+	0x8298: "Copyright",
+	0x829A: "ExposureTime",
+	0x829D: "FNumber",
+	0x8827: "ISOSpeedRatings",
+	0x9003: "DateTimeOriginal",
+	0x9004: "DateTimeDigitized",
+	0x920A: "FocalLength",
+	0xA433: "LensMake",
+	0xA434: "LensModel",
+}
+
+// GPS IFD tags.
+const (
+	gpsLatitudeRef  = 1
+	gpsLatitude     = 2
+	gpsLongitudeRef = 3
+	gpsLongitude    = 4
+)
+
+// TIFF field types.
+const (
+	tiffByte      = 1
+	tiffASCII     = 2
+	tiffShort     = 3
+	tiffLong      = 4
+	tiffRational  = 5
+	tiffUndefined = 7
+	tiffSLong     = 9
+	tiffSRational = 10
+)
+
+// tiffTypeSizes is the byte size of one value of each TIFF field type.
+var tiffTypeSizes = map[uint16]uint64{
+	tiffByte: 1, tiffASCII: 1, tiffShort: 2, tiffLong: 4,
+	tiffRational: 8, tiffUndefined: 1, tiffSLong: 4, tiffSRational: 8,
+}
+
+// ReadEXIF returns selected EXIF tags of a JPEG, PNG or WebP file keyed by
+// tag name, such as "DateTimeOriginal" or "Model". Dates keep the EXIF
+// "2006:01:02 15:04:05" form. A GPS position is returned as signed decimal
+// degrees in "GPSLatitude" and "GPSLongitude". Files without metadata give
+// an empty map.
+func (s *Service) ReadEXIF(path string) (map[string]string, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	data, err := findEXIF(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	if data == nil {
+		return map[string]string{}, nil
+	}
+	tags, err := parseEXIF(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EXIF: %w", err)
+	}
+	return tags, nil
+}
+
+// findEXIF returns the TIFF-structured EXIF block of a JPEG, PNG or WebP
+// file, or nil if there is none. Image data is skipped, not read.
+func findEXIF(r io.ReadSeeker) ([]byte, error) {
+	var head [12]byte
+	n, err := io.ReadFull(r, head[:])
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	var data []byte
+	switch {
+	case n >= 2 && head[0] == 0xFF && head[1] == 0xD8:
+		data, err = jpegEXIF(r)
+	case n >= 8 && string(head[:8]) == "\x89PNG\r\n\x1a\n":
+		data, err = pngEXIF(r)
+	case n == 12 && string(head[:4]) == "RIFF" && string(head[8:]) == "WEBP":
+		data, err = webpEXIF(r)
+	default:
+		return nil, nil
+	}
+	// A file truncated before its metadata has none
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// jpegEXIF scans JPEG marker segments for an "Exif" APP1 segment.
+func jpegEXIF(r io.ReadSeeker) ([]byte, error) {
+	if _, err := r.Seek(2, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var buf [2]byte
+	for {
+		if _, err := io.ReadFull(r, buf[:1]); err != nil {
+			return nil, err
+		}
+		if buf[0] != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG marker")
+		}
+		// Markers may be padded with extra 0xFF bytes
+		marker := byte(0xFF)
+		for marker == 0xFF {
+			if _, err := io.ReadFull(r, buf[:1]); err != nil {
+				return nil, err
+			}
+			marker = buf[0]
+		}
+
+		switch {
+		case marker == 0x01 || marker == 0xD8 || (marker >= 0xD0 && marker <= 0xD7):
+			continue // no payload
+		case marker == 0xDA || marker == 0xD9:
+			return nil, nil // metadata always precedes the scan
+		}
+
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, err
+		}
+		length := int64(binary.BigEndian.Uint16(buf[:]))
+		if length < 2 {
+			return nil, fmt.Errorf("invalid JPEG segment length")
+		}
+		size := length - 2
+
+		if marker == 0xE1 && size >= 6 {
+			segment := make([]byte, size)
+			if _, err := io.ReadFull(r, segment); err != nil {
+				return nil, err
+			}
+			if string(segment[:6]) == "Exif\x00\x00" {
+				return segment[6:], nil
+			}
+			continue // XMP or another APP1
+		}
+		if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// pngEXIF scans PNG chunks for an eXIf chunk.
+func pngEXIF(r io.ReadSeeker) ([]byte, error) {
+	if _, err := r.Seek(8, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var head [8]byte
+	for {
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return nil, err
+		}
+		size := int64(binary.BigEndian.Uint32(head[:4]))
+		switch string(head[4:]) {
+		case "eXIf":
+			return readChunk(r, size)
+		case "IEND":
+			return nil, nil
+		}
+		// Skip the data and CRC
+		if _, err := r.Seek(size+4, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// webpEXIF scans WebP RIFF chunks for an EXIF chunk.
+func webpEXIF(r io.ReadSeeker) ([]byte, error) {
+	var head [8]byte
+	for {
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return nil, err
+		}
+		size := int64(binary.LittleEndian.Uint32(head[4:]))
+		if string(head[:4]) == "EXIF" {
+			data, err := readChunk(r, size)
+			if err != nil {
+				return nil, err
+			}
+			// Some writers keep the JPEG APP1 header
+			return []byte(strings.TrimPrefix(string(data), "Exif\x00\x00")), nil
+		}
+		// Chunks are padded to an even size
+		if _, err := r.Seek(size+size&1, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readChunk reads a metadata chunk of size bytes.
+func readChunk(r io.Reader, size int64) ([]byte, error) {
+	if size > maxEXIFSize {
+		return nil, fmt.Errorf("metadata chunk too large: %d bytes", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// tiffEntry is one IFD entry with its value bytes resolved.
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// tiffReader reads IFDs from a TIFF-structured EXIF block.
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// parseEXIF reads the tags named in exifTags and the GPS position from a
+// TIFF-structured EXIF block.
+func parseEXIF(data []byte) (map[string]string, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("header too short")
+	}
+	t := tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("unknown byte order")
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return nil, fmt.Errorf("not a TIFF header")
+	}
+
+	ifd0, err := t.ifd(t.order.Uint32(data[4:]))
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+	var exifIFD, gpsIFD []tiffEntry
+	for _, entry := range ifd0 {
+		switch entry.tag {
+		case tagExifIFD:
+			if exifIFD, err = t.subIFD(entry); err != nil {
+				return nil, fmt.Errorf("exif IFD: %w", err)
+			}
+		case tagGPSIFD:
+			if gpsIFD, err = t.subIFD(entry); err != nil {
+				return nil, fmt.Errorf("GPS IFD: %w", err)
+			}
+		}
+	}
+
+	for _, entry := range append(ifd0, exifIFD...) {
+		name, ok := exifTags[entry.tag]
+		if !ok {
+			continue
+		}
+		if value, ok := t.format(entry, name); ok {
+			tags[name] = value
+		}
+	}
+	t.addGPS(tags, gpsIFD)
+	return tags, nil
+}
+
+// ifd reads the entries of the IFD at offset.
+func (t tiffReader) ifd(offset uint32) ([]tiffEntry, error) {
+	start := uint64(offset)
+	if start+2 > uint64(len(t.data)) {
+		return nil, fmt.Errorf("IFD offset %d out of range", offset)
+	}
+	count := uint64(t.order.Uint16(t.data[start:]))
+	if count > maxIFDEntries || start+2+count*12 > uint64(len(t.data)) {
+		return nil, fmt.Errorf("IFD at %d truncated", offset)
+	}
+
+	entries := make([]tiffEntry, 0, count)
+	for i := uint64(0); i < count; i++ {
+		raw := t.data[start+2+i*12:]
+		entry := tiffEntry{
+			tag:   t.order.Uint16(raw),
+			typ:   t.order.Uint16(raw[2:]),
+			count: t.order.Uint32(raw[4:]),
+		}
+		typeSize, ok := tiffTypeSizes[entry.typ]
+		if !ok {
+			continue
+		}
+		size := typeSize * uint64(entry.count)
+		if size <= 4 {
+			entry.value = raw[8 : 8+size]
+		} else {
+			at := uint64(t.order.Uint32(raw[8:]))
+			if at+size > uint64(len(t.data)) {
+				continue // value points past the block; skip the tag
+			}
+			entry.value = t.data[at : at+size]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// subIFD follows an IFD pointer entry.
+func (t tiffReader) subIFD(entry tiffEntry) ([]tiffEntry, error) {
+	offset, ok := t.uint(entry, 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid pointer")
+	}
+	return t.ifd(uint32(offset))
+}
+
+// uint returns the i-th BYTE, SHORT or LONG value of entry.
+func (t tiffReader) uint(entry tiffEntry, i int) (uint64, bool) {
+	if uint32(i) >= entry.count {
+		return 0, false
+	}
+	switch entry.typ {
+	case tiffByte:
+		return uint64(entry.value[i]), true
+	case tiffShort:
+		return uint64(t.order.Uint16(entry.value[i*2:])), true
+	case tiffLong:
+		return uint64(t.order.Uint32(entry.value[i*4:])), true
+	}
+	return 0, false
+}
+
+// rational returns the i-th RATIONAL or SRATIONAL value of entry as num/den.
+func (t tiffReader) rational(entry tiffEntry, i int) (num, den float64, ok bool) {
+	if uint32(i) >= entry.count {
+		return 0, 0, false
+	}
+	raw := entry.value[i*8:]
+	switch entry.typ {
+	case tiffRational:
+		num, den = float64(t.order.Uint32(raw)), float64(t.order.Uint32(raw[4:]))
+	case tiffSRational:
+		num, den = float64(int32(t.order.Uint32(raw))), float64(int32(t.order.Uint32(raw[4:])))
+	default:
+		return 0, 0, false
+	}
+	return num, den, den != 0
+}
+
+// format renders the first value of entry as text.
+// Exposure times under a second read as "1/125"; other fractions are
+// rounded to two decimals.
+func (t tiffReader) format(entry tiffEntry, name string) (string, bool) {
+	switch entry.typ {
+	case tiffASCII:
+		value := strings.TrimSpace(strings.TrimRight(string(entry.value), "\x00"))
+		return value, value != ""
+	case tiffByte, tiffShort, tiffLong:
+		n, ok := t.uint(entry, 0)
+		return strconv.FormatUint(n, 10), ok
+	case tiffSLong:
+		if entry.count == 0 {
+			return "", false
+		}
+		return strconv.Itoa(int(int32(t.order.Uint32(entry.value)))), true
+	case tiffRational, tiffSRational:
+		num, den, ok := t.rational(entry, 0)
+		if !ok {
+			return "", false
+		}
+		if name == "ExposureTime" && num > 0 && num < den {
+			return "1/" + strconv.FormatFloat(math.Round(den/num), 'f', -1, 64), true
+		}
+		return strconv.FormatFloat(math.Round(num/den*100)/100, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// addGPS stores the GPS position from the GPS IFD as signed decimal degrees.
+func (t tiffReader) addGPS(tags map[string]string, entries []tiffEntry) {
+	byTag := make(map[uint16]tiffEntry, len(entries))
+	for _, entry := range entries {
+		byTag[entry.tag] = entry
+	}
+	for _, coord := range []struct {
+		name     string
+		tag, ref uint16
+		negative string
+	}{
+		{"GPSLatitude", gpsLatitude, gpsLatitudeRef, "S"},
+		{"GPSLongitude", gpsLongitude, gpsLongitudeRef, "W"},
+	} {
+		entry, ok := byTag[coord.tag]
+		if !ok {
+			continue
+		}
+		// Degrees, minutes and seconds
+		degrees, scale := 0.0, 1.0
+		for i := 0; i < 3; i++ {
+			num, den, ok := t.rational(entry, i)
+			if !ok {
+				break
+			}
+			degrees += num / den / scale
+			scale *= 60
+		}
+		if scale == 1 {
+			continue
+		}
+		if ref, ok := byTag[coord.ref]; ok && strings.HasPrefix(string(ref.value), coord.negative) {
+			degrees = -degrees
+		}
+		tags[coord.name] = strconv.FormatFloat(degrees, 'f', 6, 64)
+	}
+}
@@ -0,0 +1,224 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tiffField is an IFD entry for buildTIFF; value is raw bytes in file order.
+type tiffField struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// tiffOrder is a byte order that can also append, like binary.LittleEndian.
+type tiffOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// buildTIFF lays out IFD0, an Exif IFD and a GPS IFD one after another.
+func buildTIFF(order tiffOrder, ifd0, exif, gps []tiffField) []byte {
+	var buf bytes.Buffer
+	if order.String() == binary.LittleEndian.String() {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	binary.Write(&buf, order, uint16(42))
+	binary.Write(&buf, order, uint32(8))
+
+	ifdSize := func(fields []tiffField) int {
+		size := 2 + len(fields)*12 + 4
+		for _, f := range fields {
+			if len(f.value) > 4 {
+				size += len(f.value)
+			}
+		}
+		return size
+	}
+	exifAt := 8 + ifdSize(ifd0) + 24 // two pointer entries added below
+	gpsAt := exifAt + ifdSize(exif)
+	ifd0 = append(ifd0,
+		tiffField{tagExifIFD, tiffLong, 1, order.AppendUint32(nil, uint32(exifAt))},
+		tiffField{tagGPSIFD, tiffLong, 1, order.AppendUint32(nil, uint32(gpsAt))},
+	)
+
+	writeIFD := func(fields []tiffField) {
+		start := buf.Len()
+		extra := start + 2 + len(fields)*12 + 4
+		var values []byte
+		binary.Write(&buf, order, uint16(len(fields)))
+		for _, f := range fields {
+			binary.Write(&buf, order, f.tag)
+			binary.Write(&buf, order, f.typ)
+			binary.Write(&buf, order, f.count)
+			if len(f.value) <= 4 {
+				buf.Write(append(f.value, make([]byte, 4-len(f.value))...))
+			} else {
+				binary.Write(&buf, order, uint32(extra+len(values)))
+				values = append(values, f.value...)
+			}
+		}
+		binary.Write(&buf, order, uint32(0))
+		buf.Write(values)
+	}
+	writeIFD(ifd0)
+	writeIFD(exif)
+	writeIFD(gps)
+	return buf.Bytes()
+}
+
+func testTIFF(order tiffOrder) []byte {
+	ascii := func(s string) (uint32, []byte) { return uint32(len(s) + 1), append([]byte(s), 0) }
+	rational := func(pairs ...uint32) []byte {
+		var b []byte
+		for _, v := range pairs {
+			b = order.AppendUint32(b, v)
+		}
+		return b
+	}
+	modelLen, model := ascii("X-T30")
+	dateLen, date := ascii("2024:03:09 10:15:00")
+	return buildTIFF(order,
+		[]tiffField{
+			{0x0110, tiffASCII, modelLen, model},
+			{0x0112, tiffShort, 1, order.AppendUint16(nil, 6)},
+		},
+		[]tiffField{
+			{0x9003, tiffASCII, dateLen, date},
+			{0x829A, tiffRational, 1, rational(10, 1250)},
+			{0x829D, tiffRational, 1, rational(28, 10)},
+		},
+		[]tiffField{
+			{gpsLatitudeRef, tiffASCII, 2, []byte("N\x00")},
+			{gpsLatitude, tiffRational, 3, rational(10, 1, 46, 1, 3690, 100)},
+			{gpsLongitudeRef, tiffASCII, 2, []byte("W\x00")},
+			{gpsLongitude, tiffRational, 3, rational(106, 1, 41, 1, 0, 1)},
+		},
+	)
+}
+
+func checkEXIF(t *testing.T, tags map[string]string) {
+	t.Helper()
+	expected := map[string]string{
+		"Model":            "X-T30",
+		"Orientation":      "6",
+		"DateTimeOriginal": "2024:03:09 10:15:00",
+		"ExposureTime":     "1/125",
+		"FNumber":          "2.8",
+		"GPSLatitude":      "10.776917",
+		"GPSLongitude":     "-106.683333",
+	}
+	for name, value := range expected {
+		if tags[name] != value {
+			t.Errorf("%s: expected %q, got %q", name, value, tags[name])
+		}
+	}
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestReadEXIFJPEG(t *testing.T) {
+	tiff := testTIFF(binary.BigEndian)
+	var buf bytes.Buffer
+	buf.Write([]byte{0xFF, 0xD8})
+	// An XMP APP1 before the Exif one is skipped
+	xmp := []byte("http://ns.adobe.com/xap/1.0/\x00<x/>")
+	buf.Write([]byte{0xFF, 0xE1})
+	binary.Write(&buf, binary.BigEndian, uint16(len(xmp)+2))
+	buf.Write(xmp)
+	buf.Write([]byte{0xFF, 0xE1})
+	binary.Write(&buf, binary.BigEndian, uint16(len(tiff)+8))
+	buf.WriteString("Exif\x00\x00")
+	buf.Write(tiff)
+	buf.Write([]byte{0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9})
+
+	tags, err := NewService().ReadEXIF(writeFile(t, "a.jpg", buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadEXIF failed: %v", err)
+	}
+	checkEXIF(t, tags)
+}
+
+func TestReadEXIFPNG(t *testing.T) {
+	var plain bytes.Buffer
+	if err := png.Encode(&plain, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("png.Encode failed: %v", err)
+	}
+	svc := NewService()
+	tags, err := svc.ReadEXIF(writeFile(t, "plain.png", plain.Bytes()))
+	if err != nil || len(tags) != 0 {
+		t.Errorf("Expected no tags for plain PNG, got %v, %v", tags, err)
+	}
+
+	// Insert an eXIf chunk after IHDR (8 byte signature + 25 byte chunk)
+	tiff := testTIFF(binary.LittleEndian)
+	data := plain.Bytes()
+	var buf bytes.Buffer
+	buf.Write(data[:33])
+	binary.Write(&buf, binary.BigEndian, uint32(len(tiff)))
+	buf.WriteString("eXIf")
+	buf.Write(tiff)
+	buf.Write([]byte{0, 0, 0, 0}) // CRC is not checked
+	buf.Write(data[33:])
+
+	tags, err = svc.ReadEXIF(writeFile(t, "exif.png", buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadEXIF failed: %v", err)
+	}
+	checkEXIF(t, tags)
+}
+
+func TestReadEXIFWebP(t *testing.T) {
+	tiff := testTIFF(binary.LittleEndian)
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	// An odd sized chunk is padded before the next one
+	body.WriteString("ICCP")
+	binary.Write(&body, binary.LittleEndian, uint32(3))
+	body.Write([]byte{1, 2, 3, 0})
+	body.WriteString("EXIF")
+	binary.Write(&body, binary.LittleEndian, uint32(len(tiff)+6))
+	body.WriteString("Exif\x00\x00")
+	body.Write(tiff)
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(body.Len()))
+	buf.Write(body.Bytes())
+
+	tags, err := NewService().ReadEXIF(writeFile(t, "a.webp", buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadEXIF failed: %v", err)
+	}
+	checkEXIF(t, tags)
+}
+
+func TestParseEXIFCorrupt(t *testing.T) {
+	tiff := testTIFF(binary.LittleEndian)
+	tests := [][]byte{
+		[]byte("XX*\x00\x08\x00\x00\x00"),
+		[]byte("II*\x00\xff\xff\x00\x00"),
+		tiff[:20],
+	}
+	for i, data := range tests {
+		if _, err := parseEXIF(data); err == nil {
+			t.Errorf("Case %d: expected error", i)
+		}
+	}
+}
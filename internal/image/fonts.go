@@ -2,12 +2,20 @@
 package image
 
 import (
-	"embed"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"unicode"
 
+	tsfont "github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/shaping"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
 )
 
 // Font name constants.
@@ -18,16 +26,23 @@ const (
 
 // FontManager handles font loading and caching.
 type FontManager struct {
-	fonts embed.FS
-	cache map[string]*opentype.Font
-	mu    sync.RWMutex
+	fonts      fs.FS
+	cache      map[string]*opentype.Font
+	data       map[string][]byte // raw font files, kept for the shaping engine
+	shapeCache map[string]*tsfont.Font
+	chain      []string // glyph fallback order, first entry is the primary font
+	mu         sync.RWMutex
 }
 
 // NewFontManager creates font manager with embedded fonts.
-func NewFontManager(fontsFS embed.FS) *FontManager {
+// Any fs.FS containing assets/fonts works, which lets tests use os.DirFS.
+func NewFontManager(fontsFS fs.FS) *FontManager {
 	return &FontManager{
-		fonts: fontsFS,
-		cache: make(map[string]*opentype.Font),
+		fonts:      fontsFS,
+		cache:      make(map[string]*opentype.Font),
+		data:       make(map[string][]byte),
+		shapeCache: make(map[string]*tsfont.Font),
+		chain:      []string{FontBeVietnamPro, FontRoboto},
 	}
 }
 
@@ -49,7 +64,7 @@ func (fm *FontManager) LoadFont(name string) (*opentype.Font, error) {
 	}
 
 	path := "assets/fonts/" + name + ".ttf"
-	data, err := fm.fonts.ReadFile(path)
+	data, err := fs.ReadFile(fm.fonts, path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read font %s: %w", name, err)
 	}
@@ -60,6 +75,7 @@ func (fm *FontManager) LoadFont(name string) (*opentype.Font, error) {
 	}
 
 	fm.cache[name] = f
+	fm.data[name] = data
 	return f, nil
 }
 
@@ -90,6 +106,173 @@ func (fm *FontManager) GetFace(name string, size float64) (font.Face, error) {
 	return face, nil
 }
 
+// RegisterFont parses font data and makes it available under name.
+func (fm *FontManager) RegisterFont(name string, data []byte) error {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse font %s: %w", name, err)
+	}
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.cache[name] = f
+	fm.data[name] = data
+	delete(fm.shapeCache, name)
+	return nil
+}
+
+// LoadFontDir registers every .ttf/.otf file in dir and appends them to the fallback chain.
+// Returns registered font names; a missing dir is not an error.
+func (fm *FontManager) LoadFontDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read font dir: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".ttf" && ext != ".otf") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return names, fmt.Errorf("failed to read font %s: %w", entry.Name(), err)
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if err := fm.RegisterFont(name, data); err != nil {
+			return names, err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fm.SetFallbackChain(append(fm.FallbackChain(), names...)...)
+	return names, nil
+}
+
+// SetFallbackChain sets the font order used to find glyphs.
+func (fm *FontManager) SetFallbackChain(names ...string) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.chain = append([]string(nil), names...)
+}
+
+// FallbackChain returns the font order used to find glyphs.
+func (fm *FontManager) FallbackChain() []string {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return append([]string(nil), fm.chain...)
+}
+
+// fontChain resolves each rune to the first font in the fallback chain that has it.
+// Not safe for concurrent use; create one per layout.
+type fontChain struct {
+	fm      *FontManager
+	names   []string
+	fonts   []*opentype.Font
+	faces   []font.Face
+	size    float64
+	buf     sfnt.Buffer
+	missing []rune
+
+	// Shaping engine state; shaped is false for the basic engine
+	shaped      bool
+	shaper      shaping.HarfbuzzShaper
+	shapeFaces  []*tsfont.Face
+	shapeFailed []bool
+}
+
+// newFontChain loads the fallback chain at size. Fonts that fail to load are skipped.
+func (fm *FontManager) newFontChain(size float64) (*fontChain, error) {
+	c := &fontChain{fm: fm, size: size}
+	var lastErr error
+	for _, name := range fm.FallbackChain() {
+		f, err := fm.LoadFont(name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		c.names = append(c.names, name)
+		c.fonts = append(c.fonts, f)
+	}
+	if len(c.fonts) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("fallback chain is empty")
+		}
+		return nil, fmt.Errorf("failed to load font: %w", lastErr)
+	}
+	c.faces = make([]font.Face, len(c.fonts))
+
+	primary, err := c.newFace(0)
+	if err != nil {
+		return nil, err
+	}
+	c.faces[0] = primary
+	return c, nil
+}
+
+func (c *fontChain) newFace(i int) (font.Face, error) {
+	face, err := opentype.NewFace(c.fonts[i], &opentype.FaceOptions{
+		Size:    c.size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create face: %w", err)
+	}
+	return face, nil
+}
+
+// face returns the face at index i, creating it on first use.
+func (c *fontChain) face(i int) font.Face {
+	if c.faces[i] == nil {
+		face, err := c.newFace(i)
+		if err != nil {
+			return c.faces[0]
+		}
+		c.faces[i] = face
+	}
+	return c.faces[i]
+}
+
+// primary returns the face of the first font in the chain.
+func (c *fontChain) primary() font.Face {
+	return c.face(0)
+}
+
+// fontIndex returns which font in the chain draws r.
+// Runes no font covers are recorded as missing and drawn with the primary font.
+func (c *fontChain) fontIndex(r rune) int {
+	if unicode.IsControl(r) {
+		return 0
+	}
+	for i, f := range c.fonts {
+		if idx, err := f.GlyphIndex(&c.buf, r); err == nil && idx != 0 {
+			return i
+		}
+	}
+	for _, m := range c.missing {
+		if m == r {
+			return 0
+		}
+	}
+	c.missing = append(c.missing, r)
+	return 0
+}
+
+// Close releases all faces created by the chain.
+func (c *fontChain) Close() {
+	for _, face := range c.faces {
+		if face != nil {
+			face.Close()
+		}
+	}
+}
+
 // DefaultFontName returns default font to use.
 func DefaultFontName() string {
 	return FontBeVietnamPro
@@ -0,0 +1,95 @@
+package image
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"vibe-imageborder/internal/models"
+)
+
+func TestFallbackChainDefault(t *testing.T) {
+	fm := NewFontManager(os.DirFS("../.."))
+	chain := fm.FallbackChain()
+	if len(chain) != 2 || chain[0] != FontBeVietnamPro || chain[1] != FontRoboto {
+		t.Errorf("Unexpected default chain: %v", chain)
+	}
+}
+
+func TestLoadFontDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "GoRegular.ttf"), goregular.TTF, 0644); err != nil {
+		t.Fatalf("Failed to write font: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("skip"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	fm := NewFontManager(os.DirFS("../.."))
+	names, err := fm.LoadFontDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFontDir failed: %v", err)
+	}
+	if len(names) != 1 || names[0] != "GoRegular" {
+		t.Errorf("Expected [GoRegular], got %v", names)
+	}
+
+	chain := fm.FallbackChain()
+	if chain[len(chain)-1] != "GoRegular" {
+		t.Errorf("Expected user font at end of chain, got %v", chain)
+	}
+
+	if _, err := fm.LoadFontDir(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("Missing dir should not be an error: %v", err)
+	}
+}
+
+func TestGlyphFallback(t *testing.T) {
+	fm := NewFontManager(os.DirFS("../.."))
+	if err := fm.RegisterFont("GoRegular", goregular.TTF); err != nil {
+		t.Fatalf("RegisterFont failed: %v", err)
+	}
+	fm.SetFallbackChain(FontBeVietnamPro, FontRoboto, "GoRegular")
+	tr := NewTextRenderer(fm)
+
+	// Roboto lacks the arrow, the Go font has it
+	layout, err := tr.layoutOverlay(models.TextOverlay{Text: "A→B", Position: "0,0", FontSize: 20})
+	if err != nil {
+		t.Fatalf("layoutOverlay failed: %v", err)
+	}
+	defer layout.Close()
+
+	runs := layout.lines[0].runs
+	if len(runs) != 3 || runs[1].text != "→" {
+		t.Fatalf("Expected arrow in its own run, got %d runs", len(runs))
+	}
+	if runs[1].face == runs[0].face {
+		t.Error("Expected arrow drawn with a fallback face")
+	}
+	if layout.missingGlyphs() != "" {
+		t.Errorf("Expected no missing glyphs, got %q", layout.missingGlyphs())
+	}
+}
+
+func TestRenderOverlaysMissingGlyphWarning(t *testing.T) {
+	tr := newTestTextRenderer()
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+
+	overlays := map[string]models.TextOverlay{
+		"name": {Text: "Ø 10mm ⌀ 中", Position: "0,0", FontSize: 20, Color: "black"},
+	}
+	result, err := tr.RenderOverlays(img, overlays)
+	if err != nil {
+		t.Fatalf("RenderOverlays failed: %v", err)
+	}
+
+	if len(result.Warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %v", result.Warnings)
+	}
+	if !strings.HasPrefix(result.Warnings[0], "name:") || !strings.Contains(result.Warnings[0], "⌀中") {
+		t.Errorf("Unexpected warning: %s", result.Warnings[0])
+	}
+}
@@ -0,0 +1,600 @@
+// Package image provides reduced-scale decoding of baseline JPEGs.
+package image
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+// errScaleUnsupported marks JPEGs the scaled decoder cannot read, such as
+// progressive, arithmetic-coded, RGB or CMYK files.
+var errScaleUnsupported = errors.New("JPEGs cannot be downscaled, only baseline YCbCr or grayscale ones")
+
+// scaleUnsupported returns errScaleUnsupported for one kind of JPEG, such as
+// "progressive".
+func scaleUnsupported(kind string) error {
+	return fmt.Errorf("%s %w", kind, errScaleUnsupported)
+}
+
+// JPEG markers used by the scaled decoder.
+const (
+	markerSOF0 = 0xc0 // baseline
+	markerSOF1 = 0xc1 // extended sequential, huffman
+	markerDHT  = 0xc4
+	markerRST0 = 0xd0
+	markerRST7 = 0xd7
+	markerSOI  = 0xd8
+	markerEOI  = 0xd9
+	markerSOS  = 0xda
+	markerDQT  = 0xdb
+	markerDRI  = 0xdd
+	markerAPP0 = 0xe0
+)
+
+// unzig maps a coefficient's zigzag position to its natural position.
+var unzig = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// huffTable decodes codes the way of JPEG spec F.2.2.3.
+type huffTable struct {
+	maxCode [17]int32 // largest code of each length, -1 if none
+	minCode [17]int32
+	valPtr  [17]int32
+	vals    []byte
+}
+
+// scaledComponent is one color component and the plane it is decoded into.
+type scaledComponent struct {
+	id     byte
+	h, v   int // sampling factors
+	tq     byte
+	td, ta byte // DC and AC tables of the current scan
+	pred   int32
+	done   bool
+
+	plane         []byte
+	stride        int
+	width, height int // plane size after scaling
+}
+
+// scaledDecoder decodes a sequential JPEG block by block, reducing each
+// 8x8 block to (8/scale)x(8/scale) pixels as it goes, so only the reduced
+// image is ever held.
+type scaledDecoder struct {
+	r     *bufio.Reader
+	err   error
+	bits  uint32
+	nBits int
+
+	scale         int
+	n             int // output pixels per block side
+	reduce        [8][8]float64
+	width, height int
+	hmax, vmax    int
+	restart       int
+	adobe         bool
+	transform     byte
+
+	quant [4][64]int32
+	huff  [2][4]*huffTable // DC, AC
+	comps []scaledComponent
+	img   image.Image
+}
+
+// decodeJPEGScaled decodes a baseline JPEG at 1/scale of its size, scale
+// being 1, 2, 4 or 8, without holding the full-size image. Each output
+// pixel is the average of the scale x scale pixels it replaces.
+func decodeJPEGScaled(r io.Reader, scale int) (image.Image, error) {
+	switch scale {
+	case 1, 2, 4, 8:
+	default:
+		return nil, fmt.Errorf("invalid jpeg scale: %d", scale)
+	}
+	d := &scaledDecoder{r: bufio.NewReader(r), scale: scale, n: 8 / scale}
+	d.buildReduce()
+	return d.decode()
+}
+
+// buildReduce precomputes the 1-D inverse DCT averaged over groups of scale
+// samples; applying it along both axes gives the reduced block.
+func (d *scaledDecoder) buildReduce() {
+	for out := 0; out < d.n; out++ {
+		for freq := 0; freq < 8; freq++ {
+			c := 1.0
+			if freq == 0 {
+				c = 1 / math.Sqrt2
+			}
+			sum := 0.0
+			for i := out * d.scale; i < (out+1)*d.scale; i++ {
+				sum += c / 2 * math.Cos(float64(2*i+1)*float64(freq)*math.Pi/16)
+			}
+			d.reduce[out][freq] = sum / float64(d.scale)
+		}
+	}
+}
+
+func (d *scaledDecoder) decode() (image.Image, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(d.r, soi[:]); err != nil {
+		return nil, fmt.Errorf("failed to read jpeg: %w", err)
+	}
+	if soi[0] != 0xff || soi[1] != markerSOI {
+		return nil, fmt.Errorf("not a jpeg")
+	}
+
+	for {
+		marker, err := d.nextMarker()
+		if err != nil {
+			return nil, err
+		}
+		if marker == markerEOI {
+			break
+		}
+		if marker >= markerRST0 && marker <= markerRST7 {
+			continue
+		}
+
+		segment, err := d.readSegment()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case marker == markerSOF0 || marker == markerSOF1:
+			err = d.processSOF(segment)
+		case marker >= 0xc2 && marker <= 0xcf && marker != markerDHT && marker != 0xc8 && marker != 0xcc:
+			// Progressive, lossless, hierarchical and arithmetic-coded frames
+			err = scaleUnsupported(frameKind(marker))
+		case marker == markerDHT:
+			err = d.processDHT(segment)
+		case marker == markerDQT:
+			err = d.processDQT(segment)
+		case marker == markerDRI:
+			if len(segment) != 2 {
+				err = fmt.Errorf("invalid jpeg restart interval")
+			} else {
+				d.restart = int(segment[0])<<8 | int(segment[1])
+			}
+		case marker == markerSOS:
+			err = d.processSOS(segment)
+		case marker == markerAPP0+14:
+			if len(segment) >= 12 && string(segment[:5]) == "Adobe" {
+				d.adobe, d.transform = true, segment[11]
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if d.img == nil {
+		return nil, fmt.Errorf("jpeg has no image")
+	}
+	for _, c := range d.comps {
+		if !c.done {
+			return nil, fmt.Errorf("jpeg is missing component %d", c.id)
+		}
+	}
+	return d.img, nil
+}
+
+// frameKind names the coding of a start of frame marker the scaled decoder
+// does not read.
+func frameKind(marker byte) string {
+	switch {
+	case marker&3 == 2:
+		return "progressive"
+	case marker&3 == 3:
+		return "lossless"
+	case marker == 0xc5:
+		return "hierarchical"
+	default:
+		return "arithmetic-coded"
+	}
+}
+
+// nextMarker skips to the next marker and returns its code.
+func (d *scaledDecoder) nextMarker() (byte, error) {
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read jpeg: %w", err)
+		}
+		if b != 0xff {
+			continue
+		}
+		for b == 0xff {
+			if b, err = d.r.ReadByte(); err != nil {
+				return 0, fmt.Errorf("failed to read jpeg: %w", err)
+			}
+		}
+		if b != 0 {
+			return b, nil
+		}
+	}
+}
+
+// readSegment reads a marker segment without its length bytes.
+func (d *scaledDecoder) readSegment() ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(d.r, length[:]); err != nil {
+		return nil, fmt.Errorf("failed to read jpeg: %w", err)
+	}
+	n := int(length[0])<<8 | int(length[1])
+	if n < 2 {
+		return nil, fmt.Errorf("invalid jpeg segment length")
+	}
+	segment := make([]byte, n-2)
+	if _, err := io.ReadFull(d.r, segment); err != nil {
+		return nil, fmt.Errorf("failed to read jpeg: %w", err)
+	}
+	return segment, nil
+}
+
+func (d *scaledDecoder) processSOF(segment []byte) error {
+	if d.img != nil {
+		return fmt.Errorf("jpeg has more than one frame")
+	}
+	if len(segment) < 6 || segment[0] != 8 {
+		return scaleUnsupported("12-bit")
+	}
+	d.height = int(segment[1])<<8 | int(segment[2])
+	d.width = int(segment[3])<<8 | int(segment[4])
+	count := int(segment[5])
+	if count == 4 {
+		return scaleUnsupported("CMYK")
+	}
+	if d.width == 0 || d.height == 0 || (count != 1 && count != 3) || len(segment) != 6+3*count {
+		return scaleUnsupported("unusual")
+	}
+
+	d.comps = make([]scaledComponent, count)
+	for i := range d.comps {
+		c := &d.comps[i]
+		c.id, c.h, c.v, c.tq = segment[6+3*i], int(segment[7+3*i]>>4), int(segment[7+3*i]&15), segment[8+3*i]
+		if c.h < 1 || c.h > 4 || c.v < 1 || c.v > 4 || c.tq > 3 {
+			return fmt.Errorf("invalid jpeg component")
+		}
+		d.hmax, d.vmax = max(d.hmax, c.h), max(d.vmax, c.v)
+	}
+
+	bounds := image.Rect(0, 0, ceilDiv(d.width, d.scale), ceilDiv(d.height, d.scale))
+	if count == 1 {
+		gray := image.NewGray(bounds)
+		d.setPlane(0, gray.Pix, gray.Stride, bounds.Dy())
+		d.img = gray
+		return nil
+	}
+
+	// RGB files are marked by an Adobe transform of 0 or by component IDs
+	if (d.adobe && d.transform == 0) || (d.comps[0].id == 'R' && d.comps[1].id == 'G' && d.comps[2].id == 'B') {
+		return scaleUnsupported("RGB")
+	}
+	if d.comps[1].h != 1 || d.comps[1].v != 1 || d.comps[2].h != 1 || d.comps[2].v != 1 {
+		return scaleUnsupported("unusually subsampled")
+	}
+	ratios := map[[2]int]image.YCbCrSubsampleRatio{
+		{1, 1}: image.YCbCrSubsampleRatio444,
+		{2, 1}: image.YCbCrSubsampleRatio422,
+		{2, 2}: image.YCbCrSubsampleRatio420,
+		{1, 2}: image.YCbCrSubsampleRatio440,
+		{4, 1}: image.YCbCrSubsampleRatio411,
+		{4, 2}: image.YCbCrSubsampleRatio410,
+	}
+	ratio, ok := ratios[[2]int{d.comps[0].h, d.comps[0].v}]
+	if !ok {
+		return scaleUnsupported("unusually subsampled")
+	}
+	ycc := image.NewYCbCr(bounds, ratio)
+	d.setPlane(0, ycc.Y, ycc.YStride, bounds.Dy())
+	d.setPlane(1, ycc.Cb, ycc.CStride, len(ycc.Cb)/ycc.CStride)
+	d.setPlane(2, ycc.Cr, ycc.CStride, len(ycc.Cr)/ycc.CStride)
+	d.img = ycc
+	return nil
+}
+
+// setPlane points component i at the pixels it is decoded into.
+func (d *scaledDecoder) setPlane(i int, plane []byte, stride, height int) {
+	c := &d.comps[i]
+	c.plane, c.stride, c.height = plane, stride, height
+	c.width = ceilDiv(ceilDiv(d.width*c.h, d.hmax), d.scale)
+}
+
+func (d *scaledDecoder) processDQT(segment []byte) error {
+	for len(segment) > 0 {
+		precision, tq := segment[0]>>4, segment[0]&15
+		size := 1 + 64
+		if precision == 1 {
+			size = 1 + 128
+		}
+		if tq > 3 || precision > 1 || len(segment) < size {
+			return fmt.Errorf("invalid jpeg quantization table")
+		}
+		for k := 0; k < 64; k++ {
+			if precision == 0 {
+				d.quant[tq][k] = int32(segment[1+k])
+			} else {
+				d.quant[tq][k] = int32(segment[1+2*k])<<8 | int32(segment[2+2*k])
+			}
+		}
+		segment = segment[size:]
+	}
+	return nil
+}
+
+func (d *scaledDecoder) processDHT(segment []byte) error {
+	for len(segment) > 0 {
+		if len(segment) < 17 {
+			return fmt.Errorf("invalid jpeg huffman table")
+		}
+		class, th := segment[0]>>4, segment[0]&15
+		if class > 1 || th > 3 {
+			return fmt.Errorf("invalid jpeg huffman table")
+		}
+		total := 0
+		for _, count := range segment[1:17] {
+			total += int(count)
+		}
+		if total > 256 || len(segment) < 17+total {
+			return fmt.Errorf("invalid jpeg huffman table")
+		}
+
+		t := &huffTable{vals: append([]byte(nil), segment[17:17+total]...)}
+		code, k := int32(0), int32(0)
+		for length := 1; length <= 16; length++ {
+			count := int32(segment[length])
+			t.valPtr[length], t.minCode[length] = k, code
+			code += count
+			k += count
+			t.maxCode[length] = -1
+			if count > 0 {
+				t.maxCode[length] = code - 1
+			}
+			code <<= 1
+		}
+		d.huff[class][th] = t
+		segment = segment[17+total:]
+	}
+	return nil
+}
+
+func (d *scaledDecoder) processSOS(segment []byte) error {
+	if d.img == nil {
+		return fmt.Errorf("jpeg scan before frame header")
+	}
+	if len(segment) < 1 {
+		return fmt.Errorf("invalid jpeg scan")
+	}
+	count := int(segment[0])
+	if count < 1 || count > len(d.comps) || len(segment) != 4+2*count {
+		return fmt.Errorf("invalid jpeg scan")
+	}
+
+	scan := make([]*scaledComponent, count)
+	for i := range scan {
+		id, tables := segment[1+2*i], segment[2+2*i]
+		for j := range d.comps {
+			if d.comps[j].id == id {
+				scan[i] = &d.comps[j]
+			}
+		}
+		if scan[i] == nil {
+			return fmt.Errorf("jpeg scan has unknown component %d", id)
+		}
+		scan[i].td, scan[i].ta = tables>>4, tables&15
+		if scan[i].td > 3 || scan[i].ta > 3 || d.huff[0][scan[i].td] == nil || d.huff[1][scan[i].ta] == nil {
+			return fmt.Errorf("jpeg scan uses a missing huffman table")
+		}
+		scan[i].pred = 0
+	}
+
+	// Blocks of a single-component scan follow that component's own size;
+	// interleaved scans go MCU by MCU
+	var mcusX, mcusY int
+	if count == 1 {
+		c := scan[0]
+		mcusX, mcusY = ceilDiv(ceilDiv(d.width*c.h, d.hmax), 8), ceilDiv(ceilDiv(d.height*c.v, d.vmax), 8)
+	} else {
+		mcusX, mcusY = ceilDiv(d.width, 8*d.hmax), ceilDiv(d.height, 8*d.vmax)
+	}
+
+	d.bits, d.nBits = 0, 0
+	mcu := 0
+	for my := 0; my < mcusY; my++ {
+		for mx := 0; mx < mcusX; mx++ {
+			if d.restart > 0 && mcu > 0 && mcu%d.restart == 0 {
+				if err := d.processRestart(scan); err != nil {
+					return err
+				}
+			}
+			mcu++
+
+			if count == 1 {
+				d.decodeBlock(scan[0], mx, my)
+			} else {
+				for _, c := range scan {
+					for v := 0; v < c.v; v++ {
+						for h := 0; h < c.h; h++ {
+							d.decodeBlock(c, mx*c.h+h, my*c.v+v)
+						}
+					}
+				}
+			}
+			if d.err != nil {
+				return d.err
+			}
+		}
+	}
+	for _, c := range scan {
+		c.done = true
+	}
+	return nil
+}
+
+// processRestart skips to the restart marker that starts the next interval
+// and resets the DC predictions.
+func (d *scaledDecoder) processRestart(scan []*scaledComponent) error {
+	d.bits, d.nBits = 0, 0
+	marker, err := d.nextMarker()
+	if err != nil {
+		return err
+	}
+	if marker < markerRST0 || marker > markerRST7 {
+		return fmt.Errorf("jpeg restart marker missing")
+	}
+	for _, c := range scan {
+		c.pred = 0
+	}
+	return nil
+}
+
+// decodeBlock decodes the 8x8 block at (bx, by) of c and writes its
+// reduced pixels to c's plane.
+func (d *scaledDecoder) decodeBlock(c *scaledComponent, bx, by int) {
+	var coef [64]float64
+	q := &d.quant[c.tq]
+
+	size := d.decodeHuff(d.huff[0][c.td])
+	if size > 16 {
+		d.fail(fmt.Errorf("invalid jpeg dc coefficient"))
+		return
+	}
+	c.pred += d.receiveExtend(size)
+	coef[0] = float64(c.pred * q[0])
+
+	ac := d.huff[1][c.ta]
+	for k := 1; k < 64 && d.err == nil; k++ {
+		rs := d.decodeHuff(ac)
+		run, size := int(rs>>4), rs&15
+		if size == 0 {
+			if run != 15 {
+				break // end of block
+			}
+			k += 15
+			continue
+		}
+		k += run
+		if k > 63 {
+			d.fail(fmt.Errorf("invalid jpeg ac coefficient"))
+			return
+		}
+		coef[unzig[k]] = float64(d.receiveExtend(size) * q[k])
+	}
+	if d.err != nil {
+		return
+	}
+
+	// Reduce columns, then rows: out = R * coef * R^T
+	var rows [8][8]float64
+	for a := 0; a < d.n; a++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for v := 0; v < 8; v++ {
+				sum += d.reduce[a][v] * coef[v*8+u]
+			}
+			rows[a][u] = sum
+		}
+	}
+	for a := 0; a < d.n; a++ {
+		y := by*d.n + a
+		if y >= c.height {
+			break
+		}
+		for b := 0; b < d.n; b++ {
+			x := bx*d.n + b
+			if x >= c.width {
+				break
+			}
+			sum := 128.0
+			for u := 0; u < 8; u++ {
+				sum += rows[a][u] * d.reduce[b][u]
+			}
+			c.plane[y*c.stride+x] = clampByte(sum)
+		}
+	}
+}
+
+// decodeHuff reads one huffman-coded value.
+func (d *scaledDecoder) decodeHuff(t *huffTable) byte {
+	code := int32(d.readBit())
+	for length := 1; length <= 16; length++ {
+		if code <= t.maxCode[length] {
+			return t.vals[t.valPtr[length]+code-t.minCode[length]]
+		}
+		code = code<<1 | int32(d.readBit())
+	}
+	d.fail(fmt.Errorf("invalid jpeg huffman code"))
+	return 0
+}
+
+// receiveExtend reads a size-bit value and sign-extends it (JPEG spec F.2.2.1).
+func (d *scaledDecoder) receiveExtend(size byte) int32 {
+	if size == 0 {
+		return 0
+	}
+	var v int32
+	for i := byte(0); i < size; i++ {
+		v = v<<1 | int32(d.readBit())
+	}
+	if v < 1<<(size-1) {
+		v += -1<<size + 1
+	}
+	return v
+}
+
+// readBit reads the next bit of entropy-coded data. Stuffed zero bytes are
+// dropped; at a marker the data ends and zero bits are returned, leaving
+// the marker to be read.
+func (d *scaledDecoder) readBit() uint32 {
+	if d.nBits == 0 {
+		var b byte
+		p, _ := d.r.Peek(2)
+		switch {
+		case len(p) == 0:
+			d.fail(fmt.Errorf("failed to read jpeg: %w", io.ErrUnexpectedEOF))
+			return 0
+		case p[0] != 0xff:
+			b = p[0]
+			d.r.Discard(1)
+		case len(p) == 2 && p[1] == 0:
+			b = 0xff
+			d.r.Discard(2)
+		}
+		d.bits, d.nBits = uint32(b), 8
+	}
+	d.nBits--
+	return d.bits >> d.nBits & 1
+}
+
+// fail records the first decoding error.
+func (d *scaledDecoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func clampByte(v float64) byte {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	default:
+		return byte(v + 0.5)
+	}
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
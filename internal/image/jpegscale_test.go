@@ -0,0 +1,161 @@
+package image
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// testPhoto returns a gradient, with squares of the given size in another
+// color if squares > 0.
+func testPhoto(width, height, squares int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{uint8(x * 255 / width), uint8(y * 255 / height), 120, 255}
+			if squares > 0 && (x/squares+y/squares)%2 == 0 {
+				c.B = 200
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("Failed to encode jpeg: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// meanDiff is the mean absolute difference of the RGB channels of a and b,
+// which must be the same size.
+func meanDiff(a, b image.Image) float64 {
+	bounds := a.Bounds()
+	total, n := 0.0, 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ar, ag, ab, _ := a.At(x, y).RGBA()
+			br, bg, bb, _ := b.At(x, y).RGBA()
+			for _, d := range []int{int(ar>>8) - int(br>>8), int(ag>>8) - int(bg>>8), int(ab>>8) - int(bb>>8)} {
+				if d < 0 {
+					d = -d
+				}
+				total += float64(d)
+				n++
+			}
+		}
+	}
+	return total / float64(n)
+}
+
+// planeDiff is the mean absolute difference between a reduced plane and
+// the scale x scale box average of the full plane it came from.
+func planeDiff(full []byte, fullStride, fullW, fullH int, got []byte, gotStride, scale int) float64 {
+	total, n := 0.0, 0
+	for oy := 0; oy < ceilDiv(fullH, scale); oy++ {
+		for ox := 0; ox < ceilDiv(fullW, scale); ox++ {
+			sum, count := 0, 0
+			for y := oy * scale; y < min((oy+1)*scale, fullH); y++ {
+				for x := ox * scale; x < min((ox+1)*scale, fullW); x++ {
+					sum += int(full[y*fullStride+x])
+					count++
+				}
+			}
+			d := float64(sum)/float64(count) - float64(got[oy*gotStride+ox])
+			if d < 0 {
+				d = -d
+			}
+			total += d
+			n++
+		}
+	}
+	return total / float64(n)
+}
+
+func TestDecodeJPEGScaledFullSize(t *testing.T) {
+	// Odd sizes leave partial blocks and MCUs at the edges
+	data := encodeJPEG(t, testPhoto(203, 141, 40))
+	expected, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("jpeg.Decode failed: %v", err)
+	}
+
+	got, err := decodeJPEGScaled(bytes.NewReader(data), 1)
+	if err != nil {
+		t.Fatalf("decodeJPEGScaled failed: %v", err)
+	}
+	if got.Bounds() != expected.Bounds() {
+		t.Fatalf("Expected bounds %v, got %v", expected.Bounds(), got.Bounds())
+	}
+	if diff := meanDiff(got, expected); diff > 1 {
+		t.Errorf("Expected output matching image/jpeg, mean difference %.2f", diff)
+	}
+}
+
+func TestDecodeJPEGScaled(t *testing.T) {
+	data := encodeJPEG(t, testPhoto(203, 141, 40))
+	decoded, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("jpeg.Decode failed: %v", err)
+	}
+	full := decoded.(*image.YCbCr)
+	chromaW, chromaH := ceilDiv(203, 2), ceilDiv(141, 2) // 4:2:0
+
+	for _, scale := range []int{2, 4, 8} {
+		img, err := decodeJPEGScaled(bytes.NewReader(data), scale)
+		if err != nil {
+			t.Fatalf("scale %d: decodeJPEGScaled failed: %v", scale, err)
+		}
+		got, ok := img.(*image.YCbCr)
+		if !ok || got.SubsampleRatio != full.SubsampleRatio {
+			t.Fatalf("scale %d: expected %v YCbCr image, got %T", scale, full.SubsampleRatio, img)
+		}
+		if got.Rect.Dx() != ceilDiv(203, scale) || got.Rect.Dy() != ceilDiv(141, scale) {
+			t.Fatalf("scale %d: unexpected bounds %v", scale, got.Rect)
+		}
+
+		// Each plane is the box average of the full-size plane
+		diffs := []float64{
+			planeDiff(full.Y, full.YStride, 203, 141, got.Y, got.YStride, scale),
+			planeDiff(full.Cb, full.CStride, chromaW, chromaH, got.Cb, got.CStride, scale),
+			planeDiff(full.Cr, full.CStride, chromaW, chromaH, got.Cr, got.CStride, scale),
+		}
+		for i, diff := range diffs {
+			if diff > 1 {
+				t.Errorf("scale %d: plane %d differs by %.2f on average", scale, i, diff)
+			}
+		}
+	}
+}
+
+func TestDecodeJPEGScaledGray(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 64, 48))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i % 64 * 4)
+	}
+	got, err := decodeJPEGScaled(bytes.NewReader(encodeJPEG(t, src)), 4)
+	if err != nil {
+		t.Fatalf("decodeJPEGScaled failed: %v", err)
+	}
+	if _, ok := got.(*image.Gray); !ok || got.Bounds().Dx() != 16 || got.Bounds().Dy() != 12 {
+		t.Errorf("Expected 16x12 gray image, got %T %v", got, got.Bounds())
+	}
+}
+
+func TestDecodeJPEGScaledUnsupported(t *testing.T) {
+	// A progressive frame header
+	data := []byte{0xff, 0xd8, 0xff, 0xc2, 0x00, 0x0b, 8, 0, 16, 0, 16, 1, 1, 0x11, 0}
+	if _, err := decodeJPEGScaled(bytes.NewReader(data), 2); !errors.Is(err, errScaleUnsupported) {
+		t.Errorf("Expected errScaleUnsupported, got %v", err)
+	}
+	if _, err := decodeJPEGScaled(bytes.NewReader([]byte("not a jpeg")), 2); err == nil {
+		t.Error("Expected error for non-jpeg data")
+	}
+}
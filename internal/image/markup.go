@@ -0,0 +1,137 @@
+// Package image provides inline text markup parsing.
+package image
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// markupTagRegex matches supported inline tags such as <b>, </b> or <color=#ff0000>.
+var markupTagRegex = regexp.MustCompile(`<(/?)(b|i|u|s|color|size)(?:=("[^"]*"|[^>]*))?>`)
+
+// spanStyle is the inline styling applied to a span of overlay text.
+// Zero values inherit from the overlay.
+type spanStyle struct {
+	bold      bool
+	italic    bool
+	underline bool
+	strike    bool
+	color     string
+	size      float64
+}
+
+// textSpan is a piece of text sharing one style.
+type textSpan struct {
+	text  string
+	style spanStyle
+}
+
+// markupTag is an open tag on the parse stack.
+type markupTag struct {
+	name  string
+	value string
+}
+
+// parseMarkup splits text into styled spans.
+// Supported tags: <b>, <i>, <u>, <s>, <color=VALUE> and <size=N>, each closed
+// by </tag>. Unknown or unmatched tags are kept as literal text.
+func parseMarkup(text string) []textSpan {
+	var spans []textSpan
+	var stack []markupTag
+
+	appendText := func(s string) {
+		if s == "" {
+			return
+		}
+		style := styleFromStack(stack)
+		if n := len(spans); n > 0 && spans[n-1].style == style {
+			spans[n-1].text += s
+			return
+		}
+		spans = append(spans, textSpan{text: s, style: style})
+	}
+
+	pos := 0
+	for _, m := range markupTagRegex.FindAllStringSubmatchIndex(text, -1) {
+		tag := text[m[0]:m[1]]
+		closing := text[m[2]:m[3]] == "/"
+		name := text[m[4]:m[5]]
+		value := ""
+		if m[6] >= 0 {
+			value = strings.Trim(strings.TrimSpace(text[m[6]:m[7]]), `"`)
+		}
+
+		switch {
+		case closing && value == "":
+			idx := lastTag(stack, name)
+			if idx < 0 {
+				continue // unmatched close tag stays literal
+			}
+			appendText(text[pos:m[0]])
+			stack = append(stack[:idx], stack[idx+1:]...)
+		case !closing && validTagValue(name, value):
+			appendText(text[pos:m[0]])
+			stack = append(stack, markupTag{name: name, value: value})
+		default:
+			continue
+		}
+		pos = m[0] + len(tag)
+	}
+	appendText(text[pos:])
+	return spans
+}
+
+// validTagValue reports whether a tag has the value it requires.
+func validTagValue(name, value string) bool {
+	switch name {
+	case "color":
+		return value != ""
+	case "size":
+		size, err := strconv.ParseFloat(value, 64)
+		return err == nil && size > 0
+	default:
+		return value == ""
+	}
+}
+
+// lastTag returns the index of the innermost open tag with name, or -1.
+func lastTag(stack []markupTag, name string) int {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// styleFromStack combines open tags into a style; inner tags win.
+func styleFromStack(stack []markupTag) spanStyle {
+	var style spanStyle
+	for _, tag := range stack {
+		switch tag.name {
+		case "b":
+			style.bold = true
+		case "i":
+			style.italic = true
+		case "u":
+			style.underline = true
+		case "s":
+			style.strike = true
+		case "color":
+			style.color = tag.value
+		case "size":
+			style.size, _ = strconv.ParseFloat(tag.value, 64)
+		}
+	}
+	return style
+}
+
+// StripMarkup returns text with supported inline tags removed.
+func StripMarkup(text string) string {
+	var b strings.Builder
+	for _, span := range parseMarkup(text) {
+		b.WriteString(span.text)
+	}
+	return b.String()
+}
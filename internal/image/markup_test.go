@@ -0,0 +1,118 @@
+package image
+
+import (
+	"testing"
+
+	"vibe-imageborder/internal/models"
+)
+
+func TestParseMarkup(t *testing.T) {
+	spans := parseMarkup(`Giá <b>500K</b> <s><color="gray">650K</color></s> <size=60>to</size>`)
+
+	expected := []textSpan{
+		{text: "Giá "},
+		{text: "500K", style: spanStyle{bold: true}},
+		{text: " "},
+		{text: "650K", style: spanStyle{strike: true, color: "gray"}},
+		{text: " "},
+		{text: "to", style: spanStyle{size: 60}},
+	}
+	if len(spans) != len(expected) {
+		t.Fatalf("Expected %d spans, got %+v", len(expected), spans)
+	}
+	for i, span := range spans {
+		if span != expected[i] {
+			t.Errorf("Span %d: expected %+v, got %+v", i, expected[i], span)
+		}
+	}
+}
+
+func TestParseMarkupLiteralTags(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a < b", "a < b"},
+		{"<p>x</p>", "<p>x</p>"},
+		{"x</b>", "x</b>"},
+		{"<size=abc>x", "<size=abc>x"},
+		{"<color>x", "<color>x"},
+		{"<b>open", "open"},
+		{"<i>a<b>b</i>c</b>", "abc"},
+	}
+
+	for _, tt := range tests {
+		if got := StripMarkup(tt.input); got != tt.expected {
+			t.Errorf("StripMarkup(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestLayoutRichTextSharedBaseline(t *testing.T) {
+	tr := newTestTextRenderer()
+
+	layout, err := tr.layoutOverlay(models.TextOverlay{
+		Text:     "small <size=60>BIG</size> <b>bold</b>",
+		Position: "0,0",
+		FontSize: 20,
+	})
+	if err != nil {
+		t.Fatalf("layoutOverlay failed: %v", err)
+	}
+	defer layout.Close()
+
+	if len(layout.lines) != 1 {
+		t.Fatalf("Expected 1 line, got %d", len(layout.lines))
+	}
+	line := layout.lines[0]
+	if line.y != 60 {
+		t.Errorf("Expected baseline at the largest span's ascent (60), got %.1f", line.y)
+	}
+	if line.text != "small BIG bold" {
+		t.Errorf("Expected markup stripped from line text, got %q", line.text)
+	}
+
+	var big, bold *textRun
+	for i := range line.runs {
+		switch line.runs[i].text {
+		case "BIG":
+			big = &line.runs[i]
+		case "bold":
+			bold = &line.runs[i]
+		}
+	}
+	if big == nil || big.size != 60 {
+		t.Fatalf("Expected BIG run at size 60, got %+v", line.runs)
+	}
+	if bold == nil || !bold.style.bold || bold.size != 20 {
+		t.Fatalf("Expected bold run at size 20, got %+v", line.runs)
+	}
+}
+
+func TestLayoutWrapsAcrossSpans(t *testing.T) {
+	tr := newTestTextRenderer()
+
+	layout, err := tr.layoutOverlay(models.TextOverlay{
+		Text:     "one <b>two three</b> <i>four</i> five",
+		Position: "100,0",
+		FontSize: 20,
+		MaxWidth: 90,
+		Align:    "right",
+	})
+	if err != nil {
+		t.Fatalf("layoutOverlay failed: %v", err)
+	}
+	defer layout.Close()
+
+	if len(layout.lines) < 2 {
+		t.Fatalf("Expected wrapped lines, got %d", len(layout.lines))
+	}
+	for _, line := range layout.lines {
+		if line.advance > 90 && len(line.runs) > 1 {
+			t.Errorf("Line %q wider than max width: %.1f", line.text, line.advance)
+		}
+		if end := line.x + line.advance; end < 99.5 || end > 100.5 {
+			t.Errorf("Expected line %q right-aligned at 100, ends at %.1f", line.text, end)
+		}
+	}
+}
@@ -0,0 +1,595 @@
+// Package image provides text layout and measurement.
+package image
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	tsfont "github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/unicode/norm"
+	"vibe-imageborder/internal/models"
+)
+
+// defaultOverlayFontSize is used when an overlay has no font size.
+const defaultOverlayFontSize = 40
+
+// Synthetic styles: the bundled fonts only ship a regular weight.
+const (
+	italicShear        = 0.2  // horizontal slant per unit of height
+	boldStrengthRatio  = 24.0 // font size / extra stroke width in pixels
+	decorationRatio    = 16.0 // font size / underline and strike thickness
+	underlineOffsetPct = 0.12 // underline distance below baseline, in ems
+	strikeOffsetPct    = 0.3  // strikethrough height above baseline, in ems
+)
+
+// textRun is a piece of a line drawn with a single font and style.
+// Shaped runs carry glyphs; basic runs are drawn from text with face.
+type textRun struct {
+	text    string
+	face    font.Face
+	x       float64 // offset from the line origin
+	advance float64
+	style   spanStyle
+
+	glyphs    []shaping.Glyph
+	shapeFace *tsfont.Face
+	size      float64
+}
+
+// textLine is one laid out line of an overlay.
+type textLine struct {
+	text    string
+	runs    []textRun
+	advance float64
+	x, y    float64 // baseline origin
+}
+
+// textLayout holds where each line of an overlay is drawn.
+// Drawing and measuring share it so the reported boxes match the output.
+type textLayout struct {
+	fm       *FontManager
+	shaped   bool
+	fontSize float64
+	chains   map[float64]*fontChain
+	order    []*fontChain
+	lines    []textLine
+	path     *textPath // text follows this arc instead of straight lines
+	stroke   int       // outline width in pixels, 0 without a stroke
+}
+
+// layoutOverlay parses inline markup, breaks overlay text into lines and
+// positions them on a shared baseline per line.
+// Caller must Close the returned layout.
+func (tr *TextRenderer) layoutOverlay(overlay models.TextOverlay) (*textLayout, error) {
+	var path *textPath
+	var x, y int
+	var err error
+	if overlay.Path != "" {
+		path, err = parseTextPath(overlay.Path)
+	} else {
+		x, y, err = ParsePosition(overlay.Position)
+		if err != nil {
+			err = fmt.Errorf("invalid position: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fontSize := float64(overlay.FontSize)
+	if fontSize <= 0 {
+		fontSize = defaultOverlayFontSize
+	}
+
+	if tr.fontManager == nil {
+		return nil, fmt.Errorf("font manager not configured")
+	}
+	layout := &textLayout{
+		fm:       tr.fontManager,
+		shaped:   tr.engine != EngineBasic || path != nil, // curving needs glyph outlines
+		fontSize: fontSize,
+		chains:   make(map[float64]*fontChain),
+		path:     path,
+		stroke:   strokeWidth(overlay, fontSize),
+	}
+	base, err := layout.chain(fontSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// NFD input would draw combining marks as separate, misplaced glyphs
+	spans := parseMarkup(norm.NFC.String(overlay.Text))
+
+	if path != nil {
+		layout.layoutPath(spans, overlay.Align)
+		return layout, nil
+	}
+
+	baseline := float64(y)
+	for _, paragraph := range splitParagraphs(spans) {
+		for _, lineSpans := range layout.wrap(paragraph, float64(overlay.MaxWidth)) {
+			runs, advance := layout.shapeSpans(lineSpans)
+
+			// Position is the top of the first line; later lines advance by line height
+			ascent, height := fontSize, fixedToFloat(base.primary().Metrics().Height)
+			if len(runs) > 0 {
+				ascent, height = 0, 0
+				for _, run := range runs {
+					ascent = math.Max(ascent, run.size)
+					height = math.Max(height, fixedToFloat(run.face.Metrics().Height))
+				}
+			}
+			if len(layout.lines) == 0 {
+				baseline += ascent
+			} else {
+				baseline += height
+			}
+
+			lineX := float64(x)
+			switch strings.ToLower(overlay.Align) {
+			case "center":
+				lineX -= advance / 2
+			case "right":
+				lineX -= advance
+			}
+			layout.lines = append(layout.lines, textLine{
+				text:    spansText(lineSpans),
+				runs:    runs,
+				advance: advance,
+				x:       lineX,
+				y:       baseline,
+			})
+		}
+	}
+	return layout, nil
+}
+
+// layoutPath lays spans out as a single line along the layout's arc.
+// Line x is the arc distance of the first glyph from the start angle, so
+// center and right alignment put the text's middle or end at the start angle.
+func (l *textLayout) layoutPath(spans []textSpan, align string) {
+	var line []textSpan
+	for _, span := range spans {
+		span.text = strings.ReplaceAll(span.text, "\n", " ")
+		line = appendSpan(line, span)
+	}
+	runs, advance := l.shapeSpans(line)
+
+	var start float64
+	switch strings.ToLower(align) {
+	case "center":
+		start = -advance / 2
+	case "right":
+		start = -advance
+	}
+	l.lines = append(l.lines, textLine{text: spansText(line), runs: runs, advance: advance, x: start})
+}
+
+// chain returns the font chain for size, loading it on first use.
+func (l *textLayout) chain(size float64) (*fontChain, error) {
+	if c, ok := l.chains[size]; ok {
+		return c, nil
+	}
+	c, err := l.fm.newFontChain(size)
+	if err != nil {
+		return nil, err
+	}
+	c.shaped = l.shaped
+	l.chains[size] = c
+	l.order = append(l.order, c)
+	return c, nil
+}
+
+// Close releases the layout's font faces.
+func (l *textLayout) Close() {
+	for _, c := range l.order {
+		c.Close()
+	}
+}
+
+// missingGlyphs returns characters no font in the chain could draw.
+// Curved text is drawn only from shaped outlines, so there the characters
+// of runs whose font could not be shaped are missing too.
+func (l *textLayout) missingGlyphs() string {
+	var missing []rune
+	seen := make(map[rune]bool)
+	add := func(r rune) {
+		if !seen[r] {
+			seen[r] = true
+			missing = append(missing, r)
+		}
+	}
+	for _, c := range l.order {
+		for _, r := range c.missing {
+			add(r)
+		}
+	}
+	if l.path != nil {
+		for _, line := range l.lines {
+			for _, run := range line.runs {
+				if run.glyphs != nil {
+					continue
+				}
+				for _, r := range run.text {
+					if !unicode.IsSpace(r) {
+						add(r)
+					}
+				}
+			}
+		}
+	}
+	return string(missing)
+}
+
+// shapeSpans turns styled spans into positioned runs and returns the line advance.
+func (l *textLayout) shapeSpans(spans []textSpan) ([]textRun, float64) {
+	var runs []textRun
+	var x float64
+	for _, span := range spans {
+		size := l.fontSize
+		if span.style.size > 0 {
+			size = span.style.size
+		}
+		fonts, err := l.chain(size)
+		if err != nil {
+			fonts, _ = l.chain(l.fontSize)
+		}
+
+		spanRuns, advance := splitRuns(fonts, span.text, span.style)
+		for _, run := range spanRuns {
+			run.x += x
+			runs = append(runs, run)
+		}
+		x += advance
+	}
+	return runs, x
+}
+
+// splitRuns cuts text into runs sharing one font and measures them.
+func splitRuns(fonts *fontChain, text string, style spanStyle) ([]textRun, float64) {
+	var runs []textRun
+	var x float64
+	start, current := 0, -1
+	flush := func(end int) {
+		if end <= start {
+			return
+		}
+		run := textRun{text: text[start:end], face: fonts.face(current), x: x, size: fonts.size, style: style}
+		if fonts.shaped {
+			if out, ok := fonts.shapeRun(current, run.text); ok {
+				run.glyphs = out.Glyphs
+				run.shapeFace = out.Face
+				run.advance = fixedToFloat(out.Advance)
+			}
+		}
+		if run.glyphs == nil {
+			run.advance = fixedToFloat(font.MeasureString(run.face, run.text))
+		}
+		if style.bold {
+			run.advance += run.boldStrength()
+		}
+		runs = append(runs, run)
+		x += run.advance
+	}
+
+	for i, r := range text {
+		idx := fonts.fontIndex(r)
+		if idx != current {
+			flush(i)
+			start, current = i, idx
+		}
+	}
+	flush(len(text))
+	return runs, x
+}
+
+// bounds returns the ink bounding box of all lines, stroke included.
+func (l *textLayout) bounds() (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, line := range l.lines {
+		for _, run := range line.runs {
+			if l.path != nil {
+				x0, y0, x1, y1, ok := l.path.runBounds(run, line.x+run.x)
+				if ok {
+					minX, minY = math.Min(minX, x0), math.Min(minY, y0)
+					maxX, maxY = math.Max(maxX, x1), math.Max(maxY, y1)
+				}
+				continue
+			}
+			x0, y0, x1, y1, ok := run.bounds()
+			if !ok {
+				continue
+			}
+			originX := line.x + run.x
+			minX = math.Min(minX, originX+x0)
+			minY = math.Min(minY, line.y+y0)
+			maxX = math.Max(maxX, originX+x1)
+			maxY = math.Max(maxY, line.y+y1)
+		}
+	}
+	if math.IsInf(minX, 1) {
+		return 0, 0, 0, 0
+	}
+	stroke := float64(l.stroke)
+	return minX - stroke, minY - stroke, maxX + stroke, maxY + stroke
+}
+
+// bounds returns the run's ink box relative to its origin, including
+// synthetic bold, italic slant and decorations.
+func (r textRun) bounds() (minX, minY, maxX, maxY float64, ok bool) {
+	minX, minY, maxX, maxY, ok = r.inkBounds()
+	if ok {
+		if r.shear() != 0 {
+			if minY < 0 {
+				maxX += r.shear() * -minY
+			}
+			if maxY > 0 {
+				minX -= r.shear() * maxY
+			}
+		}
+		if r.style.bold {
+			maxX += r.boldStrength()
+		}
+	}
+
+	for _, deco := range r.decorations() {
+		if !ok {
+			minX, minY, maxX, maxY, ok = deco[0], deco[1], deco[2], deco[3], true
+			continue
+		}
+		minX = math.Min(minX, deco[0])
+		minY = math.Min(minY, deco[1])
+		maxX = math.Max(maxX, deco[2])
+		maxY = math.Max(maxY, deco[3])
+	}
+	return minX, minY, maxX, maxY, ok
+}
+
+// inkBounds returns the glyph ink box relative to the run origin.
+func (r textRun) inkBounds() (minX, minY, maxX, maxY float64, ok bool) {
+	if r.glyphs != nil {
+		return shapedBounds(r.glyphs)
+	}
+	b, _ := font.BoundString(r.face, r.text)
+	if b.Empty() {
+		return 0, 0, 0, 0, false
+	}
+	return fixedToFloat(b.Min.X), fixedToFloat(b.Min.Y), fixedToFloat(b.Max.X), fixedToFloat(b.Max.Y), true
+}
+
+// shear returns the italic slant; only shaped runs can be slanted.
+func (r textRun) shear() float64 {
+	if r.style.italic && r.glyphs != nil {
+		return italicShear
+	}
+	return 0
+}
+
+// boldStrength returns how far synthetic bold widens glyphs, in pixels.
+func (r textRun) boldStrength() float64 {
+	return math.Max(1, r.size/boldStrengthRatio)
+}
+
+// decorations returns underline and strikethrough rectangles relative to the run origin.
+func (r textRun) decorations() [][4]float64 {
+	var rects [][4]float64
+	thickness := math.Max(1, r.size/decorationRatio)
+	if r.style.underline {
+		top := r.size * underlineOffsetPct
+		rects = append(rects, [4]float64{0, top, r.advance, top + thickness})
+	}
+	if r.style.strike {
+		top := -r.size*strikeOffsetPct - thickness/2
+		rects = append(rects, [4]float64{0, top, r.advance, top + thickness})
+	}
+	return rects
+}
+
+// Measure returns the rendered bounding box of each overlay on a width x height canvas.
+// Results are sorted by overlay key; text overlays with empty text are omitted.
+// Stack children are measured where the stack places them, and a stack's box
+// covers its visible children.
+func (tr *TextRenderer) Measure(overlays map[string]models.TextOverlay, width, height int) ([]models.OverlayBox, error) {
+	overlays = tr.resolveStacks(overlays)
+
+	boxes := make([]models.OverlayBox, 0, len(overlays))
+	byKey := make(map[string]models.OverlayBox, len(overlays))
+	for _, key := range sortedOverlayKeys(overlays) {
+		overlay := overlays[key]
+		plain := overlay.Type == "" || overlay.Type == models.OverlayText
+		if overlay.Type == models.OverlayStack || (plain && strings.TrimSpace(overlay.Text) == "") {
+			continue
+		}
+
+		box, err := tr.measureOverlay(overlay, width, height)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure %s: %w", key, err)
+		}
+		box.Key = key
+		boxes = append(boxes, box)
+		byKey[key] = box
+	}
+
+	for _, key := range sortedOverlayKeys(overlays) {
+		if overlays[key].Type != models.OverlayStack {
+			continue
+		}
+		if box, ok := stackBox(overlays[key], byKey, width, height); ok {
+			box.Key = key
+			boxes = append(boxes, box)
+		}
+	}
+	sort.Slice(boxes, func(i, j int) bool { return boxes[i].Key < boxes[j].Key })
+	return boxes, nil
+}
+
+// measureOverlay lays out one overlay and converts it to a box.
+func (tr *TextRenderer) measureOverlay(overlay models.TextOverlay, width, height int) (models.OverlayBox, error) {
+	if overlay.Type == models.OverlayShape {
+		rect, err := shapeRect(overlay)
+		if err != nil {
+			return models.OverlayBox{}, err
+		}
+		box := models.OverlayBox{X: rect.Min.X, Y: rect.Min.Y, Width: rect.Dx(), Height: rect.Dy()}
+		box.Clipped = !rect.In(image.Rect(0, 0, width, height))
+		return box, nil
+	}
+
+	if overlay.Type == models.OverlayTable {
+		return tr.measureTable(overlay, width, height)
+	}
+
+	layout, err := tr.layoutOverlay(overlay)
+	if err != nil {
+		return models.OverlayBox{}, err
+	}
+	defer layout.Close()
+
+	minX, minY, maxX, maxY := layout.bounds()
+	box := models.OverlayBox{
+		X:        int(math.Floor(minX)),
+		Y:        int(math.Floor(minY)),
+		Width:    int(math.Ceil(maxX) - math.Floor(minX)),
+		Height:   int(math.Ceil(maxY) - math.Floor(minY)),
+		FontSize: layout.fontSize,
+		Missing:  layout.missingGlyphs(),
+	}
+	for _, line := range layout.lines {
+		box.Lines = append(box.Lines, line.text)
+	}
+	// Curved text has no horizontal baseline
+	if len(layout.lines) > 0 && layout.path == nil {
+		box.Baseline = int(math.Round(layout.lines[0].y))
+	}
+	box.Clipped = box.X < 0 || box.Y < 0 || box.X+box.Width > width || box.Y+box.Height > height
+	return box, nil
+}
+
+// splitParagraphs splits spans at explicit newlines.
+func splitParagraphs(spans []textSpan) [][]textSpan {
+	paragraphs := [][]textSpan{{}}
+	for _, span := range spans {
+		parts := strings.Split(span.text, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				paragraphs = append(paragraphs, []textSpan{})
+			}
+			if part != "" {
+				last := len(paragraphs) - 1
+				paragraphs[last] = append(paragraphs[last], textSpan{text: part, style: span.style})
+			}
+		}
+	}
+	return paragraphs
+}
+
+// wrap breaks a paragraph into lines no wider than maxWidth.
+// Words keep their styles; maxWidth <= 0 disables wrapping.
+func (l *textLayout) wrap(paragraph []textSpan, maxWidth float64) [][]textSpan {
+	if maxWidth <= 0 {
+		return [][]textSpan{paragraph}
+	}
+
+	words := splitWords(paragraph)
+	if len(words) == 0 {
+		return [][]textSpan{{}}
+	}
+
+	var lines [][]textSpan
+	current := words[0]
+	for _, word := range words[1:] {
+		candidate := joinWords(current, word)
+		if _, advance := l.shapeSpans(candidate); advance > maxWidth {
+			lines = append(lines, current)
+			current = word
+		} else {
+			current = candidate
+		}
+	}
+	return append(lines, current)
+}
+
+// splitWords splits spans at whitespace; a word may mix several styles.
+func splitWords(spans []textSpan) [][]textSpan {
+	var words [][]textSpan
+	var word []textSpan
+	for _, span := range spans {
+		start := -1
+		for i, r := range span.text {
+			if unicode.IsSpace(r) {
+				if start >= 0 {
+					word = append(word, textSpan{text: span.text[start:i], style: span.style})
+					start = -1
+				}
+				if len(word) > 0 {
+					words = append(words, word)
+					word = nil
+				}
+				continue
+			}
+			if start < 0 {
+				start = i
+			}
+		}
+		if start >= 0 {
+			word = append(word, textSpan{text: span.text[start:], style: span.style})
+		}
+	}
+	if len(word) > 0 {
+		words = append(words, word)
+	}
+	return words
+}
+
+// joinWords appends word to line separated by a space in the line's last style.
+func joinWords(line, word []textSpan) []textSpan {
+	joined := make([]textSpan, 0, len(line)+len(word)+1)
+	joined = append(joined, line...)
+	joined = appendSpan(joined, textSpan{text: " ", style: line[len(line)-1].style})
+	for _, span := range word {
+		joined = appendSpan(joined, span)
+	}
+	return joined
+}
+
+// appendSpan appends span, merging it into the last span when styles match.
+func appendSpan(spans []textSpan, span textSpan) []textSpan {
+	if n := len(spans); n > 0 && spans[n-1].style == span.style {
+		merged := spans[n-1]
+		merged.text += span.text
+		spans[n-1] = merged
+		return spans
+	}
+	return append(spans, span)
+}
+
+// spansText returns the plain text of spans.
+func spansText(spans []textSpan) string {
+	var b strings.Builder
+	for _, span := range spans {
+		b.WriteString(span.text)
+	}
+	return b.String()
+}
+
+// sortedOverlayKeys returns overlay keys in a stable drawing order.
+func sortedOverlayKeys(overlays map[string]models.TextOverlay) []string {
+	keys := make([]string, 0, len(overlays))
+	for key := range overlays {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func fixedToFloat(v fixed.Int26_6) float64 {
+	return float64(v) / 64
+}
@@ -0,0 +1,105 @@
+package image
+
+import (
+	"os"
+	"testing"
+
+	"vibe-imageborder/internal/models"
+)
+
+// newTestTextRenderer returns a renderer backed by the repo's font assets.
+func newTestTextRenderer() *TextRenderer {
+	return NewTextRenderer(NewFontManager(os.DirFS("../..")))
+}
+
+func TestMeasure(t *testing.T) {
+	tr := newTestTextRenderer()
+
+	overlays := map[string]models.TextOverlay{
+		"price": {Text: "Giá 500K", Position: "10,20", FontSize: 40, Color: "white"},
+		"empty": {Text: "  ", Position: "0,0", FontSize: 40},
+		"edge":  {Text: "Overflow", Position: "180,20", FontSize: 40},
+	}
+
+	boxes, err := tr.Measure(overlays, 200, 200)
+	if err != nil {
+		t.Fatalf("Measure failed: %v", err)
+	}
+
+	if len(boxes) != 2 {
+		t.Fatalf("Expected 2 boxes, got %d", len(boxes))
+	}
+	if boxes[0].Key != "edge" || boxes[1].Key != "price" {
+		t.Errorf("Expected boxes sorted by key, got %s, %s", boxes[0].Key, boxes[1].Key)
+	}
+
+	price := boxes[1]
+	if price.Baseline != 60 {
+		t.Errorf("Expected baseline 60, got %d", price.Baseline)
+	}
+	if price.X < 10 || price.Y < 20 || price.Y+price.Height > 72 {
+		t.Errorf("Unexpected box %+v", price)
+	}
+	if price.Width <= 0 || price.Height <= 0 {
+		t.Errorf("Expected positive box size, got %dx%d", price.Width, price.Height)
+	}
+	if price.Clipped {
+		t.Error("price should not be clipped")
+	}
+	if !boxes[0].Clipped {
+		t.Error("edge should be clipped")
+	}
+}
+
+func TestMeasureWrapAndAlign(t *testing.T) {
+	tr := newTestTextRenderer()
+
+	overlay := models.TextOverlay{
+		Text:     "one two three four\nfive",
+		Position: "100,0",
+		FontSize: 20,
+		MaxWidth: 90,
+		Align:    "center",
+	}
+
+	box, err := tr.measureOverlay(overlay, 200, 200)
+	if err != nil {
+		t.Fatalf("measureOverlay failed: %v", err)
+	}
+
+	if len(box.Lines) < 3 {
+		t.Fatalf("Expected text to wrap into at least 3 lines, got %v", box.Lines)
+	}
+	if box.Lines[len(box.Lines)-1] != "five" {
+		t.Errorf("Expected explicit line break kept, got %v", box.Lines)
+	}
+	center := box.X + box.Width/2
+	if center < 95 || center > 105 {
+		t.Errorf("Expected box centered on x=100, got %+v", box)
+	}
+}
+
+func TestMeasureInvalidPosition(t *testing.T) {
+	tr := newTestTextRenderer()
+
+	overlays := map[string]models.TextOverlay{
+		"bad": {Text: "x", Position: "oops", FontSize: 20},
+	}
+	if _, err := tr.Measure(overlays, 100, 100); err == nil {
+		t.Error("Expected error for invalid position")
+	}
+}
+
+func TestLayoutNormalizesNFC(t *testing.T) {
+	tr := newTestTextRenderer()
+
+	layout, err := tr.layoutOverlay(models.TextOverlay{Text: "Gia\u0301", Position: "0,0", FontSize: 20})
+	if err != nil {
+		t.Fatalf("layoutOverlay failed: %v", err)
+	}
+	defer layout.Close()
+
+	if layout.lines[0].text != "Gi\u00e1" {
+		t.Errorf("Expected composed text, got %q", layout.lines[0].text)
+	}
+}
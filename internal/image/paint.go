@@ -0,0 +1,307 @@
+// Package image provides paint parsing for solid and gradient fills.
+package image
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"vibe-imageborder/internal/models"
+)
+
+// Paint is a parsed fill: a solid color or a linear or radial gradient.
+// Gradients are laid out over the rectangle passed to Image.
+type Paint struct {
+	solid    color.Color
+	gradient *gradient
+}
+
+// gradient is a color ramp along a line (linear) or out from a center (radial).
+type gradient struct {
+	radial bool
+	angle  float64 // linear direction in degrees; 0 points up, 90 right
+	stops  []colorStop
+}
+
+// colorStop is a gradient color at offset 0..1 along the ramp.
+type colorStop struct {
+	color  color.RGBA64
+	offset float64
+}
+
+// ParsePaint parses a color or gradient such as "#ff0000",
+// "linear(90deg,#ff0000,#ffcc00)" or "radial(white,#333333 80%)".
+// Linear gradients default to 180deg (top to bottom), like CSS.
+// Stops may carry a percentage offset; missing offsets are spread evenly.
+// An empty value is white, the overlay default.
+func ParsePaint(value string) (Paint, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Paint{solid: color.White}, nil
+	}
+	name, args, isFunc := splitFunc(value)
+	if !isFunc || (name != "linear" && name != "radial") {
+		c, err := ParseColor(value)
+		if err != nil {
+			return Paint{}, err
+		}
+		return Paint{solid: c}, nil
+	}
+
+	g := &gradient{radial: name == "radial", angle: 180}
+	parts := splitArgs(args)
+	if !g.radial && len(parts) > 0 {
+		if angle, ok := parseAngle(parts[0]); ok {
+			g.angle = angle
+			parts = parts[1:]
+		}
+	}
+	if len(parts) < 2 {
+		return Paint{}, fmt.Errorf("gradient %q needs at least two colors", value)
+	}
+
+	stops, err := parseStops(parts)
+	if err != nil {
+		return Paint{}, fmt.Errorf("invalid gradient %q: %w", value, err)
+	}
+	g.stops = stops
+	return Paint{gradient: g}, nil
+}
+
+// ValidateColors checks the background, every overlay color (or auto color
+// pair) and every inline <color=...> tag, returning one error that lists
+// each invalid value.
+func ValidateColors(background string, overlays map[string]models.TextOverlay) error {
+	var errs []error
+	if background != "" {
+		if _, err := ParsePaint(background); err != nil {
+			errs = append(errs, fmt.Errorf("background: %w", err))
+		}
+	}
+	for _, key := range sortedOverlayKeys(overlays) {
+		overlay := overlays[key]
+		if overlay.Type != models.OverlayShape && IsAutoColor(overlay.Color) {
+			light, dark := autoPair(overlay)
+			for _, c := range []string{light, dark} {
+				if _, err := ParseColor(c); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				}
+			}
+		} else if _, err := ParsePaint(overlay.Color); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+		texts := []string{overlay.Text}
+		for _, row := range overlay.Rows {
+			texts = append(texts, row...)
+		}
+		for _, text := range texts {
+			for _, m := range markupTagRegex.FindAllStringSubmatch(text, -1) {
+				if m[1] != "" || m[2] != "color" || m[3] == "" {
+					continue
+				}
+				if _, err := ParsePaint(strings.Trim(strings.TrimSpace(m[3]), `"`)); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				}
+			}
+		}
+		for _, extra := range [][2]string{{"border", overlay.Border}, {"zebra", overlay.Zebra}} {
+			if extra[1] == "" {
+				continue
+			}
+			if _, err := ParsePaint(extra[1]); err != nil {
+				errs = append(errs, fmt.Errorf("%s %s: %w", key, extra[0], err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IsGradient reports whether the paint varies across its area.
+func (p Paint) IsGradient() bool {
+	return p.gradient != nil
+}
+
+// Image returns a source image for drawing the paint.
+// Gradients span rect; the returned image is unbounded so antialiased
+// edges just outside rect still get the nearest end color.
+func (p Paint) Image(rect image.Rectangle) image.Image {
+	if p.gradient == nil {
+		return image.NewUniform(p.solid)
+	}
+	return &gradientImage{gradient: p.gradient, rect: rect}
+}
+
+// splitFunc splits "name(args)" into its parts.
+func splitFunc(value string) (name, args string, ok bool) {
+	open := strings.IndexByte(value, '(')
+	if open <= 0 || !strings.HasSuffix(value, ")") {
+		return "", "", false
+	}
+	return strings.ToLower(strings.TrimSpace(value[:open])), value[open+1 : len(value)-1], true
+}
+
+// splitArgs splits comma separated arguments, ignoring commas inside
+// parentheses so nested colors like rgb(0,0,0) stay whole.
+func splitArgs(args string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range args {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(args[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(args[start:]))
+}
+
+// parseAngle parses "90deg", "0.25turn" or a bare number of degrees.
+func parseAngle(s string) (float64, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(s, "deg"):
+		s = strings.TrimSuffix(s, "deg")
+	case strings.HasSuffix(s, "turn"):
+		s, scale = strings.TrimSuffix(s, "turn"), 360
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * scale, true
+}
+
+// parseStops parses "color [offset%]" stops and fills in missing offsets.
+func parseStops(parts []string) ([]colorStop, error) {
+	stops := make([]colorStop, len(parts))
+	set := make([]bool, len(parts))
+	for i, part := range parts {
+		colorPart := part
+		if idx := strings.LastIndexByte(part, ' '); idx > 0 && strings.HasSuffix(part, "%") {
+			pct, err := strconv.ParseFloat(strings.TrimSuffix(part[idx+1:], "%"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid stop offset in %q", part)
+			}
+			stops[i].offset = pct / 100
+			set[i] = true
+			colorPart = strings.TrimSpace(part[:idx])
+		}
+		if colorPart == "" {
+			return nil, fmt.Errorf("empty color stop")
+		}
+		c, err := ParseColor(colorPart)
+		if err != nil {
+			return nil, err
+		}
+		stops[i].color = color.RGBA64Model.Convert(c).(color.RGBA64)
+	}
+
+	if !set[0] {
+		stops[0].offset, set[0] = 0, true
+	}
+	if last := len(stops) - 1; !set[last] {
+		stops[last].offset, set[last] = 1, true
+	}
+	// Spread unset stops evenly between their set neighbours
+	for i := 1; i < len(stops); i++ {
+		if set[i] {
+			continue
+		}
+		next := i
+		for !set[next] {
+			next++
+		}
+		from, to := stops[i-1].offset, stops[next].offset
+		for j := i; j < next; j++ {
+			stops[j].offset = from + (to-from)*float64(j-i+1)/float64(next-i+1)
+			set[j] = true
+		}
+	}
+	// Offsets never go backwards
+	for i := 1; i < len(stops); i++ {
+		stops[i].offset = math.Max(stops[i].offset, stops[i-1].offset)
+	}
+	return stops, nil
+}
+
+// at returns the ramp color at position t.
+func (g *gradient) at(t float64) color.RGBA64 {
+	if t <= g.stops[0].offset {
+		return g.stops[0].color
+	}
+	for i := 1; i < len(g.stops); i++ {
+		b := g.stops[i]
+		if t > b.offset {
+			continue
+		}
+		a := g.stops[i-1]
+		if b.offset == a.offset {
+			return b.color
+		}
+		f := (t - a.offset) / (b.offset - a.offset)
+		return color.RGBA64{
+			R: lerp16(a.color.R, b.color.R, f),
+			G: lerp16(a.color.G, b.color.G, f),
+			B: lerp16(a.color.B, b.color.B, f),
+			A: lerp16(a.color.A, b.color.A, f),
+		}
+	}
+	return g.stops[len(g.stops)-1].color
+}
+
+func lerp16(a, b uint16, f float64) uint16 {
+	return uint16(math.Round(float64(a) + (float64(b)-float64(a))*f))
+}
+
+// gradientImage renders a gradient laid out over rect.
+type gradientImage struct {
+	gradient *gradient
+	rect     image.Rectangle
+}
+
+func (g *gradientImage) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+
+func (g *gradientImage) Bounds() image.Rectangle {
+	return image.Rectangle{Min: image.Point{X: -1e9, Y: -1e9}, Max: image.Point{X: 1e9, Y: 1e9}}
+}
+
+func (g *gradientImage) At(x, y int) color.Color {
+	return g.RGBA64At(x, y)
+}
+
+// RGBA64At samples the gradient at the pixel center, following CSS geometry:
+// a linear ramp spans the rect's extent along the angle, a radial ramp
+// reaches the farthest corner.
+func (g *gradientImage) RGBA64At(x, y int) color.RGBA64 {
+	w, h := float64(g.rect.Dx()), float64(g.rect.Dy())
+	px := float64(x) + 0.5 - (float64(g.rect.Min.X) + w/2)
+	py := float64(y) + 0.5 - (float64(g.rect.Min.Y) + h/2)
+
+	var t float64
+	if g.gradient.radial {
+		radius := math.Hypot(w/2, h/2)
+		if radius > 0 {
+			t = math.Hypot(px, py) / radius
+		}
+	} else {
+		sin, cos := math.Sincos(g.gradient.angle * math.Pi / 180)
+		length := math.Abs(w*sin) + math.Abs(h*cos)
+		if length > 0 {
+			t = (px*sin-py*cos)/length + 0.5
+		}
+	}
+	return g.gradient.at(t)
+}
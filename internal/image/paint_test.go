@@ -0,0 +1,109 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestParsePaintSolid(t *testing.T) {
+	paint, err := ParsePaint("#ff0000")
+	if err != nil {
+		t.Fatalf("ParsePaint failed: %v", err)
+	}
+	if paint.IsGradient() {
+		t.Error("Expected solid paint")
+	}
+	r, g, b, _ := paint.Image(image.Rect(0, 0, 10, 10)).At(5, 5).RGBA()
+	if r>>8 != 255 || g != 0 || b != 0 {
+		t.Errorf("Expected red, got %d,%d,%d", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestParsePaintLinear(t *testing.T) {
+	paint, err := ParsePaint("linear(90deg, #ff0000, #0000ff)")
+	if err != nil {
+		t.Fatalf("ParsePaint failed: %v", err)
+	}
+	if !paint.IsGradient() {
+		t.Fatal("Expected gradient")
+	}
+
+	img := paint.Image(image.Rect(100, 0, 200, 10))
+	left := color.RGBAModel.Convert(img.At(100, 5)).(color.RGBA)
+	right := color.RGBAModel.Convert(img.At(199, 5)).(color.RGBA)
+	mid := color.RGBAModel.Convert(img.At(149, 5)).(color.RGBA)
+	if left.R < 250 || left.B > 5 {
+		t.Errorf("Expected red at left edge, got %v", left)
+	}
+	if right.B < 250 || right.R > 5 {
+		t.Errorf("Expected blue at right edge, got %v", right)
+	}
+	if mid.R < 120 || mid.R > 135 || mid.B < 120 || mid.B > 135 {
+		t.Errorf("Expected halfway blend in the middle, got %v", mid)
+	}
+	// Vertical position does not matter at 90deg
+	if img.At(149, 0) != img.At(149, 9) {
+		t.Error("Expected horizontal gradient to be constant down a column")
+	}
+}
+
+func TestParsePaintDefaultsAndStops(t *testing.T) {
+	paint, err := ParsePaint("linear(white, black 20%, red)")
+	if err != nil {
+		t.Fatalf("ParsePaint failed: %v", err)
+	}
+	g := paint.gradient
+	if g.angle != 180 {
+		t.Errorf("Expected default angle 180, got %v", g.angle)
+	}
+	offsets := []float64{0, 0.2, 1}
+	for i, stop := range g.stops {
+		if stop.offset != offsets[i] {
+			t.Errorf("Stop %d: expected offset %v, got %v", i, offsets[i], stop.offset)
+		}
+	}
+
+	// Top row is white, and black by 20% down
+	img := paint.Image(image.Rect(0, 0, 10, 100))
+	if r, _, _, _ := img.At(5, 0).RGBA(); r>>8 < 240 {
+		t.Errorf("Expected white at top, got r=%d", r>>8)
+	}
+	if r, _, _, _ := img.At(5, 19).RGBA(); r>>8 > 10 {
+		t.Errorf("Expected black at 20%%, got r=%d", r>>8)
+	}
+}
+
+func TestParsePaintRadial(t *testing.T) {
+	paint, err := ParsePaint("radial(#ffffff, #000000)")
+	if err != nil {
+		t.Fatalf("ParsePaint failed: %v", err)
+	}
+	img := paint.Image(image.Rect(0, 0, 100, 100))
+	center, _, _, _ := img.At(50, 50).RGBA()
+	corner, _, _, _ := img.At(0, 0).RGBA()
+	if center>>8 < 245 || corner>>8 > 10 {
+		t.Errorf("Expected white center and black corner, got %d and %d", center>>8, corner>>8)
+	}
+}
+
+func TestParsePaintInvalid(t *testing.T) {
+	tests := []string{
+		"linear(90deg, #ff0000)",
+		"radial()",
+		"linear(90deg, #ff0000, #00ff00 abc%)",
+		"linear(90deg, , #00ff00)",
+	}
+	for _, value := range tests {
+		if _, err := ParsePaint(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestSplitArgsNested(t *testing.T) {
+	parts := splitArgs("90deg, rgb(0,0,0) 10%, #fff")
+	if len(parts) != 3 || parts[1] != "rgb(0,0,0) 10%" {
+		t.Errorf("Unexpected split: %q", parts)
+	}
+}
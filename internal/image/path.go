@@ -0,0 +1,226 @@
+// Package image provides text layout along a circular arc.
+package image
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	tsfont "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"golang.org/x/image/vector"
+)
+
+// textPath is a circle that overlay text follows.
+// Angles are in degrees clockwise from 12 o'clock, like gradient angles.
+type textPath struct {
+	cx, cy float64
+	radius float64
+	start  float64
+	ccw    bool
+}
+
+// parseTextPath parses "cx,cy,radius,start[,cw|ccw]".
+// Clockwise text sits on the outside of the circle reading left to right
+// over the top; counter-clockwise text sits inside, reading left to right
+// along the bottom.
+func parseTextPath(value string) (*textPath, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 && len(parts) != 5 {
+		return nil, fmt.Errorf("invalid path %q: expected cx,cy,radius,start[,cw|ccw]", value)
+	}
+
+	var nums [4]float64
+	for i := range nums {
+		v, err := strconv.ParseFloat(strings.TrimSpace(parts[i]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q: %w", value, err)
+		}
+		nums[i] = v
+	}
+	if nums[2] <= 0 {
+		return nil, fmt.Errorf("invalid path %q: radius must be positive", value)
+	}
+
+	path := &textPath{cx: nums[0], cy: nums[1], radius: nums[2], start: nums[3]}
+	if len(parts) == 5 {
+		switch strings.ToLower(strings.TrimSpace(parts[4])) {
+		case "cw":
+		case "ccw":
+			path.ccw = true
+		default:
+			return nil, fmt.Errorf("invalid path %q: direction must be cw or ccw", value)
+		}
+	}
+	return path, nil
+}
+
+// decorationStep is the longest straight piece, in pixels, of an underline
+// or strikethrough bent along an arc.
+const decorationStep = 2.0
+
+// pathFrame maps glyph-local coordinates (x right of the glyph centre,
+// y up from the baseline) onto the canvas.
+type pathFrame struct {
+	ox, oy float64 // glyph centre on the baseline
+	ax, ay float64 // reading direction
+	ux, uy float64 // glyph up
+}
+
+func (f pathFrame) apply(x, y float64) (float64, float64) {
+	return f.ox + x*f.ax + y*f.ux, f.oy + x*f.ay + y*f.uy
+}
+
+// at returns the frame for a glyph centred s pixels along the arc from the start angle.
+func (p *textPath) at(s float64) pathFrame {
+	dir := 1.0
+	if p.ccw {
+		dir = -1
+	}
+	theta := p.start*math.Pi/180 + dir*s/p.radius
+	sin, cos := math.Sincos(theta)
+	f := pathFrame{ox: p.cx + p.radius*sin, oy: p.cy - p.radius*cos}
+	if p.ccw {
+		f.ax, f.ay, f.ux, f.uy = -cos, -sin, -sin, cos
+	} else {
+		f.ax, f.ay, f.ux, f.uy = cos, sin, sin, -cos
+	}
+	return f
+}
+
+// pathGlyphs calls fn with the frame of every glyph in a shaped run whose
+// first glyph starts s pixels along the arc.
+func (p *textPath) pathGlyphs(run textRun, s float64, fn func(i int, frame pathFrame)) {
+	pen := s
+	for i, g := range run.glyphs {
+		advance := fixedToFloat(g.XAdvance)
+		fn(i, p.at(pen+advance/2))
+		pen += advance
+	}
+}
+
+// runBounds returns the canvas ink box of a run placed s pixels along the arc.
+func (p *textPath) runBounds(run textRun, s float64) (minX, minY, maxX, maxY float64, ok bool) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	shear := run.shear()
+	p.pathGlyphs(run, s, func(i int, frame pathFrame) {
+		g := run.glyphs[i]
+		if g.Width == 0 || g.Height == 0 {
+			return
+		}
+		advance := fixedToFloat(g.XAdvance)
+		left := fixedToFloat(g.XOffset+g.XBearing) - advance/2
+		right := left + fixedToFloat(g.Width)
+		top := fixedToFloat(g.YOffset + g.YBearing)
+		bottom := top + fixedToFloat(g.Height)
+		if run.style.bold {
+			right += run.boldStrength()
+		}
+		for _, c := range [][2]float64{{left, top}, {right, top}, {left, bottom}, {right, bottom}} {
+			x, y := frame.apply(c[0]+c[1]*shear, c[1])
+			minX, minY = math.Min(minX, x), math.Min(minY, y)
+			maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+		}
+	})
+	for _, deco := range run.decorations() {
+		for _, pt := range p.decorationOutline(s, deco) {
+			minX, minY = math.Min(minX, pt[0]), math.Min(minY, pt[1])
+			maxX, maxY = math.Max(maxX, pt[0]), math.Max(maxY, pt[1])
+		}
+	}
+	if math.IsInf(minX, 1) {
+		return 0, 0, 0, 0, false
+	}
+	return minX, minY, maxX, maxY, true
+}
+
+// decorationOutline bends a decoration rectangle from textRun.decorations
+// along the arc for a run placed s pixels along it, and returns the canvas
+// outline: the top edge forwards, then the bottom edge back.
+func (p *textPath) decorationOutline(s float64, deco [4]float64) [][2]float64 {
+	steps := max(1, int(math.Ceil((deco[2]-deco[0])/decorationStep)))
+	outline := make([][2]float64, 0, 2*(steps+1))
+	for _, edge := range [2]float64{deco[1], deco[3]} {
+		for i := 0; i <= steps; i++ {
+			t := float64(i) / float64(steps)
+			if edge == deco[3] {
+				t = 1 - t
+			}
+			// Decorations measure y down from the baseline, frames up
+			x, y := p.at(s+deco[0]+t*(deco[2]-deco[0])).apply(0, -edge)
+			outline = append(outline, [2]float64{x, y})
+		}
+	}
+	return outline
+}
+
+// runMask rasterizes a shaped run placed s pixels along the arc, with its
+// underline and strikethrough bent to follow it, and returns the mask and
+// the canvas rectangle it covers. Runs that could not be shaped have nothing
+// to draw; missingGlyphs reports their characters.
+func (p *textPath) runMask(run textRun, s float64) (*image.Alpha, image.Rectangle) {
+	if run.glyphs == nil {
+		return nil, image.Rectangle{}
+	}
+	minX, minY, maxX, maxY, ok := p.runBounds(run, s)
+	if !ok {
+		return nil, image.Rectangle{}
+	}
+	rect := image.Rect(
+		int(math.Floor(minX))-1, int(math.Floor(minY))-1,
+		int(math.Ceil(maxX))+1, int(math.Ceil(maxY))+1,
+	)
+
+	var z vector.Rasterizer
+	z.Reset(rect.Dx(), rect.Dy())
+	scale := run.size / float64(run.shapeFace.Upem())
+	shear := run.shear()
+
+	// Synthetic bold smears each glyph like emboldenMask, but along the
+	// tangent: copies shifted 0 to strength pixels in the glyph's own frame
+	strength := 0
+	if run.style.bold {
+		strength = int(math.Round(run.boldStrength()))
+	}
+	p.pathGlyphs(run, s, func(i int, frame pathFrame) {
+		g := run.glyphs[i]
+		outline, isOutline := run.shapeFace.GlyphData(g.GlyphID).(tsfont.GlyphOutline)
+		if !isOutline {
+			return
+		}
+		dx := fixedToFloat(g.XOffset) - fixedToFloat(g.XAdvance)/2
+		dy := fixedToFloat(g.YOffset)
+		for shift := 0; shift <= strength; shift++ {
+			addOutline(&z, outline, func(pt ot.SegmentPoint) (float32, float32) {
+				lx, ly := float64(pt.X)*scale+dx, float64(pt.Y)*scale+dy
+				x, y := frame.apply(lx+ly*shear+float64(shift), ly)
+				return float32(x - float64(rect.Min.X)), float32(y - float64(rect.Min.Y))
+			})
+		}
+	})
+
+	mask := image.NewAlpha(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+
+	// Decorations are drawn separately so they are not widened with the glyphs
+	if decorations := run.decorations(); len(decorations) > 0 {
+		var d vector.Rasterizer
+		d.Reset(rect.Dx(), rect.Dy())
+		for _, deco := range decorations {
+			outline := p.decorationOutline(s, deco)
+			at := func(i int) (float32, float32) {
+				return float32(outline[i][0] - float64(rect.Min.X)), float32(outline[i][1] - float64(rect.Min.Y))
+			}
+			d.MoveTo(at(0))
+			for i := 1; i < len(outline); i++ {
+				d.LineTo(at(i))
+			}
+			d.ClosePath()
+		}
+		d.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	}
+	return mask, rect
+}
@@ -0,0 +1,240 @@
+package image
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/go-text/typesetting/shaping"
+	"vibe-imageborder/internal/models"
+)
+
+func TestParseTextPath(t *testing.T) {
+	path, err := parseTextPath("250, 250, 150, -90, ccw")
+	if err != nil {
+		t.Fatalf("parseTextPath failed: %v", err)
+	}
+	if path.cx != 250 || path.cy != 250 || path.radius != 150 || path.start != -90 || !path.ccw {
+		t.Errorf("Unexpected path %+v", path)
+	}
+
+	for _, value := range []string{"1,2,3", "a,0,10,0", "0,0,0,0", "0,0,10,0,up"} {
+		if _, err := parseTextPath(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestPathFrame(t *testing.T) {
+	path := &textPath{cx: 100, cy: 100, radius: 50}
+
+	// Clockwise text at 12 o'clock reads left to right, upright
+	f := path.at(0)
+	if f.ox != 100 || f.oy != 50 || f.ax != 1 || f.uy != -1 {
+		t.Errorf("Unexpected top frame %+v", f)
+	}
+
+	// Counter-clockwise text at 6 o'clock also reads left to right, upright
+	path.start, path.ccw = 180, true
+	f = path.at(0)
+	x, y := f.apply(10, 10)
+	if x < 109.9 || x > 110.1 || y < 139.9 || y > 140.1 {
+		t.Errorf("Expected point right of and above the bottom of the circle, got %.1f,%.1f", x, y)
+	}
+}
+
+func TestCurvedTextMeasureAndRender(t *testing.T) {
+	tr := newTestTextRenderer()
+	tr.SetEngine(EngineBasic) // curved text shapes regardless
+
+	overlays := map[string]models.TextOverlay{
+		"top":    {Text: "BẢO HÀNH 12 THÁNG", Path: "200,200,120,0,cw", Align: "center", FontSize: 30, Color: "#ff0000"},
+		"bottom": {Text: "CHÍNH HÃNG", Path: "200,200,120,180,ccw", Align: "center", FontSize: 30, Color: "#ff0000"},
+	}
+	boxes, err := tr.Measure(overlays, 400, 400)
+	if err != nil {
+		t.Fatalf("Measure failed: %v", err)
+	}
+	for _, box := range boxes {
+		center := box.X + box.Width/2
+		if center < 195 || center > 205 {
+			t.Errorf("%s: expected box centered on x=200, got %+v", box.Key, box)
+		}
+	}
+	bottom, top := boxes[0], boxes[1]
+	if top.Y+top.Height > 200 || top.Y < 200-120-30 {
+		t.Errorf("Expected top text above the center, got %+v", top)
+	}
+	if bottom.Y < 200 || bottom.Y+bottom.Height > 200+120+2 {
+		t.Errorf("Expected bottom text inside the lower half, got %+v", bottom)
+	}
+
+	result, err := tr.RenderOverlays(image.NewRGBA(image.Rect(0, 0, 400, 400)), overlays)
+	if err != nil {
+		t.Fatalf("RenderOverlays failed: %v", err)
+	}
+	painted := 0
+	for _, box := range boxes {
+		rect := image.Rect(box.X, box.Y, box.X+box.Width, box.Y+box.Height).Inset(-1)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				if _, _, _, a := result.Image.At(x, y).RGBA(); a != 0 {
+					painted++
+				}
+			}
+		}
+	}
+	total := 0
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 400; x++ {
+			if _, _, _, a := result.Image.At(x, y).RGBA(); a != 0 {
+				total++
+			}
+		}
+	}
+	if total == 0 || painted != total {
+		t.Errorf("Expected all %d painted pixels inside the measured boxes, got %d", total, painted)
+	}
+}
+
+// inkNear counts pixels with alpha within the rings of radius lo to hi
+// around cx, cy, and all pixels with alpha.
+func inkNear(img image.Image, cx, cy, lo, hi float64) (ring, total int) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a == 0 {
+				continue
+			}
+			total++
+			if d := math.Hypot(float64(x)-cx, float64(y)-cy); d >= lo && d <= hi {
+				ring++
+			}
+		}
+	}
+	return ring, total
+}
+
+func TestCurvedTextDecorations(t *testing.T) {
+	tr := newTestTextRenderer()
+	plain := models.TextOverlay{Text: "BẢO HÀNH", Path: "200,200,120,0,cw", Align: "center", FontSize: 32, Color: "#ff0000"}
+	underlined := plain
+	underlined.Text = "<u>BẢO HÀNH</u>"
+
+	// The underline sits 0.12em below the baseline, 2px thick at 32px
+	lo, hi := 120-32*underlineOffsetPct-3, 120-32*underlineOffsetPct+1
+	var rings [2]int
+	for i, overlay := range []models.TextOverlay{plain, underlined} {
+		overlays := map[string]models.TextOverlay{"seal": overlay}
+		result, err := tr.RenderOverlays(image.NewRGBA(image.Rect(0, 0, 400, 400)), overlays)
+		if err != nil {
+			t.Fatalf("RenderOverlays failed: %v", err)
+		}
+		ring, total := inkNear(result.Image, 200, 200, lo, hi)
+		rings[i] = ring
+
+		boxes, err := tr.Measure(overlays, 400, 400)
+		if err != nil {
+			t.Fatalf("Measure failed: %v", err)
+		}
+		box := image.Rect(boxes[0].X, boxes[0].Y, boxes[0].X+boxes[0].Width, boxes[0].Y+boxes[0].Height).Inset(-1)
+		inside, _ := inkNear(result.Image.(*image.RGBA).SubImage(box), 0, 0, 0, math.Inf(1))
+		if inside != total {
+			t.Errorf("%q: expected all %d painted pixels inside the measured box, got %d", overlay.Text, total, inside)
+		}
+	}
+
+	if rings[1] < rings[0]+100 {
+		t.Errorf("Expected the underline to follow the arc, got %d pixels on it without and %d with", rings[0], rings[1])
+	}
+}
+
+func TestCurvedTextStroke(t *testing.T) {
+	tr := newTestTextRenderer()
+	overlay := models.TextOverlay{Text: "CHÍNH HÃNG", Path: "200,200,120,180,ccw", Align: "center", FontSize: 30, Color: "#000000"}
+	stroked := overlay
+	stroked.Stroke, stroked.StrokeWidth = "#ff0000", 3
+
+	boxes, err := tr.Measure(map[string]models.TextOverlay{"plain": overlay, "stroked": stroked}, 400, 400)
+	if err != nil {
+		t.Fatalf("Measure failed: %v", err)
+	}
+	plain, outlined := boxes[0], boxes[1]
+	if outlined.X != plain.X-3 || outlined.Y != plain.Y-3 || outlined.Width != plain.Width+6 || outlined.Height != plain.Height+6 {
+		t.Errorf("Expected the stroke to grow the box by 3px per side, got %+v from %+v", outlined, plain)
+	}
+
+	result, err := tr.RenderOverlays(image.NewRGBA(image.Rect(0, 0, 400, 400)), map[string]models.TextOverlay{"stroked": stroked})
+	if err != nil {
+		t.Fatalf("RenderOverlays failed: %v", err)
+	}
+	canvas := result.Image.(*image.RGBA)
+	var red, black int
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 400; x++ {
+			c := canvas.RGBAAt(x, y)
+			switch {
+			case c.A == 255 && c.R == 255:
+				red++
+			case c.A == 255 && c.R == 0:
+				black++
+			}
+		}
+	}
+	if red == 0 || black == 0 {
+		t.Errorf("Expected a red stroke around black text, got %d red and %d black pixels", red, black)
+	}
+}
+
+func TestCurvedTextBoldFollowsTangent(t *testing.T) {
+	// At 3 o'clock the tangent runs straight down, so bold should thicken
+	// the letter vertically, not push it outward
+	tr := newTestTextRenderer()
+	inkBounds := func(text string) image.Rectangle {
+		overlays := map[string]models.TextOverlay{
+			"side": {Text: text, Path: "200,200,120,90,cw", Align: "center", FontSize: 48, Color: "#000000"},
+		}
+		result, err := tr.RenderOverlays(image.NewRGBA(image.Rect(0, 0, 400, 400)), overlays)
+		if err != nil {
+			t.Fatalf("RenderOverlays failed: %v", err)
+		}
+		var ink image.Rectangle
+		for y := 0; y < 400; y++ {
+			for x := 0; x < 400; x++ {
+				if _, _, _, a := result.Image.At(x, y).RGBA(); a != 0 {
+					ink = ink.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+		return ink
+	}
+
+	// Bold's wider advance turns the letter a little, so allow a pixel
+	regular, bold := inkBounds("I"), inkBounds("<b>I</b>")
+	if bold.Dx() > regular.Dx()+1 {
+		t.Errorf("Expected bold to keep the radial width %d, got %d", regular.Dx(), bold.Dx())
+	}
+	if bold.Dy() < regular.Dy()+2 {
+		t.Errorf("Expected bold to widen along the tangent from %d, got %d", regular.Dy(), bold.Dy())
+	}
+}
+
+func TestCurvedTextUnshapedRunsMissing(t *testing.T) {
+	// Runs without shaped glyphs cannot follow the arc; their characters
+	// are reported rather than silently dropped
+	layout := &textLayout{
+		path: &textPath{cx: 100, cy: 100, radius: 50},
+		lines: []textLine{{runs: []textRun{
+			{text: "Ab c"},
+			{text: "d", glyphs: []shaping.Glyph{{}}},
+		}}},
+	}
+	if got := layout.missingGlyphs(); got != "Abc" {
+		t.Errorf("Expected Abc missing, got %q", got)
+	}
+
+	layout.path = nil
+	if got := layout.missingGlyphs(); got != "" {
+		t.Errorf("Expected straight text to draw unshaped runs, got %q missing", got)
+	}
+}
@@ -5,12 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"image"
-	"image/color"
+	"image/draw"
 	"image/jpeg"
 	"image/png"
+	"io"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 
 	"github.com/disintegration/imaging"
@@ -24,50 +24,172 @@ const (
 	MaxImageHeight = 8192
 )
 
+// MaxImageLimit is the largest width or height a request may allow.
+const MaxImageLimit = 32768
+
 // ErrImageTooLarge is returned when image exceeds maximum dimensions.
 var ErrImageTooLarge = errors.New("image exceeds maximum allowed dimensions")
 
+// Limits bounds the dimensions of the images a load accepts. Zero fields
+// fall back to MaxImageWidth and MaxImageHeight.
+type Limits struct {
+	MaxWidth  int
+	MaxHeight int
+
+	// Downscale lets baseline JPEGs over the limits through by decoding them
+	// at 1/2, 1/4 or 1/8 scale. The largest scale that still covers
+	// TargetWidth x TargetHeight is used, without the full-size bitmap ever
+	// being held. Progressive, CMYK and other JPEGs, and other formats, over
+	// the limits are still rejected with ErrImageTooLarge, saying why.
+	Downscale    bool
+	TargetWidth  int
+	TargetHeight int
+}
+
+// withDefaults fills the zero fields of l.
+func (l Limits) withDefaults() Limits {
+	if l.MaxWidth <= 0 {
+		l.MaxWidth = MaxImageWidth
+	}
+	if l.MaxHeight <= 0 {
+		l.MaxHeight = MaxImageHeight
+	}
+	return l
+}
+
+// check rejects a width x height image over the limits.
+func (l Limits) check(width, height int) error {
+	l = l.withDefaults()
+	if width > l.MaxWidth || height > l.MaxHeight {
+		return fmt.Errorf("%w: %dx%d exceeds %dx%d",
+			ErrImageTooLarge, width, height, l.MaxWidth, l.MaxHeight)
+	}
+	return nil
+}
+
+// jpegScale returns the scale to decode a width x height JPEG at: the
+// largest that fits the limits and still covers the target, else the
+// smallest that fits. It returns 0 if none fits.
+func (l Limits) jpegScale(width, height int) int {
+	best := 0
+	for scale := 2; scale <= 8; scale *= 2 {
+		w, h := ceilDiv(width, scale), ceilDiv(height, scale)
+		if l.check(w, h) != nil {
+			continue
+		}
+		covers := l.TargetWidth > 0 && l.TargetHeight > 0 && w >= l.TargetWidth && h >= l.TargetHeight
+		if best == 0 || covers {
+			best = scale
+		}
+	}
+	return best
+}
+
 // Service handles image operations.
-type Service struct{}
+type Service struct {
+	budget *PixelBudget
+}
 
 // NewService creates new image service.
 func NewService() *Service {
-	return &Service{}
+	return &Service{budget: NewPixelBudget(DefaultPixelBudget)}
 }
 
 // LoadImage loads image from file path with size validation.
 func (s *Service) LoadImage(path string) (image.Image, error) {
-	cleanPath := filepath.Clean(path)
+	return s.LoadImageLimits(path, Limits{})
+}
 
-	img, err := imaging.Open(cleanPath)
+// LoadImageLimits loads an image no larger than limits. The size is read
+// from the header first, so an oversize image is rejected, or downscaled,
+// before any pixel memory is allocated.
+func (s *Service) LoadImageLimits(path string, limits Limits) (image.Image, error) {
+	file, header, err := openImage(path, limits)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open image: %w", err)
+		return nil, err
 	}
+	defer file.Close()
+	return decodeImage(file, header, limits)
+}
 
-	// Validate dimensions to prevent OOM
-	bounds := img.Bounds()
-	if bounds.Dx() > MaxImageWidth || bounds.Dy() > MaxImageHeight {
-		return nil, fmt.Errorf("%w: %dx%d exceeds %dx%d",
-			ErrImageTooLarge, bounds.Dx(), bounds.Dy(), MaxImageWidth, MaxImageHeight)
+// imageHeader is what openImage read from an image's header.
+type imageHeader struct {
+	width, height int   // size once decoded
+	scale         int   // JPEG decoding scale, 1 for full size
+	tooLarge      error // why a downscaled image needs its scale
+}
+
+// openImage opens an image and checks the size in its header against
+// limits. The caller closes the file.
+func openImage(path string, limits Limits) (*os.File, imageHeader, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, imageHeader{}, fmt.Errorf("failed to open image: %w", err)
+	}
+	config, format, err := image.DecodeConfig(file)
+	if err != nil {
+		file.Close()
+		return nil, imageHeader{}, fmt.Errorf("failed to open image: %w", err)
 	}
 
-	return img, nil
+	header := imageHeader{width: config.Width, height: config.Height, scale: 1}
+	if err := limits.check(config.Width, config.Height); err != nil {
+		scale := 0
+		if limits.Downscale {
+			if format != "jpeg" {
+				err = fmt.Errorf("%w; only baseline JPEGs can be downscaled, not %s", err, strings.ToUpper(format))
+			} else if scale = limits.jpegScale(config.Width, config.Height); scale == 0 {
+				err = fmt.Errorf("%w, even at 1/8 scale", err)
+			}
+		}
+		if scale == 0 {
+			file.Close()
+			return nil, imageHeader{}, err
+		}
+		header = imageHeader{
+			width:    ceilDiv(config.Width, scale),
+			height:   ceilDiv(config.Height, scale),
+			scale:    scale,
+			tooLarge: err,
+		}
+	}
+	return file, header, nil
 }
 
-// SaveImage saves image to file with format and quality.
-func (s *Service) SaveImage(img image.Image, path string, format string, quality int) error {
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create output dir: %w", err)
+// decodeImage decodes an image opened by openImage.
+func decodeImage(file *os.File, header imageHeader, limits Limits) (image.Image, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
 	}
 
-	ext := "." + strings.ToLower(format)
-	basePath := strings.TrimSuffix(path, filepath.Ext(path))
-	outputPath := basePath + ext
+	var img image.Image
+	var err error
+	if header.scale > 1 {
+		img, err = decodeJPEGScaled(file, header.scale)
+		if errors.Is(err, errScaleUnsupported) {
+			return nil, fmt.Errorf("%w; %v", header.tooLarge, err)
+		}
+	} else {
+		img, err = imaging.Decode(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
 
-	file, err := os.Create(outputPath)
+	// Headers can lie; check what was decoded too
+	bounds := img.Bounds()
+	if err := limits.check(bounds.Dx(), bounds.Dy()); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// SaveImage saves image to file with format and quality. WebP files are
+// lossy at quality; SaveWebP writes lossless ones.
+func (s *Service) SaveImage(img image.Image, path string, format string, quality int) error {
+	file, err := createOutput(path, format)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return err
 	}
 	defer file.Close()
 
@@ -77,13 +199,41 @@ func (s *Service) SaveImage(img image.Image, path string, format string, quality
 	case "jpg", "jpeg":
 		return jpeg.Encode(file, img, &jpeg.Options{Quality: quality})
 	case "webp":
-		// Go doesn't have native webp encoder, fall back to PNG
-		return imaging.Encode(file, img, imaging.PNG)
+		return EncodeWebP(file, img, WebPOptions{Quality: quality})
 	default:
 		return fmt.Errorf("unsupported format: %s", format)
 	}
 }
 
+// SaveWebP saves image to file as WebP with opts.
+func (s *Service) SaveWebP(img image.Image, path string, opts WebPOptions) error {
+	file, err := createOutput(path, "webp")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return EncodeWebP(file, img, opts)
+}
+
+// createOutput creates path with its extension replaced by format's, and
+// its directory if missing.
+func createOutput(path string, format string) (*os.File, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+
+	ext := "." + strings.ToLower(format)
+	basePath := strings.TrimSuffix(path, filepath.Ext(path))
+	outputPath := basePath + ext
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	return file, nil
+}
+
 // ResizeToFit resizes image to fit within target dimensions.
 // Maintains aspect ratio, may be smaller than target.
 func (s *Service) ResizeToFit(img image.Image, width, height int) image.Image {
@@ -102,30 +252,13 @@ func (s *Service) GetDimensions(img image.Image) (int, int) {
 	return bounds.Dx(), bounds.Dy()
 }
 
-// CreateBlankCanvas creates blank image with background color.
-func (s *Service) CreateBlankCanvas(width, height int, bgColor string) image.Image {
-	c := parseColor(bgColor)
-	return imaging.New(width, height, c)
-}
-
-// parseColor converts hex color string to color.Color.
-func parseColor(hex string) color.Color {
-	hex = strings.TrimPrefix(hex, "#")
-	if len(hex) != 6 {
-		return color.White
-	}
-
-	r := hexToByte(hex[0:2])
-	g := hexToByte(hex[2:4])
-	b := hexToByte(hex[4:6])
-
-	return color.RGBA{R: r, G: g, B: b, A: 255}
-}
-
-func hexToByte(s string) uint8 {
-	val, err := strconv.ParseUint(s, 16, 8)
+// CreateBlankCanvas creates blank image with background color or gradient.
+func (s *Service) CreateBlankCanvas(width, height int, bgColor string) (image.Image, error) {
+	paint, err := ParsePaint(bgColor)
 	if err != nil {
-		return 0
+		return nil, fmt.Errorf("invalid background: %w", err)
 	}
-	return uint8(val)
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), paint.Image(canvas.Bounds()), image.Point{}, draw.Src)
+	return canvas, nil
 }
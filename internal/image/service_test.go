@@ -1,9 +1,15 @@
 package image
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
 	"image"
 	"image/color"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -108,34 +114,18 @@ func TestResizeToFill(t *testing.T) {
 func TestCreateBlankCanvas(t *testing.T) {
 	svc := NewService()
 
-	canvas := svc.CreateBlankCanvas(150, 150, "#ff0000")
+	canvas, err := svc.CreateBlankCanvas(150, 150, "#ff0000")
+	if err != nil {
+		t.Fatalf("CreateBlankCanvas failed: %v", err)
+	}
 	w, h := svc.GetDimensions(canvas)
 
 	if w != 150 || h != 150 {
 		t.Errorf("Expected 150x150, got %dx%d", w, h)
 	}
-}
 
-func TestParseColor(t *testing.T) {
-	tests := []struct {
-		hex      string
-		expected color.RGBA
-	}{
-		{"#ff0000", color.RGBA{255, 0, 0, 255}},
-		{"ff0000", color.RGBA{255, 0, 0, 255}},
-		{"#00ff00", color.RGBA{0, 255, 0, 255}},
-		{"#0000ff", color.RGBA{0, 0, 255, 255}},
-		{"#ffffff", color.RGBA{255, 255, 255, 255}},
-		{"invalid", color.RGBA{255, 255, 255, 255}}, // fallback to white
-	}
-
-	for _, tc := range tests {
-		result := parseColor(tc.hex)
-		if r, ok := result.(color.RGBA); ok {
-			if r != tc.expected {
-				t.Errorf("parseColor(%s) = %v, expected %v", tc.hex, r, tc.expected)
-			}
-		}
+	if _, err := svc.CreateBlankCanvas(10, 10, "invalid"); err == nil {
+		t.Error("Expected error for invalid background")
 	}
 }
 
@@ -150,3 +140,194 @@ func TestUnsupportedFormat(t *testing.T) {
 		t.Error("Expected error for unsupported format")
 	}
 }
+
+// pngHeader returns the signature and IHDR chunk of a width x height RGBA
+// PNG; nothing after it is needed to read the size.
+func pngHeader(width, height uint32) []byte {
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8-bit RGBA, no interlace
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(ihdr)-4))
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+// jpegHeader returns the start, JFIF marker and baseline SOF0 segment of a
+// width x height YCbCr JPEG. Without the JFIF marker the decoder reads on
+// for an Adobe marker to learn the color model.
+func jpegHeader(width, height uint16) []byte {
+	data := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x10}
+	data = append(data, "JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"...)
+	data = append(data, 0xff, 0xc0, 0x00, 0x11, 8)
+	data = binary.BigEndian.AppendUint16(data, height)
+	data = binary.BigEndian.AppendUint16(data, width)
+	return append(data, 3, 1, 0x22, 0, 2, 0x11, 1, 3, 0x11, 1)
+}
+
+// webpHeader returns a RIFF/WEBP file with the VP8X chunk of an extended
+// WebP, which holds the canvas size minus one as 24-bit values.
+func webpHeader(width, height uint32) []byte {
+	vp8x := []byte("VP8X")
+	vp8x = binary.LittleEndian.AppendUint32(vp8x, 10)
+	vp8x = append(vp8x, 0, 0, 0, 0)
+	for _, size := range []uint32{width - 1, height - 1} {
+		vp8x = append(vp8x, byte(size), byte(size>>8), byte(size>>16))
+	}
+
+	data := []byte("RIFF")
+	data = binary.LittleEndian.AppendUint32(data, uint32(4+len(vp8x)))
+	data = append(data, "WEBP"...)
+	return append(data, vp8x...)
+}
+
+func TestLoadImageRejectsOversizeHeader(t *testing.T) {
+	svc := NewService()
+	budget := NewPixelBudget(0)
+	dir := t.TempDir()
+	headers := map[string][]byte{
+		"bomb.png":  pngHeader(50000, 50000),
+		"bomb.jpg":  jpegHeader(50000, 50000),
+		"bomb.webp": webpHeader(50000, 50000),
+	}
+	for name, data := range headers {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+
+		// Only the header exists, so any decoding attempt would fail differently
+		_, err := svc.LoadImage(path)
+		if !errors.Is(err, ErrImageTooLarge) {
+			t.Errorf("%s: expected ErrImageTooLarge, got %v", name, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "50000x50000") {
+			t.Errorf("%s: expected size in error, got %v", name, err)
+		}
+		if _, _, err := svc.LoadImageContext(context.Background(), path, Limits{}, budget); !errors.Is(err, ErrImageTooLarge) {
+			t.Errorf("%s: expected LoadImageContext to reject, got %v", name, err)
+		}
+	}
+	if inUse, _ := budget.Usage(); inUse != 0 {
+		t.Errorf("Expected rejected images to take no budget, got %d", inUse)
+	}
+}
+
+func TestLoadImageLimits(t *testing.T) {
+	svc := NewService()
+	path := filepath.Join(t.TempDir(), "product.png")
+	if err := svc.SaveImage(image.NewRGBA(image.Rect(0, 0, 300, 200)), path, "png", 0); err != nil {
+		t.Fatalf("SaveImage failed: %v", err)
+	}
+
+	if _, err := svc.LoadImageLimits(path, Limits{MaxWidth: 299}); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Expected width limit to reject, got %v", err)
+	}
+	if _, err := svc.LoadImageLimits(path, Limits{MaxHeight: 100}); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Expected height limit to reject, got %v", err)
+	}
+	if _, err := svc.LoadImageLimits(path, Limits{MaxWidth: 300, MaxHeight: 200}); err != nil {
+		t.Errorf("Expected image at the limits to load, got %v", err)
+	}
+
+	// A request can allow more than the default
+	bigPath := filepath.Join(t.TempDir(), "big.png")
+	if err := os.WriteFile(bigPath, pngHeader(MaxImageWidth+1, 10), 0644); err != nil {
+		t.Fatalf("Failed to write header: %v", err)
+	}
+	if _, err := svc.LoadImageLimits(bigPath, Limits{MaxWidth: MaxImageLimit}); errors.Is(err, ErrImageTooLarge) || err == nil {
+		t.Errorf("Expected raised limit to pass the header check, got %v", err)
+	}
+}
+
+func TestLoadImageDownscale(t *testing.T) {
+	svc := NewService()
+	dir := t.TempDir()
+	jpgPath := filepath.Join(dir, "photo.jpg")
+	if err := svc.SaveImage(testPhoto(400, 300, 0), jpgPath, "jpg", 90); err != nil {
+		t.Fatalf("SaveImage failed: %v", err)
+	}
+	pngPath := filepath.Join(dir, "photo.png")
+	if err := svc.SaveImage(testPhoto(400, 300, 0), pngPath, "png", 0); err != nil {
+		t.Fatalf("SaveImage failed: %v", err)
+	}
+
+	limits := Limits{MaxWidth: 250, MaxHeight: 250}
+	if _, err := svc.LoadImageLimits(jpgPath, limits); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Expected oversize jpeg rejected without Downscale, got %v", err)
+	}
+
+	// Without a target the least reduction that fits keeps the most detail
+	limits.Downscale = true
+	img, err := svc.LoadImageLimits(jpgPath, limits)
+	if err != nil {
+		t.Fatalf("LoadImageLimits failed: %v", err)
+	}
+	if img.Bounds().Dx() != 200 || img.Bounds().Dy() != 150 {
+		t.Errorf("Expected 200x150, got %v", img.Bounds())
+	}
+
+	// With a target the most reduction that still covers it is used
+	limits.TargetWidth, limits.TargetHeight = 90, 70
+	budget := NewPixelBudget(0)
+	img, release, err := svc.LoadImageContext(context.Background(), jpgPath, limits, budget)
+	if err != nil {
+		t.Fatalf("LoadImageContext failed: %v", err)
+	}
+	if img.Bounds().Dx() != 100 || img.Bounds().Dy() != 75 {
+		t.Errorf("Expected 100x75, got %v", img.Bounds())
+	}
+	if inUse, _ := budget.Usage(); inUse != 100*75 {
+		t.Errorf("Expected the reduced size counted in the budget, got %d", inUse)
+	}
+	release()
+
+	// Only baseline JPEGs can be decoded at reduced scale
+	_, err = svc.LoadImageLimits(pngPath, limits)
+	if !errors.Is(err, ErrImageTooLarge) || !strings.Contains(err.Error(), "not PNG") {
+		t.Errorf("Expected oversize png rejected as not a JPEG, got %v", err)
+	}
+
+	// A progressive frame header and start of scan: the size is readable but
+	// the scans are not
+	progressive := []byte{
+		0xff, 0xd8,
+		0xff, 0xc2, 0x00, 0x11, 8, 0x01, 0x2c, 0x01, 0x90, 3, 1, 0x22, 0, 2, 0x11, 1, 3, 0x11, 1,
+		0xff, 0xda, 0x00, 0x08, 1, 1, 0x00, 0, 0x3f, 0,
+	}
+	progPath := filepath.Join(dir, "progressive.jpg")
+	if err := os.WriteFile(progPath, progressive, 0644); err != nil {
+		t.Fatalf("Failed to write header: %v", err)
+	}
+	_, release, err = svc.LoadImageContext(context.Background(), progPath, limits, budget)
+	if !errors.Is(err, ErrImageTooLarge) || !strings.Contains(err.Error(), "400x300") ||
+		!strings.Contains(err.Error(), "progressive JPEGs cannot be downscaled") {
+		t.Errorf("Expected oversize progressive jpeg rejected with the reason, got %v", err)
+	}
+	if release != nil {
+		release()
+	}
+	if inUse, _ := budget.Usage(); inUse != 0 {
+		t.Errorf("Expected the rejected jpeg to return its budget, got %d", inUse)
+	}
+}
+
+func TestJPEGScale(t *testing.T) {
+	tests := []struct {
+		limits   Limits
+		expected int
+	}{
+		{Limits{MaxWidth: 5000, MaxHeight: 5000}, 4},                                        // 12000/2 still too wide
+		{Limits{MaxWidth: 1000, MaxHeight: 1000}, 0},                                        // 1500 at 1/8
+		{Limits{MaxWidth: 8192, MaxHeight: 8192, TargetWidth: 1000, TargetHeight: 1000}, 8}, // 1500x1125 covers
+		{Limits{MaxWidth: 8192, MaxHeight: 8192, TargetWidth: 2000, TargetHeight: 2000}, 4}, // 3000x2250 covers
+		{Limits{MaxWidth: 8192, MaxHeight: 8192, TargetWidth: 8000, TargetHeight: 8000}, 2}, // nothing covers
+	}
+	for _, tt := range tests {
+		if got := tt.limits.jpegScale(12000, 9000); got != tt.expected {
+			t.Errorf("%+v: expected scale %d, got %d", tt.limits, tt.expected, got)
+		}
+	}
+}
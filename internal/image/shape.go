@@ -0,0 +1,77 @@
+// Package image provides shape overlay drawing.
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"strings"
+
+	"golang.org/x/image/vector"
+	"vibe-imageborder/internal/models"
+)
+
+// Shape kinds.
+const (
+	ShapeRect    = "rect"
+	ShapeEllipse = "ellipse"
+)
+
+// ellipseKappa places cubic control points so four curves approximate a circle.
+const ellipseKappa = 0.5522847498
+
+// shapeRect returns the canvas rectangle of a shape overlay.
+// Position is the shape's top-left corner.
+func shapeRect(overlay models.TextOverlay) (image.Rectangle, error) {
+	x, y, err := ParsePosition(overlay.Position)
+	if err != nil {
+		return image.Rectangle{}, fmt.Errorf("invalid position: %w", err)
+	}
+	if overlay.Width <= 0 || overlay.Height <= 0 {
+		return image.Rectangle{}, fmt.Errorf("shape needs a positive width and height")
+	}
+	return image.Rect(x, y, x+overlay.Width, y+overlay.Height), nil
+}
+
+// drawShape fills a rect or ellipse overlay with its paint.
+func drawShape(canvas *image.RGBA, overlay models.TextOverlay) error {
+	rect, err := shapeRect(overlay)
+	if err != nil {
+		return err
+	}
+	paint, err := ParsePaint(overlay.Color)
+	if err != nil {
+		return err
+	}
+	src := paint.Image(rect)
+
+	switch strings.ToLower(overlay.Shape) {
+	case "", ShapeRect:
+		draw.Draw(canvas, rect, src, rect.Min, draw.Over)
+	case ShapeEllipse:
+		mask := ellipseMask(rect.Dx(), rect.Dy())
+		draw.DrawMask(canvas, rect, src, rect.Min, mask, image.Point{}, draw.Over)
+	default:
+		return fmt.Errorf("unknown shape: %s", overlay.Shape)
+	}
+	return nil
+}
+
+// ellipseMask rasterizes an antialiased ellipse filling a w x h box.
+func ellipseMask(w, h int) *image.Alpha {
+	rx, ry := float32(w)/2, float32(h)/2
+	kx, ky := rx*ellipseKappa, ry*ellipseKappa
+
+	var z vector.Rasterizer
+	z.Reset(w, h)
+	z.MoveTo(rx, 0)
+	z.CubeTo(rx+kx, 0, 2*rx, ry-ky, 2*rx, ry)
+	z.CubeTo(2*rx, ry+ky, rx+kx, 2*ry, rx, 2*ry)
+	z.CubeTo(rx-kx, 2*ry, 0, ry+ky, 0, ry)
+	z.CubeTo(0, ry-ky, rx-kx, 0, rx, 0)
+	z.ClosePath()
+
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	return mask
+}
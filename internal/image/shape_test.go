@@ -0,0 +1,112 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"vibe-imageborder/internal/models"
+)
+
+func TestRenderShapes(t *testing.T) {
+	tr := newTestTextRenderer()
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+
+	overlays := map[string]models.TextOverlay{
+		"a_label": {Text: "Sale", Position: "10,10", FontSize: 40, Color: "white"},
+		"z_band":  {Type: models.OverlayShape, Position: "0,0", Width: 100, Height: 100, Color: "linear(90deg,#ff0000,#0000ff)"},
+		"z_dot":   {Type: models.OverlayShape, Shape: ShapeEllipse, Position: "100,0", Width: 100, Height: 100, Color: "#00ff00"},
+	}
+	result, err := tr.RenderOverlays(img, overlays)
+	if err != nil {
+		t.Fatalf("RenderOverlays failed: %v", err)
+	}
+	out := result.Image
+
+	if c := color.RGBAModel.Convert(out.At(1, 99)).(color.RGBA); c.R < 240 || c.B > 10 {
+		t.Errorf("Expected band to start red, got %v", c)
+	}
+	if c := color.RGBAModel.Convert(out.At(98, 99)).(color.RGBA); c.B < 240 || c.R > 10 {
+		t.Errorf("Expected band to end blue, got %v", c)
+	}
+	if c := color.RGBAModel.Convert(out.At(150, 50)).(color.RGBA); c.G != 255 {
+		t.Errorf("Expected ellipse filled at center, got %v", c)
+	}
+	if _, _, _, a := out.At(101, 1).RGBA(); a != 0 {
+		t.Error("Expected ellipse corner left transparent")
+	}
+
+	// Text sorts before the band but is still drawn on top of it
+	white := 0
+	for y := 10; y < 60; y++ {
+		for x := 10; x < 100; x++ {
+			if c := color.RGBAModel.Convert(out.At(x, y)).(color.RGBA); c.R == 255 && c.G == 255 && c.B == 255 {
+				white++
+			}
+		}
+	}
+	if white == 0 {
+		t.Error("Expected text drawn over the shape")
+	}
+}
+
+func TestMeasureShape(t *testing.T) {
+	tr := newTestTextRenderer()
+
+	overlays := map[string]models.TextOverlay{
+		"band": {Type: models.OverlayShape, Position: "20,150", Width: 200, Height: 80, Color: "black"},
+	}
+	boxes, err := tr.Measure(overlays, 200, 200)
+	if err != nil {
+		t.Fatalf("Measure failed: %v", err)
+	}
+	if len(boxes) != 1 {
+		t.Fatalf("Expected 1 box, got %d", len(boxes))
+	}
+	box := boxes[0]
+	if box.X != 20 || box.Y != 150 || box.Width != 200 || box.Height != 80 {
+		t.Errorf("Unexpected box %+v", box)
+	}
+	if !box.Clipped {
+		t.Error("Expected shape past the canvas edge to be clipped")
+	}
+
+	overlays["band"] = models.TextOverlay{Type: models.OverlayShape, Position: "0,0"}
+	if _, err := tr.Measure(overlays, 200, 200); err == nil {
+		t.Error("Expected error for shape without size")
+	}
+}
+
+func TestGradientTextFill(t *testing.T) {
+	tr := newTestTextRenderer()
+	img := image.NewRGBA(image.Rect(0, 0, 400, 100))
+
+	overlays := map[string]models.TextOverlay{
+		"title": {Text: "MMMMMM", Position: "10,10", FontSize: 60, Color: "linear(90deg,#ff0000,#0000ff)"},
+	}
+	result, err := tr.RenderOverlays(img, overlays)
+	if err != nil {
+		t.Fatalf("RenderOverlays failed: %v", err)
+	}
+
+	// Leftmost ink is mostly red, rightmost mostly blue
+	var first, last color.RGBA
+	found := false
+	for x := 0; x < 400; x++ {
+		for y := 0; y < 100; y++ {
+			c := color.RGBAModel.Convert(result.Image.At(x, y)).(color.RGBA)
+			if c.A == 255 {
+				if !found {
+					first, found = c, true
+				}
+				last = c
+			}
+		}
+	}
+	if !found {
+		t.Fatal("Expected text pixels")
+	}
+	if first.R <= first.B || last.B <= last.R {
+		t.Errorf("Expected red to blue fill across the text, got %v ... %v", first, last)
+	}
+}
@@ -0,0 +1,229 @@
+// Package image provides OpenType text shaping and glyph rasterizing.
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/go-text/typesetting/di"
+	tsfont "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/language"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// Text engine names.
+const (
+	// EngineShaped shapes text with HarfBuzz rules: GPOS kerning, GSUB
+	// ligatures and mark positioning for stacked Vietnamese diacritics.
+	EngineShaped = "shaped"
+	// EngineBasic draws glyphs one by one through font.Drawer without shaping.
+	EngineBasic = "basic"
+)
+
+// vietnamese is the default shaping language; it enables the font's
+// Vietnamese-specific diacritic forms.
+var vietnamese = language.NewLanguage("vi")
+
+// shapingFont returns the typesetting font for name, parsing it on first use.
+func (fm *FontManager) shapingFont(name string) (*tsfont.Font, error) {
+	fm.mu.RLock()
+	cached, ok := fm.shapeCache[name]
+	fm.mu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	if _, err := fm.LoadFont(name); err != nil {
+		return nil, err
+	}
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if cached, ok := fm.shapeCache[name]; ok {
+		return cached, nil
+	}
+
+	face, err := tsfont.ParseTTF(bytes.NewReader(fm.data[name]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font %s for shaping: %w", name, err)
+	}
+	fm.shapeCache[name] = face.Font
+	return face.Font, nil
+}
+
+// shapeFace returns the shaping face for chain font i, or nil if the font
+// cannot be shaped and must use the basic engine.
+func (c *fontChain) shapeFace(i int) *tsfont.Face {
+	if c.shapeFaces == nil {
+		c.shapeFaces = make([]*tsfont.Face, len(c.fonts))
+		c.shapeFailed = make([]bool, len(c.fonts))
+	}
+	if c.shapeFaces[i] == nil && !c.shapeFailed[i] {
+		f, err := c.fm.shapingFont(c.names[i])
+		if err != nil {
+			c.shapeFailed[i] = true
+			return nil
+		}
+		c.shapeFaces[i] = tsfont.NewFace(f)
+	}
+	return c.shapeFaces[i]
+}
+
+// shapeRun shapes text with chain font i. Returns false if shaping is unavailable.
+func (c *fontChain) shapeRun(i int, text string) (shaping.Output, bool) {
+	face := c.shapeFace(i)
+	if face == nil {
+		return shaping.Output{}, false
+	}
+
+	runes := []rune(text)
+	input := shaping.Input{
+		Text:      runes,
+		RunStart:  0,
+		RunEnd:    len(runes),
+		Direction: di.DirectionLTR,
+		Face:      face,
+		Size:      floatToFixed(c.size),
+		Script:    runScript(runes),
+		Language:  vietnamese,
+	}
+	return c.shaper.Shape(input), true
+}
+
+// runScript returns the first specific script in runes, defaulting to Latin.
+func runScript(runes []rune) language.Script {
+	for _, r := range runes {
+		script := language.LookupScript(r)
+		if script != language.Common && script != language.Inherited && script != language.Unknown {
+			return script
+		}
+	}
+	return language.Latin
+}
+
+// shapedBounds returns the ink box of shaped glyphs relative to the run origin.
+func shapedBounds(glyphs []shaping.Glyph) (minX, minY, maxX, maxY float64, ok bool) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	var pen float64
+	for _, g := range glyphs {
+		if g.Width != 0 && g.Height != 0 {
+			left := pen + fixedToFloat(g.XOffset+g.XBearing)
+			top := -fixedToFloat(g.YOffset + g.YBearing)
+			minX = math.Min(minX, left)
+			maxX = math.Max(maxX, left+fixedToFloat(g.Width))
+			minY = math.Min(minY, top)
+			maxY = math.Max(maxY, top-fixedToFloat(g.Height))
+		}
+		pen += fixedToFloat(g.XAdvance)
+	}
+	if math.IsInf(minX, 1) {
+		return 0, 0, 0, 0, false
+	}
+	return minX, minY, maxX, maxY, true
+}
+
+// glyphMask rasterizes shaped glyphs drawn at origin (x, y) into an alpha mask,
+// slanting them by shear for synthetic italics.
+// Returns the mask and the canvas rectangle it covers.
+func glyphMask(face *tsfont.Face, size float64, glyphs []shaping.Glyph, x, y, shear float64) (*image.Alpha, image.Rectangle) {
+	minX, minY, maxX, maxY, ok := shapedBounds(glyphs)
+	if !ok {
+		return nil, image.Rectangle{}
+	}
+	if shear != 0 {
+		maxX += shear * math.Max(0, -minY)
+		minX -= shear * math.Max(0, maxY)
+	}
+	rect := image.Rect(
+		int(math.Floor(x+minX))-1, int(math.Floor(y+minY))-1,
+		int(math.Ceil(x+maxX))+1, int(math.Ceil(y+maxY))+1,
+	)
+
+	var z vector.Rasterizer
+	z.Reset(rect.Dx(), rect.Dy())
+	scale := size / float64(face.Upem())
+
+	pen := x - float64(rect.Min.X)
+	baseline := y - float64(rect.Min.Y)
+	for _, g := range glyphs {
+		outline, isOutline := face.GlyphData(g.GlyphID).(tsfont.GlyphOutline)
+		if isOutline {
+			ox := pen + fixedToFloat(g.XOffset)
+			oy := baseline - fixedToFloat(g.YOffset)
+			addOutline(&z, outline, func(p ot.SegmentPoint) (float32, float32) {
+				fx, fy := float64(p.X)*scale, float64(p.Y)*scale
+				return float32(ox + fx + fy*shear), float32(oy - fy)
+			})
+		}
+		pen += fixedToFloat(g.XAdvance)
+	}
+
+	mask := image.NewAlpha(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	return mask, rect
+}
+
+// addOutline feeds glyph outline segments to the rasterizer, mapping font
+// units to pixels with transform.
+func addOutline(z *vector.Rasterizer, outline tsfont.GlyphOutline, transform func(ot.SegmentPoint) (float32, float32)) {
+	open := false
+	for _, seg := range outline.Segments {
+		switch seg.Op {
+		case ot.SegmentOpMoveTo:
+			if open {
+				z.ClosePath()
+			}
+			z.MoveTo(transform(seg.Args[0]))
+			open = true
+		case ot.SegmentOpLineTo:
+			z.LineTo(transform(seg.Args[0]))
+		case ot.SegmentOpQuadTo:
+			bx, by := transform(seg.Args[0])
+			cx, cy := transform(seg.Args[1])
+			z.QuadTo(bx, by, cx, cy)
+		case ot.SegmentOpCubeTo:
+			bx, by := transform(seg.Args[0])
+			cx, cy := transform(seg.Args[1])
+			dx, dy := transform(seg.Args[2])
+			z.CubeTo(bx, by, cx, cy, dx, dy)
+		}
+	}
+	if open {
+		z.ClosePath()
+	}
+}
+
+// emboldenMask widens a glyph mask by taking the max alpha over a
+// horizontal window of strength pixels.
+func emboldenMask(mask *image.Alpha, rect image.Rectangle, strength int) (*image.Alpha, image.Rectangle) {
+	if strength <= 0 {
+		return mask, rect
+	}
+	w, h := rect.Dx(), rect.Dy()
+	bold := image.NewAlpha(image.Rect(0, 0, w+strength, h))
+	for y := 0; y < h; y++ {
+		src := mask.Pix[y*mask.Stride : y*mask.Stride+w]
+		dst := bold.Pix[y*bold.Stride : y*bold.Stride+w+strength]
+		for x, a := range src {
+			if a == 0 {
+				continue
+			}
+			for dx := 0; dx <= strength; dx++ {
+				if dst[x+dx] < a {
+					dst[x+dx] = a
+				}
+			}
+		}
+	}
+	return bold, image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X+strength, rect.Max.Y)
+}
+
+func floatToFixed(v float64) fixed.Int26_6 {
+	return fixed.Int26_6(math.Round(v * 64))
+}
@@ -0,0 +1,93 @@
+package image
+
+import (
+	"image"
+	"testing"
+
+	"vibe-imageborder/internal/models"
+)
+
+func TestSetEngine(t *testing.T) {
+	tr := NewTextRenderer(nil)
+	if tr.Engine() != EngineShaped {
+		t.Errorf("Expected default engine %s, got %s", EngineShaped, tr.Engine())
+	}
+	if err := tr.SetEngine(EngineBasic); err != nil {
+		t.Errorf("SetEngine(basic) failed: %v", err)
+	}
+	if err := tr.SetEngine("fancy"); err == nil {
+		t.Error("Expected error for unknown engine")
+	}
+	if tr.Engine() != EngineBasic {
+		t.Errorf("Engine changed by failed SetEngine: %s", tr.Engine())
+	}
+}
+
+func TestShapedKerning(t *testing.T) {
+	overlay := models.TextOverlay{Text: "AVAVAV", Position: "0,0", FontSize: 100}
+
+	shaped := newTestTextRenderer()
+	layout, err := shaped.layoutOverlay(overlay)
+	if err != nil {
+		t.Fatalf("layoutOverlay failed: %v", err)
+	}
+	defer layout.Close()
+	if layout.lines[0].runs[0].glyphs == nil {
+		t.Fatal("Expected shaped glyphs")
+	}
+
+	basic := newTestTextRenderer()
+	basic.SetEngine(EngineBasic)
+	basicLayout, err := basic.layoutOverlay(overlay)
+	if err != nil {
+		t.Fatalf("layoutOverlay (basic) failed: %v", err)
+	}
+	defer basicLayout.Close()
+	if basicLayout.lines[0].runs[0].glyphs != nil {
+		t.Fatal("Expected basic engine not to shape")
+	}
+
+	if layout.lines[0].advance >= basicLayout.lines[0].advance {
+		t.Errorf("Expected kerning to tighten AV pairs: shaped %.1f, basic %.1f",
+			layout.lines[0].advance, basicLayout.lines[0].advance)
+	}
+}
+
+func TestShapedRenderInsideMeasuredBox(t *testing.T) {
+	tr := newTestTextRenderer()
+	img := image.NewRGBA(image.Rect(0, 0, 300, 120))
+
+	overlays := map[string]models.TextOverlay{
+		"price": {Text: "Phở 500K", Position: "20,20", FontSize: 40, Color: "#ff0000"},
+	}
+	result, err := tr.RenderOverlays(img, overlays)
+	if err != nil {
+		t.Fatalf("RenderOverlays failed: %v", err)
+	}
+	boxes, err := tr.Measure(overlays, 300, 120)
+	if err != nil {
+		t.Fatalf("Measure failed: %v", err)
+	}
+	box := image.Rect(boxes[0].X, boxes[0].Y, boxes[0].X+boxes[0].Width, boxes[0].Y+boxes[0].Height)
+
+	painted := 0
+	out := result.Image
+	for y := 0; y < 120; y++ {
+		for x := 0; x < 300; x++ {
+			r, g, b, a := out.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			painted++
+			if !image.Pt(x, y).In(box.Inset(-1)) {
+				t.Fatalf("Pixel %d,%d drawn outside measured box %v", x, y, box)
+			}
+			if r == 0 || g != 0 || b != 0 {
+				t.Fatalf("Expected red text at %d,%d, got %d,%d,%d", x, y, r, g, b)
+			}
+		}
+	}
+	if painted == 0 {
+		t.Error("Expected text pixels")
+	}
+}
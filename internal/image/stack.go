@@ -0,0 +1,144 @@
+// Package image provides stack group layout.
+package image
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"vibe-imageborder/internal/models"
+)
+
+// Stack directions.
+const (
+	StackVertical   = "vertical"
+	StackHorizontal = "horizontal"
+)
+
+// resolveStacks returns overlays with the children of every stack moved
+// into place, one after another from the stack's position with Gap pixels
+// between their boxes. Children missing from overlays (hidden by
+// ApplyValues) or with empty text take no space. Stacks themselves draw
+// nothing. A stack that cannot be laid out is logged and its children keep
+// their own positions.
+func (tr *TextRenderer) resolveStacks(overlays map[string]models.TextOverlay) map[string]models.TextOverlay {
+	var resolved map[string]models.TextOverlay
+	for _, key := range sortedOverlayKeys(overlays) {
+		stack := overlays[key]
+		if stack.Type != models.OverlayStack {
+			continue
+		}
+		// Copy once so the caller's overlays keep their template positions
+		if resolved == nil {
+			resolved = make(map[string]models.TextOverlay, len(overlays))
+			for k, v := range overlays {
+				resolved[k] = v
+			}
+		}
+		if err := tr.layoutStack(stack, resolved); err != nil {
+			fmt.Printf("Warning: failed to lay out stack %s: %v\n", key, err)
+		}
+	}
+	if resolved == nil {
+		return overlays
+	}
+	return resolved
+}
+
+// layoutStack rewrites the positions of stack's visible children in overlays.
+func (tr *TextRenderer) layoutStack(stack models.TextOverlay, overlays map[string]models.TextOverlay) error {
+	x, y, err := ParsePosition(stack.Position)
+	if err != nil {
+		return fmt.Errorf("invalid position: %w", err)
+	}
+	vertical := true
+	switch strings.ToLower(stack.Direction) {
+	case "", StackVertical:
+	case StackHorizontal:
+		vertical = false
+	default:
+		return fmt.Errorf("unknown direction: %s", stack.Direction)
+	}
+	align := strings.ToLower(stack.Align)
+
+	cursor := y
+	if !vertical {
+		cursor = x
+	}
+	for _, childKey := range stack.Children {
+		child, ok := overlays[childKey]
+		if !ok || !stackable(child) {
+			continue
+		}
+
+		// Measure at the origin to get the box offset from the child's position
+		child.Position = "0,0"
+		box, err := tr.measureOverlay(child, 0, 0)
+		if err != nil {
+			return fmt.Errorf("failed to measure %s: %w", childKey, err)
+		}
+
+		var cx, cy int
+		if vertical {
+			cy = cursor - box.Y
+			switch align {
+			case "center":
+				cx = x - box.X - box.Width/2
+			case "right":
+				cx = x - box.X - box.Width
+			default:
+				cx = x - box.X
+			}
+			cursor += box.Height + stack.Gap
+		} else {
+			cx = cursor - box.X
+			switch align {
+			case "middle", "center":
+				cy = y - box.Y - box.Height/2
+			case "bottom":
+				cy = y - box.Y - box.Height
+			default:
+				cy = y - box.Y
+			}
+			cursor += box.Width + stack.Gap
+		}
+		child.Position = fmt.Sprintf("%d,%d", cx, cy)
+		overlays[childKey] = child
+	}
+	return nil
+}
+
+// stackable reports whether an overlay takes part in stack layout.
+// Hidden text and curved text, which follows its own arc, are skipped.
+func stackable(overlay models.TextOverlay) bool {
+	switch overlay.Type {
+	case models.OverlayStack:
+		return false
+	case "", models.OverlayText:
+		return strings.TrimSpace(overlay.Text) != "" && overlay.Path == ""
+	}
+	return true
+}
+
+// stackBox returns the union of the measured boxes of a stack's children,
+// or false if none are visible.
+func stackBox(stack models.TextOverlay, boxes map[string]models.OverlayBox, width, height int) (models.OverlayBox, bool) {
+	var rect image.Rectangle
+	for _, key := range stack.Children {
+		box, ok := boxes[key]
+		if !ok {
+			continue
+		}
+		rect = rect.Union(image.Rect(box.X, box.Y, box.X+box.Width, box.Y+box.Height))
+	}
+	if rect.Empty() {
+		return models.OverlayBox{}, false
+	}
+	return models.OverlayBox{
+		X:       rect.Min.X,
+		Y:       rect.Min.Y,
+		Width:   rect.Dx(),
+		Height:  rect.Dy(),
+		Clipped: !rect.In(image.Rect(0, 0, width, height)),
+	}, true
+}
@@ -0,0 +1,99 @@
+package image
+
+import (
+	"image"
+	"testing"
+
+	"vibe-imageborder/internal/models"
+)
+
+func stackBoxes(t *testing.T, overlays map[string]models.TextOverlay) map[string]models.OverlayBox {
+	t.Helper()
+	tr := newTestTextRenderer()
+	boxes, err := tr.Measure(overlays, 1000, 1000)
+	if err != nil {
+		t.Fatalf("Measure failed: %v", err)
+	}
+	byKey := make(map[string]models.OverlayBox, len(boxes))
+	for _, box := range boxes {
+		byKey[box.Key] = box
+	}
+	return byKey
+}
+
+func TestStackVertical(t *testing.T) {
+	overlays := map[string]models.TextOverlay{
+		"info":  {Type: models.OverlayStack, Position: "100,200", Gap: 10, Children: []string{"name", "promo", "price"}},
+		"name":  {Text: "Ghế gỗ", Position: "0,0", FontSize: 40},
+		"promo": {Text: "Giảm 20%", Position: "0,0", FontSize: 30},
+		"price": {Text: "1.200K", Position: "0,0", FontSize: 50},
+	}
+	boxes := stackBoxes(t, overlays)
+
+	name, promo, price := boxes["name"], boxes["promo"], boxes["price"]
+	if name.X != 100 || name.Y != 200 {
+		t.Errorf("Expected first child at the stack origin, got %+v", name)
+	}
+	if promo.Y != name.Y+name.Height+10 || price.Y != promo.Y+promo.Height+10 {
+		t.Errorf("Expected children 10px apart, got %+v %+v %+v", name, promo, price)
+	}
+	if promo.X != 100 || price.X != 100 {
+		t.Errorf("Expected left aligned children, got x=%d and x=%d", promo.X, price.X)
+	}
+
+	stack := boxes["info"]
+	if stack.X != 100 || stack.Y != 200 || stack.Y+stack.Height != price.Y+price.Height {
+		t.Errorf("Expected stack box to cover its children, got %+v", stack)
+	}
+
+	// A hidden child collapses: price moves up into its place
+	delete(overlays, "promo")
+	boxes = stackBoxes(t, overlays)
+	if got := boxes["price"].Y; got != name.Y+name.Height+10 {
+		t.Errorf("Expected price to move up to %d, got %d", name.Y+name.Height+10, got)
+	}
+}
+
+func TestStackHorizontalAlign(t *testing.T) {
+	overlays := map[string]models.TextOverlay{
+		"badges": {Type: models.OverlayStack, Direction: StackHorizontal, Align: "middle", Position: "50,300", Gap: 20, Children: []string{"big", "dot", "small"}},
+		"big":    {Text: "Mới", Position: "0,0", FontSize: 60},
+		"dot":    {Type: models.OverlayShape, Position: "0,0", Width: 16, Height: 16, Color: "red"},
+		"small":  {Text: "", Position: "0,0", FontSize: 20},
+	}
+	boxes := stackBoxes(t, overlays)
+
+	big, dot := boxes["big"], boxes["dot"]
+	if big.X != 50 || dot.X != big.X+big.Width+20 {
+		t.Errorf("Expected children laid out left to right, got %+v %+v", big, dot)
+	}
+	for _, box := range []models.OverlayBox{big, dot} {
+		if mid := box.Y + box.Height/2; mid < 299 || mid > 301 {
+			t.Errorf("Expected %s centered on y=300, got middle %d", box.Key, mid)
+		}
+	}
+	stack := boxes["badges"]
+	if stack.X+stack.Width != dot.X+dot.Width {
+		t.Errorf("Expected empty child to take no space, stack %+v", stack)
+	}
+}
+
+func TestStackRenderKeepsTemplatePositions(t *testing.T) {
+	tr := newTestTextRenderer()
+	overlays := map[string]models.TextOverlay{
+		"info": {Type: models.OverlayStack, Position: "10,10", Children: []string{"name"}},
+		"name": {Text: "Ghế", Position: "500,500", FontSize: 30},
+	}
+	if _, err := tr.RenderOverlays(image.NewRGBA(image.Rect(0, 0, 200, 100)), overlays); err != nil {
+		t.Fatalf("RenderOverlays failed: %v", err)
+	}
+	if overlays["name"].Position != "500,500" {
+		t.Errorf("Expected caller overlays untouched, got %s", overlays["name"].Position)
+	}
+
+	overlays["info"] = models.TextOverlay{Type: models.OverlayStack, Position: "10,10", Direction: "diagonal", Children: []string{"name"}}
+	boxes := stackBoxes(t, overlays)
+	if boxes["name"].X < 500 {
+		t.Errorf("Expected invalid stack to leave children in place, got %+v", boxes["name"])
+	}
+}
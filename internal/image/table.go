@@ -0,0 +1,219 @@
+// Package image provides table overlay layout and drawing.
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"strings"
+
+	"vibe-imageborder/internal/models"
+)
+
+// Table geometry relative to the font size.
+const (
+	rowHeightRatio   = 1.6  // default row height
+	cellPaddingRatio = 0.3  // horizontal space between cell edge and text
+	cellCapRatio     = 0.35 // half the cap height, used to center text in a row
+)
+
+// tableGrid is the cell geometry of a table overlay.
+type tableGrid struct {
+	x, y      int
+	widths    []int
+	rowHeight int
+	fontSize  float64
+	rows      [][]string
+}
+
+// newTableGrid computes a table's cells. Position is the top-left corner;
+// rows with more cells than column widths reuse the last width.
+func newTableGrid(overlay models.TextOverlay) (*tableGrid, error) {
+	x, y, err := ParsePosition(overlay.Position)
+	if err != nil {
+		return nil, fmt.Errorf("invalid position: %w", err)
+	}
+	if len(overlay.Columns) == 0 {
+		return nil, fmt.Errorf("table needs column widths")
+	}
+
+	grid := &tableGrid{x: x, y: y, rows: overlay.Rows, fontSize: float64(overlay.FontSize)}
+	if grid.fontSize <= 0 {
+		grid.fontSize = defaultOverlayFontSize
+	}
+	grid.rowHeight = overlay.RowHeight
+	if grid.rowHeight <= 0 {
+		grid.rowHeight = int(math.Round(grid.fontSize * rowHeightRatio))
+	}
+
+	cols := len(overlay.Columns)
+	for _, row := range overlay.Rows {
+		cols = max(cols, len(row))
+	}
+	for i := 0; i < cols; i++ {
+		width := overlay.Columns[min(i, len(overlay.Columns)-1)]
+		if width <= 0 {
+			return nil, fmt.Errorf("invalid column width %d", width)
+		}
+		grid.widths = append(grid.widths, width)
+	}
+	return grid, nil
+}
+
+// bounds returns the rectangle covering every row and column.
+func (g *tableGrid) bounds() image.Rectangle {
+	width := 0
+	for _, w := range g.widths {
+		width += w
+	}
+	return image.Rect(g.x, g.y, g.x+width, g.y+len(g.rows)*g.rowHeight)
+}
+
+// cell returns the rectangle of the cell at row r, column c.
+func (g *tableGrid) cell(r, c int) image.Rectangle {
+	left := g.x
+	for _, w := range g.widths[:c] {
+		left += w
+	}
+	top := g.y + r*g.rowHeight
+	return image.Rect(left, top, left+g.widths[c], top+g.rowHeight)
+}
+
+// cellOverlay returns the text overlay for one cell, inheriting the table's
+// font and colors and aligned within the cell.
+func (g *tableGrid) cellOverlay(table models.TextOverlay, r, c int) models.TextOverlay {
+	cell := g.cell(r, c)
+	align := columnAlign(table.Align, c)
+	pad := int(math.Round(g.fontSize * cellPaddingRatio))
+
+	x := cell.Min.X + pad
+	switch align {
+	case "center":
+		x = (cell.Min.X + cell.Max.X) / 2
+	case "right":
+		x = cell.Max.X - pad
+	}
+	// Position is the top of the text; center the cap height in the row
+	y := int(math.Round(float64(cell.Min.Y+cell.Max.Y)/2 - g.fontSize*(1-cellCapRatio)))
+
+	return models.TextOverlay{
+		Text:        g.rows[r][c],
+		Position:    fmt.Sprintf("%d,%d", x, y),
+		FontSize:    int(g.fontSize),
+		Color:       table.Color,
+		LightColor:  table.LightColor,
+		DarkColor:   table.DarkColor,
+		Stroke:      table.Stroke,
+		StrokeWidth: table.StrokeWidth,
+		Align:       align,
+	}
+}
+
+// columnAlign returns the alignment of column c from a list like
+// "left,right"; the last entry applies to any further columns.
+func columnAlign(align string, c int) string {
+	parts := strings.Split(align, ",")
+	return strings.ToLower(strings.TrimSpace(parts[min(c, len(parts)-1)]))
+}
+
+// drawTable draws zebra stripes, cell text and grid lines, and returns
+// characters no font could draw and, for an auto color, the color chosen
+// for each cell by cellKey.
+func (tr *TextRenderer) drawTable(canvas *image.RGBA, overlay models.TextOverlay) (string, map[string]string, error) {
+	grid, err := newTableGrid(overlay)
+	if err != nil {
+		return "", nil, err
+	}
+	bounds := grid.bounds()
+
+	if overlay.Zebra != "" {
+		paint, err := ParsePaint(overlay.Zebra)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid zebra color: %w", err)
+		}
+		for r := 1; r < len(grid.rows); r += 2 {
+			row := image.Rect(bounds.Min.X, grid.y+r*grid.rowHeight, bounds.Max.X, grid.y+(r+1)*grid.rowHeight)
+			draw.Draw(canvas, row, paint.Image(row), row.Min, draw.Over)
+		}
+	}
+
+	var missing []rune
+	var autos map[string]string
+	seen := make(map[rune]bool)
+	for r, row := range grid.rows {
+		for c := range row {
+			if strings.TrimSpace(row[c]) == "" {
+				continue
+			}
+			cellMissing, auto, err := tr.drawSingleOverlay(canvas, grid.cellOverlay(overlay, r, c))
+			if err != nil {
+				return "", nil, err
+			}
+			if auto != "" {
+				if autos == nil {
+					autos = make(map[string]string)
+				}
+				autos[cellKey(r, c)] = auto
+			}
+			for _, m := range cellMissing {
+				if !seen[m] {
+					seen[m] = true
+					missing = append(missing, m)
+				}
+			}
+		}
+	}
+
+	if overlay.Border != "" {
+		paint, err := ParsePaint(overlay.Border)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid border color: %w", err)
+		}
+		src := paint.Image(bounds)
+		thickness := int(math.Max(1, math.Round(grid.fontSize/decorationRatio)))
+		for r := 0; r <= len(grid.rows); r++ {
+			y := grid.y + r*grid.rowHeight - thickness/2
+			line := image.Rect(bounds.Min.X, y, bounds.Max.X, y+thickness)
+			draw.Draw(canvas, line, src, line.Min, draw.Over)
+		}
+		x := grid.x
+		for c := 0; c <= len(grid.widths); c++ {
+			line := image.Rect(x-thickness/2, bounds.Min.Y, x-thickness/2+thickness, bounds.Max.Y)
+			draw.Draw(canvas, line, src, line.Min, draw.Over)
+			if c < len(grid.widths) {
+				x += grid.widths[c]
+			}
+		}
+	}
+
+	return string(missing), autos, nil
+}
+
+// cellKey names the cell at row r, column c, as in "[0,1]", appended to the
+// table's overlay key in TextResult.AutoColors.
+func cellKey(r, c int) string {
+	return fmt.Sprintf("[%d,%d]", r, c)
+}
+
+// measureTable returns the grid box of a table; Lines holds one entry per
+// row with cells separated by " | ".
+func (tr *TextRenderer) measureTable(overlay models.TextOverlay, width, height int) (models.OverlayBox, error) {
+	grid, err := newTableGrid(overlay)
+	if err != nil {
+		return models.OverlayBox{}, err
+	}
+	rect := grid.bounds()
+	box := models.OverlayBox{
+		X:        rect.Min.X,
+		Y:        rect.Min.Y,
+		Width:    rect.Dx(),
+		Height:   rect.Dy(),
+		FontSize: grid.fontSize,
+		Clipped:  !rect.In(image.Rect(0, 0, width, height)),
+	}
+	for _, row := range grid.rows {
+		box.Lines = append(box.Lines, StripMarkup(strings.Join(row, " | ")))
+	}
+	return box, nil
+}
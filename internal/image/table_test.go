@@ -0,0 +1,101 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"vibe-imageborder/internal/models"
+)
+
+func testTable() models.TextOverlay {
+	return models.TextOverlay{
+		Type:     models.OverlayTable,
+		Position: "10,20",
+		FontSize: 20,
+		Color:    "black",
+		Columns:  []int{100, 150},
+		Align:    "left,right",
+		Border:   "#ff0000",
+		Zebra:    "#0000ff",
+		Rows:     [][]string{{"Dài", "120 cm"}, {"Rộng", "60 cm"}, {"Cao", "75 cm", "extra"}},
+	}
+}
+
+func TestTableGrid(t *testing.T) {
+	grid, err := newTableGrid(testTable())
+	if err != nil {
+		t.Fatalf("newTableGrid failed: %v", err)
+	}
+	if grid.rowHeight != 32 {
+		t.Errorf("Expected default row height 32, got %d", grid.rowHeight)
+	}
+	// The third cell in the last row reuses the last column width
+	if len(grid.widths) != 3 || grid.widths[2] != 150 {
+		t.Errorf("Unexpected column widths %v", grid.widths)
+	}
+	if got := grid.bounds(); got != image.Rect(10, 20, 410, 116) {
+		t.Errorf("Unexpected bounds %v", got)
+	}
+	if got := grid.cell(1, 1); got != image.Rect(110, 52, 260, 84) {
+		t.Errorf("Unexpected cell %v", got)
+	}
+
+	if columnAlign("left,right", 0) != "left" || columnAlign("left, right", 5) != "right" {
+		t.Error("Expected per-column alignment with the last entry repeated")
+	}
+
+	bad := testTable()
+	bad.Columns = nil
+	if _, err := newTableGrid(bad); err == nil {
+		t.Error("Expected error for table without column widths")
+	}
+}
+
+func TestRenderTable(t *testing.T) {
+	tr := newTestTextRenderer()
+	img := image.NewRGBA(image.Rect(0, 0, 450, 150))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	overlays := map[string]models.TextOverlay{"specs": testTable()}
+	result, err := tr.RenderOverlays(img, overlays)
+	if err != nil {
+		t.Fatalf("RenderOverlays failed: %v", err)
+	}
+	out := result.Image
+
+	at := func(x, y int) color.RGBA {
+		return color.RGBAModel.Convert(out.At(x, y)).(color.RGBA)
+	}
+	if c := at(60, 20); c != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("Expected top border, got %v", c)
+	}
+	if c := at(105, 60); c != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("Expected zebra stripe on the second row, got %v", c)
+	}
+	if c := at(105, 30); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("Expected first row unstriped, got %v", c)
+	}
+
+	boxes, err := tr.Measure(overlays, 450, 150)
+	if err != nil {
+		t.Fatalf("Measure failed: %v", err)
+	}
+	if len(boxes) != 1 || boxes[0].Width != 400 || boxes[0].Height != 96 {
+		t.Fatalf("Unexpected table box %+v", boxes)
+	}
+	if boxes[0].Lines[0] != "Dài | 120 cm" {
+		t.Errorf("Unexpected row text %q", boxes[0].Lines[0])
+	}
+
+	// Right-aligned value text ends near the right edge of its column
+	grid, _ := newTableGrid(testTable())
+	cell, err := tr.measureOverlay(grid.cellOverlay(testTable(), 0, 1), 450, 150)
+	if err != nil {
+		t.Fatalf("measureOverlay failed: %v", err)
+	}
+	if end := cell.X + cell.Width; end < 250 || end > 255 {
+		t.Errorf("Expected value right-aligned near x=254, ends at %d", end)
+	}
+}
@@ -4,88 +4,310 @@ package image
 import (
 	"fmt"
 	"image"
-	"image/color"
+	"image/draw"
+	"math"
 	"strconv"
 	"strings"
 
-	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
 	"vibe-imageborder/internal/models"
 )
 
-// namedColors maps color names to color values.
-var namedColors = map[string]color.Color{
-	"white":   color.White,
-	"black":   color.Black,
-	"red":     color.RGBA{255, 0, 0, 255},
-	"green":   color.RGBA{0, 255, 0, 255},
-	"blue":    color.RGBA{0, 0, 255, 255},
-	"yellow":  color.RGBA{255, 255, 0, 255},
-	"cyan":    color.RGBA{0, 255, 255, 255},
-	"magenta": color.RGBA{255, 0, 255, 255},
-	"gray":    color.RGBA{128, 128, 128, 255},
-	"grey":    color.RGBA{128, 128, 128, 255},
-}
+// strokeWidthRatio is font size / default stroke width.
+const strokeWidthRatio = 20.0
 
 // TextRenderer handles text drawing.
 type TextRenderer struct {
 	fontManager *FontManager
+	engine      string
 }
 
-// NewTextRenderer creates new text renderer.
+// NewTextRenderer creates new text renderer using the shaping engine.
 func NewTextRenderer(fm *FontManager) *TextRenderer {
-	return &TextRenderer{fontManager: fm}
+	return &TextRenderer{fontManager: fm, engine: EngineShaped}
+}
+
+// SetEngine selects the text engine: EngineShaped (default) or EngineBasic.
+func (tr *TextRenderer) SetEngine(engine string) error {
+	switch engine {
+	case EngineShaped, EngineBasic:
+		tr.engine = engine
+		return nil
+	default:
+		return fmt.Errorf("unknown text engine: %s", engine)
+	}
+}
+
+// Engine returns the selected text engine.
+func (tr *TextRenderer) Engine() string {
+	return tr.engine
+}
+
+// TextResult holds an image with overlays drawn and any rendering warnings.
+type TextResult struct {
+	Image      image.Image
+	Warnings   []string
+	AutoColors map[string]string // overlay key, or table key and cell as in "specs[0,1]", -> color chosen for ColorAuto
 }
 
 // DrawOverlays draws all text overlays on image.
 func (tr *TextRenderer) DrawOverlays(img image.Image, overlays map[string]models.TextOverlay) (image.Image, error) {
+	result, err := tr.RenderOverlays(img, overlays)
+	if err != nil {
+		return nil, err
+	}
+	return result.Image, nil
+}
+
+// RenderOverlays draws all overlays on image and reports characters
+// that no font in the fallback chain could draw.
+// Shape overlays are drawn first so text always sits on top of them.
+func (tr *TextRenderer) RenderOverlays(img image.Image, overlays map[string]models.TextOverlay) (*TextResult, error) {
 	bounds := img.Bounds()
-	dc := gg.NewContext(bounds.Dx(), bounds.Dy())
-	dc.DrawImage(img, 0, 0)
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), img, bounds.Min, draw.Src)
+
+	overlays = tr.resolveStacks(overlays)
 
-	for _, overlay := range overlays {
-		if err := tr.drawSingleOverlay(dc, overlay); err != nil {
+	result := &TextResult{}
+	keys := sortedOverlayKeys(overlays)
+	for _, key := range keys {
+		if overlays[key].Type != models.OverlayShape {
+			continue
+		}
+		if err := drawShape(canvas, overlays[key]); err != nil {
+			fmt.Printf("Warning: failed to draw shape %s: %v\n", key, err)
+		}
+	}
+	for _, key := range keys {
+		if overlays[key].Type == models.OverlayShape || overlays[key].Type == models.OverlayStack {
+			continue
+		}
+		var missing string
+		var autos map[string]string // cell key, or "" for the overlay, -> color
+		var err error
+		if overlays[key].Type == models.OverlayTable {
+			missing, autos, err = tr.drawTable(canvas, overlays[key])
+		} else {
+			var auto string
+			missing, auto, err = tr.drawSingleOverlay(canvas, overlays[key])
+			if auto != "" {
+				autos = map[string]string{"": auto}
+			}
+		}
+		if err != nil {
 			// Log error but continue with other overlays
 			fmt.Printf("Warning: failed to draw overlay: %v\n", err)
 			continue
 		}
+		for cell, auto := range autos {
+			if result.AutoColors == nil {
+				result.AutoColors = make(map[string]string)
+			}
+			result.AutoColors[key+cell] = auto
+		}
+		if missing != "" {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: no font can draw %q", key, missing))
+		}
 	}
 
-	return dc.Image(), nil
+	result.Image = canvas
+	return result, nil
 }
 
-// drawSingleOverlay draws one text overlay.
-func (tr *TextRenderer) drawSingleOverlay(dc *gg.Context, overlay models.TextOverlay) error {
+// drawSingleOverlay draws one text overlay and returns characters it could not draw.
+// For ColorAuto it also returns the color picked from the pixels under the text box.
+// Gradient fills span the overlay's whole text box, whichever span uses them.
+func (tr *TextRenderer) drawSingleOverlay(canvas *image.RGBA, overlay models.TextOverlay) (string, string, error) {
 	// Skip empty text
 	if strings.TrimSpace(overlay.Text) == "" {
-		return nil
+		return "", "", nil
 	}
 
-	// Parse position
-	x, y, err := ParsePosition(overlay.Position)
+	layout, err := tr.layoutOverlay(overlay)
 	if err != nil {
-		return fmt.Errorf("invalid position: %w", err)
+		return "", "", err
 	}
+	defer layout.Close()
 
-	// Parse color
-	c := ParseColorName(overlay.Color)
+	minX, minY, maxX, maxY := layout.bounds()
+	box := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
 
-	// Load font
-	fontSize := float64(overlay.FontSize)
-	if fontSize <= 0 {
-		fontSize = 40 // default
+	overlayColor, auto := overlay.Color, ""
+	if IsAutoColor(overlayColor) {
+		// Sample before drawing so the text does not see itself
+		auto, err = autoColor(canvas, box, overlay)
+		if err != nil {
+			return "", "", err
+		}
+		overlayColor = auto
 	}
 
-	face, err := tr.fontManager.GetFace(DefaultFontName(), fontSize)
-	if err != nil {
-		return fmt.Errorf("failed to load font: %w", err)
+	var masks []maskedRun
+	for _, line := range layout.lines {
+		for _, run := range line.runs {
+			mask, rect := layout.runMask(line, run)
+			if mask == nil {
+				continue
+			}
+			fill := overlayColor
+			if run.style.color != "" {
+				fill = run.style.color
+			}
+			masks = append(masks, maskedRun{mask: mask, rect: rect, fill: fill})
+		}
+	}
+
+	// Stroke every run before filling any, so no stroke covers a neighbour's fill
+	if layout.stroke > 0 {
+		paint, err := ParsePaint(overlay.Stroke)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid stroke: %w", err)
+		}
+		src := paint.Image(box)
+		for _, m := range masks {
+			mask, rect := strokeMask(m.mask, m.rect, layout.stroke)
+			draw.DrawMask(canvas, rect, src, rect.Min, mask, image.Point{}, draw.Over)
+		}
+	}
+
+	sources := make(map[string]image.Image)
+	for _, m := range masks {
+		src, ok := sources[m.fill]
+		if !ok {
+			paint, err := ParsePaint(m.fill)
+			if err != nil {
+				return "", "", err
+			}
+			src = paint.Image(box)
+			sources[m.fill] = src
+		}
+		draw.DrawMask(canvas, m.rect, src, m.rect.Min, m.mask, image.Point{}, draw.Over)
+	}
+
+	return layout.missingGlyphs(), auto, nil
+}
+
+// maskedRun is a run's coverage on the canvas and the fill it is drawn with.
+type maskedRun struct {
+	mask *image.Alpha
+	rect image.Rectangle
+	fill string
+}
+
+// strokeWidth returns the overlay's outline width in pixels, 0 without a stroke.
+func strokeWidth(overlay models.TextOverlay, fontSize float64) int {
+	if strings.TrimSpace(overlay.Stroke) == "" {
+		return 0
+	}
+	if overlay.StrokeWidth > 0 {
+		return overlay.StrokeWidth
+	}
+	return int(math.Max(1, math.Round(fontSize/strokeWidthRatio)))
+}
+
+// runMask returns the coverage of one run of a line, glyphs and decorations,
+// and the canvas rectangle it covers.
+func (l *textLayout) runMask(line textLine, run textRun) (*image.Alpha, image.Rectangle) {
+	if l.path != nil {
+		return l.path.runMask(run, line.x+run.x)
+	}
+	return runMask(run, line.x+run.x, line.y)
+}
+
+// runMask rasterizes one styled run with its origin at (x, y).
+// Synthetic bold smears the glyph mask horizontally by the run's bold strength.
+func runMask(run textRun, x, y float64) (*image.Alpha, image.Rectangle) {
+	var mask *image.Alpha
+	var rect image.Rectangle
+	if run.glyphs != nil {
+		mask, rect = glyphMask(run.shapeFace, run.size, run.glyphs, x, y, run.shear())
+	} else {
+		mask, rect = basicMask(run, x, y)
+	}
+	if mask != nil && run.style.bold {
+		mask, rect = emboldenMask(mask, rect, int(math.Round(run.boldStrength())))
+	}
+
+	decorations := run.decorations()
+	if len(decorations) == 0 {
+		return mask, rect
+	}
+	rects := make([]image.Rectangle, len(decorations))
+	union := rect
+	for i, deco := range decorations {
+		rects[i] = image.Rect(
+			int(math.Round(x+deco[0])), int(math.Round(y+deco[1])),
+			int(math.Round(x+deco[2])), int(math.Round(y+deco[3])),
+		)
+		union = union.Union(rects[i])
+	}
+	if union.Empty() {
+		return mask, rect
+	}
+	combined := image.NewAlpha(image.Rect(0, 0, union.Dx(), union.Dy()))
+	if mask != nil {
+		draw.Draw(combined, rect.Sub(union.Min), mask, image.Point{}, draw.Src)
+	}
+	for _, r := range rects {
+		draw.Draw(combined, r.Sub(union.Min), image.Opaque, image.Point{}, draw.Src)
 	}
-	defer face.Close()
+	return combined, union
+}
 
-	dc.SetFontFace(face)
-	dc.SetColor(c)
-	dc.DrawString(overlay.Text, float64(x), float64(y)+fontSize) // gg uses baseline
+// strokeMask grows a run mask by width pixels in every direction: each
+// pixel takes the highest alpha within width of it.
+func strokeMask(mask *image.Alpha, rect image.Rectangle, width int) (*image.Alpha, image.Rectangle) {
+	var disc []int
+	w, h := rect.Dx(), rect.Dy()
+	out := image.NewAlpha(image.Rect(0, 0, w+2*width, h+2*width))
+	for dy := -width; dy <= width; dy++ {
+		for dx := -width; dx <= width; dx++ {
+			if dx*dx+dy*dy <= width*width+width {
+				disc = append(disc, dy*out.Stride+dx)
+			}
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		row := mask.Pix[y*mask.Stride : y*mask.Stride+w]
+		center := (y+width)*out.Stride + width
+		for x, a := range row {
+			if a == 0 {
+				continue
+			}
+			for _, d := range disc {
+				if i := center + x + d; out.Pix[i] < a {
+					out.Pix[i] = a
+				}
+			}
+		}
+	}
+	return out, rect.Inset(-width)
+}
+
+// basicMask rasterizes an unshaped run drawn at origin (x, y) into an alpha mask.
+// Returns the mask and the canvas rectangle it covers.
+func basicMask(run textRun, x, y float64) (*image.Alpha, image.Rectangle) {
+	minX, minY, maxX, maxY, ok := run.inkBounds()
+	if !ok {
+		return nil, image.Rectangle{}
+	}
+	rect := image.Rect(
+		int(math.Floor(x+minX))-1, int(math.Floor(y+minY))-1,
+		int(math.Ceil(x+maxX))+1, int(math.Ceil(y+maxY))+1,
+	)
 
-	return nil
+	mask := image.NewAlpha(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	d := font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: run.face,
+		Dot:  fixed.Point26_6{X: floatToFixed(x - float64(rect.Min.X)), Y: floatToFixed(y - float64(rect.Min.Y))},
+	}
+	d.DrawString(run.text)
+	return mask, rect
 }
 
 // ParsePosition parses "x,y" string to coordinates.
@@ -107,25 +329,3 @@ func ParsePosition(pos string) (int, int, error) {
 
 	return x, y, nil
 }
-
-// ParseColorName converts color name or hex to color.Color.
-func ParseColorName(name string) color.Color {
-	name = strings.ToLower(strings.TrimSpace(name))
-
-	if c, ok := namedColors[name]; ok {
-		return c
-	}
-
-	// Try hex color
-	if strings.HasPrefix(name, "#") {
-		hex := strings.TrimPrefix(name, "#")
-		if len(hex) == 6 {
-			r := hexToByte(hex[0:2])
-			g := hexToByte(hex[2:4])
-			b := hexToByte(hex[4:6])
-			return color.RGBA{R: r, G: g, B: b, A: 255}
-		}
-	}
-
-	return color.White // default
-}
@@ -1,8 +1,10 @@
 package image
 
 import (
-	"image/color"
+	"image"
 	"testing"
+
+	"vibe-imageborder/internal/models"
 )
 
 func TestParsePosition(t *testing.T) {
@@ -34,53 +36,6 @@ func TestParsePosition(t *testing.T) {
 	}
 }
 
-func TestParseColorName(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected color.RGBA
-	}{
-		{"white", color.RGBA{255, 255, 255, 255}},
-		{"WHITE", color.RGBA{255, 255, 255, 255}},
-		{"black", color.RGBA{0, 0, 0, 255}},
-		{"red", color.RGBA{255, 0, 0, 255}},
-		{"green", color.RGBA{0, 255, 0, 255}},
-		{"blue", color.RGBA{0, 0, 255, 255}},
-		{"#ff0000", color.RGBA{255, 0, 0, 255}},
-		{"#00ff00", color.RGBA{0, 255, 0, 255}},
-		{"#0000ff", color.RGBA{0, 0, 255, 255}},
-		{"unknown", color.RGBA{255, 255, 255, 255}}, // fallback to white
-	}
-
-	for _, tt := range tests {
-		result := ParseColorName(tt.input)
-		r1, g1, b1, a1 := result.RGBA()
-		r2, g2, b2, a2 := tt.expected.RGBA()
-		if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
-			t.Errorf("For %s: expected %v got %v", tt.input, tt.expected, result)
-		}
-	}
-}
-
-func TestParseColorNameHex(t *testing.T) {
-	tests := []struct {
-		hex      string
-		expected color.RGBA
-	}{
-		{"#ffffff", color.RGBA{255, 255, 255, 255}},
-		{"#000000", color.RGBA{0, 0, 0, 255}},
-		{"#f1eeea", color.RGBA{241, 238, 234, 255}},
-	}
-
-	for _, tt := range tests {
-		result := ParseColorName(tt.hex)
-		if rgba, ok := result.(color.RGBA); ok {
-			if rgba != tt.expected {
-				t.Errorf("For %s: expected %v got %v", tt.hex, tt.expected, rgba)
-			}
-		}
-	}
-}
-
 func TestNewTextRenderer(t *testing.T) {
 	// Test that TextRenderer can be created without FontManager
 	// (will fail on DrawOverlays without proper fonts)
@@ -96,3 +51,43 @@ func TestDefaultFontName(t *testing.T) {
 		t.Errorf("Expected BeVietnamPro-Regular, got %s", name)
 	}
 }
+
+func TestDrawStroke(t *testing.T) {
+	tr := newTestTextRenderer()
+	plain := models.TextOverlay{Text: "<u>Giá</u> 500K", Position: "20,20", FontSize: 40, Color: "#000000"}
+	stroked := plain
+	stroked.Stroke = "#ff0000" // default width 40/20 = 2px
+
+	boxes, err := tr.Measure(map[string]models.TextOverlay{"plain": plain, "stroked": stroked}, 300, 100)
+	if err != nil {
+		t.Fatalf("Measure failed: %v", err)
+	}
+	if got, want := boxes[1], boxes[0]; got.X != want.X-2 || got.Width != want.Width+4 || got.Height != want.Height+4 {
+		t.Errorf("Expected the stroke to grow the box by 2px per side, got %+v from %+v", got, want)
+	}
+
+	result, err := tr.RenderOverlays(image.NewRGBA(image.Rect(0, 0, 300, 100)), map[string]models.TextOverlay{"stroked": stroked})
+	if err != nil {
+		t.Fatalf("RenderOverlays failed: %v", err)
+	}
+	canvas := result.Image.(*image.RGBA)
+	var red, black int
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			switch c := canvas.RGBAAt(x, y); {
+			case c.A == 255 && c.R == 255:
+				red++
+			case c.A == 255 && c.R == 0:
+				black++
+			}
+		}
+	}
+	if red == 0 || black == 0 {
+		t.Errorf("Expected a red stroke around black text, got %d red and %d black pixels", red, black)
+	}
+
+	stroked.Stroke = "not-a-color"
+	if _, _, err := tr.drawSingleOverlay(image.NewRGBA(image.Rect(0, 0, 300, 100)), stroked); err == nil {
+		t.Error("Expected error for an invalid stroke")
+	}
+}
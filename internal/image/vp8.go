@@ -0,0 +1,1100 @@
+// Package image provides a lossy WebP (VP8) encoder.
+package image
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// Limits of the VP8 format and of this encoder.
+const (
+	vp8MaxSize           = 1<<14 - 1
+	vp8MaxLevel          = 2047
+	vp8MaxFirstPartition = 1 << 19
+	vp8MaxPartition      = 1 << 24
+	vp8PartitionPixels   = 1 << 22 // images over this many pixels spread tokens over 8 partitions
+)
+
+// Intra prediction modes, shared by 16x16 luma and 8x8 chroma blocks.
+const (
+	vp8PredDC = iota
+	vp8PredTM
+	vp8PredVE
+	vp8PredHE
+	vp8Modes
+)
+
+// Token probability planes.
+const (
+	vp8PlaneY1WithY2 = iota
+	vp8PlaneY2
+	vp8PlaneUV
+)
+
+var (
+	vp8Bands   = [17]uint8{0, 1, 2, 3, 6, 4, 5, 6, 6, 6, 6, 6, 6, 6, 6, 7, 0}
+	vp8Zigzag  = [16]uint8{0, 1, 4, 8, 5, 2, 3, 6, 9, 12, 13, 10, 7, 11, 14, 15}
+	vp8Cat3456 = [4][]uint8{
+		{173, 148, 140},
+		{176, 155, 140, 135},
+		{180, 157, 141, 134, 130},
+		{254, 254, 243, 230, 196, 177, 153, 140, 133, 130, 129},
+	}
+)
+
+// boolEncoder is the VP8 boolean entropy coder.
+type boolEncoder struct {
+	buf      []byte
+	rng      uint32
+	bottom   uint32
+	bitCount int
+}
+
+func newBoolEncoder() *boolEncoder {
+	return &boolEncoder{rng: 255, bitCount: 24}
+}
+
+// put codes bit, which is false with probability prob/256.
+func (e *boolEncoder) put(bit bool, prob uint8) {
+	split := 1 + (e.rng-1)*uint32(prob)>>8
+	if bit {
+		e.bottom += split
+		e.rng -= split
+	} else {
+		e.rng = split
+	}
+	for e.rng < 128 {
+		e.rng <<= 1
+		if e.bottom&(1<<31) != 0 {
+			// Carry into the bytes already written
+			i := len(e.buf) - 1
+			for e.buf[i] == 0xff {
+				e.buf[i] = 0
+				i--
+			}
+			e.buf[i]++
+		}
+		e.bottom <<= 1
+		e.bitCount--
+		if e.bitCount == 0 {
+			e.buf = append(e.buf, byte(e.bottom>>24))
+			e.bottom &= 1<<24 - 1
+			e.bitCount = 8
+		}
+	}
+}
+
+// putUint codes the n low bits of v, most significant first, at even odds.
+func (e *boolEncoder) putUint(v uint32, n int) {
+	for n > 0 {
+		n--
+		e.put(v>>n&1 != 0, 128)
+	}
+}
+
+// bytes flushes the coder and returns its output.
+func (e *boolEncoder) bytes() []byte {
+	for i := 0; i < 32; i++ {
+		e.put(false, 128)
+	}
+	return e.buf
+}
+
+// vp8Quant holds the DC and AC dequantization factors of each block type.
+type vp8Quant struct {
+	y1, y2, uv [2]int32
+}
+
+// vp8NonZero records which blocks along a macroblock edge coded any
+// coefficients, the context of the blocks next to them.
+type vp8NonZero struct {
+	y    [4]uint8
+	u, v [2]uint8
+	y2   uint8
+}
+
+// vp8Encoder encodes a key frame, one macroblock at a time, predicting each
+// from the frame as the decoder will have reconstructed it.
+type vp8Encoder struct {
+	width, height int
+	mbw, mbh      int
+	qIndex        int
+	quant         vp8Quant
+	filterLevel   int
+
+	y, u, v    []uint8 // source planes, padded to whole macroblocks
+	ry, ru, rv []uint8 // reconstruction
+	header     *boolEncoder
+	partitions []*boolEncoder
+	upNZ       []vp8NonZero
+	leftNZ     vp8NonZero
+}
+
+// encodeVP8 returns the VP8 key frame of img at quality 0-100.
+func encodeVP8(img *image.NRGBA, quality int) ([]byte, error) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	if width > vp8MaxSize || height > vp8MaxSize {
+		return nil, fmt.Errorf("image %dx%d exceeds the lossy WebP limit of %d", width, height, vp8MaxSize)
+	}
+
+	e := &vp8Encoder{
+		width:  width,
+		height: height,
+		mbw:    ceilDiv(width, 16),
+		mbh:    ceilDiv(height, 16),
+		header: newBoolEncoder(),
+	}
+	e.setQuality(quality)
+	e.convert(img)
+	n := 1
+	if width*height > vp8PartitionPixels {
+		n = 8
+	}
+	for i := 0; i < n; i++ {
+		e.partitions = append(e.partitions, newBoolEncoder())
+	}
+	e.upNZ = make([]vp8NonZero, e.mbw)
+
+	e.writeHeader()
+	for mby := 0; mby < e.mbh; mby++ {
+		e.leftNZ = vp8NonZero{}
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			e.encodeMacroblock(mbx, mby)
+		}
+	}
+	return e.frame()
+}
+
+// setQuality picks the quantizer for quality on a curve close to libwebp's,
+// so a quality gives files of similar size and look, and a loop filter
+// strength to match it.
+func (e *vp8Encoder) setQuality(quality int) {
+	c := float64(max(0, min(100, quality))) / 100
+	if c < 0.75 {
+		c *= 2.0 / 3
+	} else {
+		c = 2*c - 1
+	}
+	e.qIndex = int(math.Round(127 * (1 - math.Cbrt(c))))
+
+	q := e.qIndex
+	e.quant.y1 = [2]int32{int32(vp8DequantDC[q]), int32(vp8DequantAC[q])}
+	e.quant.y2 = [2]int32{2 * int32(vp8DequantDC[q]), max(8, int32(vp8DequantAC[q])*155/100)}
+	e.quant.uv = [2]int32{int32(vp8DequantDC[min(q, 117)]), int32(vp8DequantAC[q])}
+	e.filterLevel = min(63, int(vp8DequantAC[q])/8)
+}
+
+// convert fills the source planes from img with BT.601 studio range YCbCr,
+// which WebP decoders assume, repeating the last row and column to fill
+// the macroblocks.
+func (e *vp8Encoder) convert(img *image.NRGBA) {
+	yStride, cStride := 16*e.mbw, 8*e.mbw
+	e.y, e.ry = make([]uint8, yStride*16*e.mbh), make([]uint8, yStride*16*e.mbh)
+	e.u, e.ru = make([]uint8, cStride*8*e.mbh), make([]uint8, cStride*8*e.mbh)
+	e.v, e.rv = make([]uint8, cStride*8*e.mbh), make([]uint8, cStride*8*e.mbh)
+
+	pixel := func(x, y int) (int32, int32, int32) {
+		i := min(y, e.height-1)*img.Stride + 4*min(x, e.width-1)
+		return int32(img.Pix[i]), int32(img.Pix[i+1]), int32(img.Pix[i+2])
+	}
+	for y := 0; y < 16*e.mbh; y++ {
+		for x := 0; x < yStride; x++ {
+			r, g, b := pixel(x, y)
+			e.y[y*yStride+x] = uint8((16839*r + 33059*g + 6420*b + 1<<15 + 16<<16) >> 16)
+		}
+	}
+	for y := 0; y < 8*e.mbh; y++ {
+		for x := 0; x < cStride; x++ {
+			// Sums of 2x2 pixels
+			var r, g, b int32
+			for _, p := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				pr, pg, pb := pixel(2*x+p[0], 2*y+p[1])
+				r, g, b = r+pr, g+pg, b+pb
+			}
+			e.u[y*cStride+x] = clip8((-9719*r - 19081*g + 28800*b + 1<<17 + 128<<18) >> 18)
+			e.v[y*cStride+x] = clip8((28800*r - 24116*g - 4684*b + 1<<17 + 128<<18) >> 18)
+		}
+	}
+}
+
+// writeHeader codes the frame header into the first partition: no
+// segments, the normal loop filter, one quantizer and the default token
+// probabilities.
+func (e *vp8Encoder) writeHeader() {
+	h := e.header
+	h.putUint(0, 1) // color space
+	h.putUint(0, 1) // clamping required
+	h.putUint(0, 1) // no segmentation
+	h.putUint(0, 1) // normal loop filter
+	h.putUint(uint32(e.filterLevel), 6)
+	h.putUint(0, 3) // sharpness
+	h.putUint(0, 1) // no loop filter adjustments
+	log2 := 0
+	for 1<<log2 < len(e.partitions) {
+		log2++
+	}
+	h.putUint(uint32(log2), 2)
+	h.putUint(uint32(e.qIndex), 7)
+	for i := 0; i < 5; i++ {
+		h.putUint(0, 1) // no quantizer deltas
+	}
+	h.putUint(0, 1) // refresh entropy probs
+	for i := range vp8TokenUpdateProb {
+		for j := range vp8TokenUpdateProb[i] {
+			for k := range vp8TokenUpdateProb[i][j] {
+				for _, p := range vp8TokenUpdateProb[i][j][k] {
+					h.put(false, p)
+				}
+			}
+		}
+	}
+	h.putUint(0, 1) // every macroblock codes its coefficients
+}
+
+// encodeMacroblock codes the macroblock at mbx, mby with 16x16 luma and 8x8
+// chroma prediction and reconstructs it as the decoder will.
+func (e *vp8Encoder) encodeMacroblock(mbx, mby int) {
+	tokens := e.partitions[mby%len(e.partitions)]
+	up, left := &e.upNZ[mbx], &e.leftNZ
+
+	// Luma: a second order transform of the 16 DCs, then the ACs
+	yStride := 16 * e.mbw
+	x0, y0 := 16*mbx, 16*mby
+	var pred [256]uint8
+	mode := e.bestMode(pred[:], 16, []vplane{{e.y, e.ry, yStride}}, x0, y0)
+	e.header.put(true, 145)
+	switch mode {
+	case vp8PredDC:
+		e.header.put(false, 156)
+		e.header.put(false, 163)
+	case vp8PredVE:
+		e.header.put(false, 156)
+		e.header.put(true, 163)
+	case vp8PredHE:
+		e.header.put(true, 156)
+		e.header.put(false, 128)
+	case vp8PredTM:
+		e.header.put(true, 156)
+		e.header.put(true, 128)
+	}
+
+	var levels [16][16]int32
+	var dc [16]int32
+	for b := range levels {
+		bx, by := 4*(b%4), 4*(b/4)
+		coeffs := forwardDCT(e.y[(y0+by)*yStride+x0+bx:], yStride, pred[by*16+bx:], 16)
+		dc[b] = coeffs[0]
+		for i := 1; i < 16; i++ {
+			levels[b][i] = quantize(coeffs[i], e.quant.y1[1])
+		}
+	}
+	var y2 [16]int32
+	for i, c := range forwardWHT(dc) {
+		y2[i] = quantize(c, e.quant.y2[min(i, 1)])
+	}
+	dcs := inverseWHT(dequantize(y2, e.quant.y2))
+	for b := range levels {
+		bx, by := 4*(b%4), 4*(b/4)
+		coeffs := dequantize(levels[b], e.quant.y1)
+		coeffs[0] = dcs[b]
+		inverseDCT(e.ry[(y0+by)*yStride+x0+bx:], yStride, pred[by*16+bx:], 16, coeffs)
+	}
+
+	nz := putCoeffs(tokens, vp8PlaneY2, left.y2+up.y2, y2, 0)
+	left.y2, up.y2 = nz, nz
+	for by := 0; by < 4; by++ {
+		for bx := 0; bx < 4; bx++ {
+			nz := putCoeffs(tokens, vp8PlaneY1WithY2, left.y[by]+up.y[bx], levels[4*by+bx], 1)
+			left.y[by], up.y[bx] = nz, nz
+		}
+	}
+
+	// Chroma: one mode for both planes, four blocks each
+	cStride := 8 * e.mbw
+	x0, y0 = 8*mbx, 8*mby
+	planes := []vplane{{e.u, e.ru, cStride}, {e.v, e.rv, cStride}}
+	var predC [2][64]uint8
+	mode = e.bestMode(predC[0][:], 8, planes, x0, y0)
+	e.predict(predC[1][:], 8, mode, planes[1], x0, y0)
+	switch mode {
+	case vp8PredDC:
+		e.header.put(false, 142)
+	case vp8PredVE:
+		e.header.put(true, 142)
+		e.header.put(false, 114)
+	case vp8PredHE:
+		e.header.put(true, 142)
+		e.header.put(true, 114)
+		e.header.put(false, 183)
+	case vp8PredTM:
+		e.header.put(true, 142)
+		e.header.put(true, 114)
+		e.header.put(true, 183)
+	}
+
+	for p, plane := range planes {
+		ctxLeft, ctxUp := &left.u, &up.u
+		if p == 1 {
+			ctxLeft, ctxUp = &left.v, &up.v
+		}
+		for by := 0; by < 2; by++ {
+			for bx := 0; bx < 2; bx++ {
+				i := (y0+4*by)*cStride + x0 + 4*bx
+				coeffs := forwardDCT(plane.src[i:], cStride, predC[p][4*by*8+4*bx:], 8)
+				var levels [16]int32
+				for k, c := range coeffs {
+					levels[k] = quantize(c, e.quant.uv[min(k, 1)])
+				}
+				inverseDCT(plane.recon[i:], cStride, predC[p][4*by*8+4*bx:], 8, dequantize(levels, e.quant.uv))
+
+				nz := putCoeffs(tokens, vp8PlaneUV, ctxLeft[by]+ctxUp[bx], levels, 0)
+				ctxLeft[by], ctxUp[bx] = nz, nz
+			}
+		}
+	}
+}
+
+// vplane is a source plane and its reconstruction.
+type vplane struct {
+	src, recon []uint8
+	stride     int
+}
+
+// bestMode fills pred with the prediction of the n x n blocks at x, y of
+// planes that differs least from their source, and returns its mode.
+func (e *vp8Encoder) bestMode(pred []uint8, n int, planes []vplane, x, y int) int {
+	best, bestCost := 0, -1
+	for mode := 0; mode < vp8Modes; mode++ {
+		cost := 0
+		for _, plane := range planes {
+			e.predict(pred, n, mode, plane, x, y)
+			for j := 0; j < n; j++ {
+				row := plane.src[(y+j)*plane.stride+x:]
+				for i := 0; i < n; i++ {
+					cost += absInt(int(row[i]) - int(pred[j*n+i]))
+				}
+			}
+		}
+		if bestCost < 0 || cost < bestCost {
+			best, bestCost = mode, cost
+		}
+	}
+	e.predict(pred, n, best, planes[0], x, y)
+	return best
+}
+
+// predict fills pred with the n x n prediction of mode at x, y of a
+// plane's reconstruction. Above the frame the decoder assumes 127 and left
+// of it 129, and DC prediction averages only the edges inside the frame.
+func (e *vp8Encoder) predict(pred []uint8, n, mode int, plane vplane, x, y int) {
+	recon, stride := plane.recon, plane.stride
+	var top, left [16]int32
+	var topLeft int32
+	for i := 0; i < n; i++ {
+		top[i], left[i] = 127, 129
+		if y > 0 {
+			top[i] = int32(recon[(y-1)*stride+x+i])
+		}
+		if x > 0 {
+			left[i] = int32(recon[(y+i)*stride+x-1])
+		}
+	}
+	switch {
+	case y == 0:
+		topLeft = 127
+	case x == 0:
+		topLeft = 129
+	default:
+		topLeft = int32(recon[(y-1)*stride+x-1])
+	}
+
+	if mode == vp8PredDC {
+		var sum int32
+		shift := 3
+		if n == 16 {
+			shift = 4
+		}
+		switch {
+		case x > 0 && y > 0:
+			for i := 0; i < n; i++ {
+				sum += top[i] + left[i]
+			}
+			sum = (sum + int32(n)) >> (shift + 1)
+		case y > 0:
+			for i := 0; i < n; i++ {
+				sum += top[i]
+			}
+			sum = (sum + int32(n/2)) >> shift
+		case x > 0:
+			for i := 0; i < n; i++ {
+				sum += left[i]
+			}
+			sum = (sum + int32(n/2)) >> shift
+		default:
+			sum = 128
+		}
+		for i := 0; i < n*n; i++ {
+			pred[i] = uint8(sum)
+		}
+		return
+	}
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			var p int32
+			switch mode {
+			case vp8PredTM:
+				p = left[j] + top[i] - topLeft
+			case vp8PredVE:
+				p = top[i]
+			case vp8PredHE:
+				p = left[j]
+			}
+			pred[j*n+i] = clip8(p)
+		}
+	}
+}
+
+// frame assembles the frame tag, key frame header and partitions.
+func (e *vp8Encoder) frame() ([]byte, error) {
+	first := e.header.bytes()
+	if len(first) >= vp8MaxFirstPartition {
+		return nil, fmt.Errorf("image too large for a lossy WebP header")
+	}
+	parts := make([][]byte, len(e.partitions))
+	size := 10 + len(first) + 3*(len(parts)-1)
+	for i, p := range e.partitions {
+		parts[i] = p.bytes()
+		if len(parts[i]) >= vp8MaxPartition {
+			return nil, fmt.Errorf("image too large for lossy WebP at this quality")
+		}
+		size += len(parts[i])
+	}
+
+	buf := make([]byte, 0, size)
+	tag := uint32(len(first))<<5 | 1<<4 // key frame, version 0, shown
+	buf = append(buf, byte(tag), byte(tag>>8), byte(tag>>16))
+	buf = append(buf, 0x9d, 0x01, 0x2a)
+	buf = append(buf, byte(e.width), byte(e.width>>8), byte(e.height), byte(e.height>>8))
+	buf = append(buf, first...)
+	for _, p := range parts[:len(parts)-1] {
+		buf = append(buf, byte(len(p)), byte(len(p)>>8), byte(len(p)>>16))
+	}
+	for _, p := range parts {
+		buf = append(buf, p...)
+	}
+	return buf, nil
+}
+
+// putCoeffs codes the levels of a block from index first on, in zigzag
+// order, and returns 1 if it coded any.
+func putCoeffs(e *boolEncoder, plane int, ctx uint8, levels [16]int32, first int) uint8 {
+	last := -1
+	for n := first; n < 16; n++ {
+		if levels[vp8Zigzag[n]] != 0 {
+			last = n
+		}
+	}
+	probs := &vp8DefaultTokenProb[plane]
+	p := probs[vp8Bands[first]][ctx]
+	if last < 0 {
+		e.put(false, p[0])
+		return 0
+	}
+	e.put(true, p[0])
+
+	for n := first; n < 16; n++ {
+		level := levels[vp8Zigzag[n]]
+		v := level
+		if v < 0 {
+			v = -v
+		}
+		if v == 0 {
+			e.put(false, p[1])
+			p = probs[vp8Bands[n+1]][0]
+			continue
+		}
+		e.put(true, p[1])
+		if v == 1 {
+			e.put(false, p[2])
+			p = probs[vp8Bands[n+1]][1]
+		} else {
+			e.put(true, p[2])
+			putLevel(e, p, v)
+			p = probs[vp8Bands[n+1]][2]
+		}
+		e.put(level < 0, 128)
+		if n == 15 {
+			break
+		}
+		e.put(n != last, p[0]) // end of block
+		if n == last {
+			break
+		}
+	}
+	return 1
+}
+
+// putLevel codes a level above 1 as a token and its extra bits.
+func putLevel(e *boolEncoder, p [11]uint8, v int32) {
+	switch {
+	case v <= 4:
+		e.put(false, p[3])
+		if v == 2 {
+			e.put(false, p[4])
+			return
+		}
+		e.put(true, p[4])
+		e.put(v == 4, p[5])
+	case v <= 10:
+		e.put(true, p[3])
+		e.put(false, p[6])
+		if v <= 6 {
+			e.put(false, p[7])
+			e.put(v == 6, 159)
+			return
+		}
+		e.put(true, p[7])
+		e.put((v-7)&2 != 0, 165)
+		e.put((v-7)&1 != 0, 145)
+	default:
+		e.put(true, p[3])
+		e.put(true, p[6])
+		cat := 0
+		for cat < 3 && v >= 3+(8<<(cat+1)) {
+			cat++
+		}
+		e.put(cat >= 2, p[8])
+		e.put(cat&1 != 0, p[9+cat>>1])
+		extra := v - 3 - 8<<cat
+		bits := vp8Cat3456[cat]
+		for i, prob := range bits {
+			e.put(extra>>(len(bits)-1-i)&1 != 0, prob)
+		}
+	}
+}
+
+// quantize divides a coefficient by q, rounding to the nearest level.
+func quantize(c, q int32) int32 {
+	level := (abs32(c) + q/2) / q
+	level = min(level, vp8MaxLevel)
+	if c < 0 {
+		return -level
+	}
+	return level
+}
+
+// dequantize scales levels back by the block type's DC and AC factors.
+func dequantize(levels [16]int32, q [2]int32) [16]int32 {
+	var coeffs [16]int32
+	for i, l := range levels {
+		coeffs[i] = l * q[min(i, 1)]
+	}
+	return coeffs
+}
+
+// forwardDCT transforms the difference between a 4x4 source block and its
+// prediction, as libwebp does.
+func forwardDCT(src []uint8, srcStride int, pred []uint8, predStride int) [16]int32 {
+	var tmp, out [16]int32
+	for i := 0; i < 4; i++ {
+		s, p := src[i*srcStride:], pred[i*predStride:]
+		d0 := int32(s[0]) - int32(p[0])
+		d1 := int32(s[1]) - int32(p[1])
+		d2 := int32(s[2]) - int32(p[2])
+		d3 := int32(s[3]) - int32(p[3])
+		a0, a1, a2, a3 := d0+d3, d1+d2, d1-d2, d0-d3
+		tmp[0+i*4] = (a0 + a1) * 8
+		tmp[1+i*4] = (a2*2217 + a3*5352 + 1812) >> 9
+		tmp[2+i*4] = (a0 - a1) * 8
+		tmp[3+i*4] = (a3*2217 - a2*5352 + 937) >> 9
+	}
+	for i := 0; i < 4; i++ {
+		a0 := tmp[0+i] + tmp[12+i]
+		a1 := tmp[4+i] + tmp[8+i]
+		a2 := tmp[4+i] - tmp[8+i]
+		a3 := tmp[0+i] - tmp[12+i]
+		out[0+i] = (a0 + a1 + 7) >> 4
+		out[4+i] = (a2*2217 + a3*5352 + 12000) >> 16
+		if a3 != 0 {
+			out[4+i]++
+		}
+		out[8+i] = (a0 - a1 + 7) >> 4
+		out[12+i] = (a3*2217 - a2*5352 + 51000) >> 16
+	}
+	return out
+}
+
+// inverseDCT adds the inverse transform of coeffs to a 4x4 prediction,
+// exactly as the decoder does.
+func inverseDCT(dst []uint8, dstStride int, pred []uint8, predStride int, coeffs [16]int32) {
+	const c1, c2 = 85627, 35468
+	var m [4][4]int32
+	for i := 0; i < 4; i++ {
+		a := coeffs[i] + coeffs[8+i]
+		b := coeffs[i] - coeffs[8+i]
+		c := (coeffs[4+i]*c2)>>16 - (coeffs[12+i]*c1)>>16
+		d := (coeffs[4+i]*c1)>>16 + (coeffs[12+i]*c2)>>16
+		m[i] = [4]int32{a + d, b + c, b - c, a - d}
+	}
+	for j := 0; j < 4; j++ {
+		dc := m[0][j] + 4
+		a := dc + m[2][j]
+		b := dc - m[2][j]
+		c := (m[1][j]*c2)>>16 - (m[3][j]*c1)>>16
+		d := (m[1][j]*c1)>>16 + (m[3][j]*c2)>>16
+		p, out := pred[j*predStride:], dst[j*dstStride:]
+		out[0] = clip8(int32(p[0]) + (a+d)>>3)
+		out[1] = clip8(int32(p[1]) + (b+c)>>3)
+		out[2] = clip8(int32(p[2]) + (b-c)>>3)
+		out[3] = clip8(int32(p[3]) + (a-d)>>3)
+	}
+}
+
+// forwardWHT transforms the DCs of the 16 luma blocks, as libwebp does.
+func forwardWHT(dc [16]int32) [16]int32 {
+	var tmp, out [16]int32
+	for i := 0; i < 4; i++ {
+		in := dc[4*i:]
+		a0, a1 := in[0]+in[2], in[1]+in[3]
+		a2, a3 := in[1]-in[3], in[0]-in[2]
+		tmp[0+i*4] = a0 + a1
+		tmp[1+i*4] = a3 + a2
+		tmp[2+i*4] = a3 - a2
+		tmp[3+i*4] = a0 - a1
+	}
+	for i := 0; i < 4; i++ {
+		a0, a1 := tmp[0+i]+tmp[8+i], tmp[4+i]+tmp[12+i]
+		a2, a3 := tmp[4+i]-tmp[12+i], tmp[0+i]-tmp[8+i]
+		out[0+i] = (a0 + a1) >> 1
+		out[4+i] = (a3 + a2) >> 1
+		out[8+i] = (a3 - a2) >> 1
+		out[12+i] = (a0 - a1) >> 1
+	}
+	return out
+}
+
+// inverseWHT returns the luma DCs of the second order coefficients,
+// exactly as the decoder does.
+func inverseWHT(coeffs [16]int32) [16]int32 {
+	var m, dc [16]int32
+	for i := 0; i < 4; i++ {
+		a0 := coeffs[0+i] + coeffs[12+i]
+		a1 := coeffs[4+i] + coeffs[8+i]
+		a2 := coeffs[4+i] - coeffs[8+i]
+		a3 := coeffs[0+i] - coeffs[12+i]
+		m[0+i] = a0 + a1
+		m[8+i] = a0 - a1
+		m[4+i] = a3 + a2
+		m[12+i] = a3 - a2
+	}
+	for i := 0; i < 4; i++ {
+		d := m[4*i] + 3
+		a0 := d + m[3+4*i]
+		a1 := m[1+4*i] + m[2+4*i]
+		a2 := m[1+4*i] - m[2+4*i]
+		a3 := d - m[3+4*i]
+		dc[4*i+0] = (a0 + a1) >> 3
+		dc[4*i+1] = (a3 + a2) >> 3
+		dc[4*i+2] = (a0 - a1) >> 3
+		dc[4*i+3] = (a3 - a2) >> 3
+	}
+	return dc
+}
+
+func clip8(v int32) uint8 {
+	return uint8(max(0, min(255, v)))
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// vp8TokenUpdateProb are the probabilities that a frame updates each token
+// probability.
+var vp8TokenUpdateProb = [4][8][3][11]uint8{
+	{
+		{
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{176, 246, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{223, 241, 252, 255, 255, 255, 255, 255, 255, 255, 255},
+			{249, 253, 253, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 244, 252, 255, 255, 255, 255, 255, 255, 255, 255},
+			{234, 254, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{253, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 246, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{239, 253, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{254, 255, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 248, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{251, 255, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 253, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{251, 254, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{254, 255, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 254, 253, 255, 254, 255, 255, 255, 255, 255, 255},
+			{250, 255, 254, 255, 254, 255, 255, 255, 255, 255, 255},
+			{254, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+	},
+	{
+		{
+			{217, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{225, 252, 241, 253, 255, 255, 254, 255, 255, 255, 255},
+			{234, 250, 241, 250, 253, 255, 253, 254, 255, 255, 255},
+		},
+		{
+			{255, 254, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{223, 254, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{238, 253, 254, 254, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 248, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{249, 254, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 253, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{247, 254, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 253, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{252, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 254, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{253, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 254, 253, 255, 255, 255, 255, 255, 255, 255, 255},
+			{250, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{254, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+	},
+	{
+		{
+			{186, 251, 250, 255, 255, 255, 255, 255, 255, 255, 255},
+			{234, 251, 244, 254, 255, 255, 255, 255, 255, 255, 255},
+			{251, 251, 243, 253, 254, 255, 254, 255, 255, 255, 255},
+		},
+		{
+			{255, 253, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{236, 253, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{251, 253, 253, 254, 254, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 254, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{254, 254, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 254, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{254, 254, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{254, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{254, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+	},
+	{
+		{
+			{248, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{250, 254, 252, 254, 255, 255, 255, 255, 255, 255, 255},
+			{248, 254, 249, 253, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 253, 253, 255, 255, 255, 255, 255, 255, 255, 255},
+			{246, 253, 253, 255, 255, 255, 255, 255, 255, 255, 255},
+			{252, 254, 251, 254, 254, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 254, 252, 255, 255, 255, 255, 255, 255, 255, 255},
+			{248, 254, 253, 255, 255, 255, 255, 255, 255, 255, 255},
+			{253, 255, 254, 254, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 251, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{245, 251, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{253, 253, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 251, 253, 255, 255, 255, 255, 255, 255, 255, 255},
+			{252, 253, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 254, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 252, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{249, 255, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 254, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 255, 253, 255, 255, 255, 255, 255, 255, 255, 255},
+			{250, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+		{
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{254, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+			{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+	},
+}
+
+// vp8DefaultTokenProb are the token probabilities of a key frame that
+// updates none.
+var vp8DefaultTokenProb = [4][8][3][11]uint8{
+	{
+		{
+			{128, 128, 128, 128, 128, 128, 128, 128, 128, 128, 128},
+			{128, 128, 128, 128, 128, 128, 128, 128, 128, 128, 128},
+			{128, 128, 128, 128, 128, 128, 128, 128, 128, 128, 128},
+		},
+		{
+			{253, 136, 254, 255, 228, 219, 128, 128, 128, 128, 128},
+			{189, 129, 242, 255, 227, 213, 255, 219, 128, 128, 128},
+			{106, 126, 227, 252, 214, 209, 255, 255, 128, 128, 128},
+		},
+		{
+			{1, 98, 248, 255, 236, 226, 255, 255, 128, 128, 128},
+			{181, 133, 238, 254, 221, 234, 255, 154, 128, 128, 128},
+			{78, 134, 202, 247, 198, 180, 255, 219, 128, 128, 128},
+		},
+		{
+			{1, 185, 249, 255, 243, 255, 128, 128, 128, 128, 128},
+			{184, 150, 247, 255, 236, 224, 128, 128, 128, 128, 128},
+			{77, 110, 216, 255, 236, 230, 128, 128, 128, 128, 128},
+		},
+		{
+			{1, 101, 251, 255, 241, 255, 128, 128, 128, 128, 128},
+			{170, 139, 241, 252, 236, 209, 255, 255, 128, 128, 128},
+			{37, 116, 196, 243, 228, 255, 255, 255, 128, 128, 128},
+		},
+		{
+			{1, 204, 254, 255, 245, 255, 128, 128, 128, 128, 128},
+			{207, 160, 250, 255, 238, 128, 128, 128, 128, 128, 128},
+			{102, 103, 231, 255, 211, 171, 128, 128, 128, 128, 128},
+		},
+		{
+			{1, 152, 252, 255, 240, 255, 128, 128, 128, 128, 128},
+			{177, 135, 243, 255, 234, 225, 128, 128, 128, 128, 128},
+			{80, 129, 211, 255, 194, 224, 128, 128, 128, 128, 128},
+		},
+		{
+			{1, 1, 255, 128, 128, 128, 128, 128, 128, 128, 128},
+			{246, 1, 255, 128, 128, 128, 128, 128, 128, 128, 128},
+			{255, 128, 128, 128, 128, 128, 128, 128, 128, 128, 128},
+		},
+	},
+	{
+		{
+			{198, 35, 237, 223, 193, 187, 162, 160, 145, 155, 62},
+			{131, 45, 198, 221, 172, 176, 220, 157, 252, 221, 1},
+			{68, 47, 146, 208, 149, 167, 221, 162, 255, 223, 128},
+		},
+		{
+			{1, 149, 241, 255, 221, 224, 255, 255, 128, 128, 128},
+			{184, 141, 234, 253, 222, 220, 255, 199, 128, 128, 128},
+			{81, 99, 181, 242, 176, 190, 249, 202, 255, 255, 128},
+		},
+		{
+			{1, 129, 232, 253, 214, 197, 242, 196, 255, 255, 128},
+			{99, 121, 210, 250, 201, 198, 255, 202, 128, 128, 128},
+			{23, 91, 163, 242, 170, 187, 247, 210, 255, 255, 128},
+		},
+		{
+			{1, 200, 246, 255, 234, 255, 128, 128, 128, 128, 128},
+			{109, 178, 241, 255, 231, 245, 255, 255, 128, 128, 128},
+			{44, 130, 201, 253, 205, 192, 255, 255, 128, 128, 128},
+		},
+		{
+			{1, 132, 239, 251, 219, 209, 255, 165, 128, 128, 128},
+			{94, 136, 225, 251, 218, 190, 255, 255, 128, 128, 128},
+			{22, 100, 174, 245, 186, 161, 255, 199, 128, 128, 128},
+		},
+		{
+			{1, 182, 249, 255, 232, 235, 128, 128, 128, 128, 128},
+			{124, 143, 241, 255, 227, 234, 128, 128, 128, 128, 128},
+			{35, 77, 181, 251, 193, 211, 255, 205, 128, 128, 128},
+		},
+		{
+			{1, 157, 247, 255, 236, 231, 255, 255, 128, 128, 128},
+			{121, 141, 235, 255, 225, 227, 255, 255, 128, 128, 128},
+			{45, 99, 188, 251, 195, 217, 255, 224, 128, 128, 128},
+		},
+		{
+			{1, 1, 251, 255, 213, 255, 128, 128, 128, 128, 128},
+			{203, 1, 248, 255, 255, 128, 128, 128, 128, 128, 128},
+			{137, 1, 177, 255, 224, 255, 128, 128, 128, 128, 128},
+		},
+	},
+	{
+		{
+			{253, 9, 248, 251, 207, 208, 255, 192, 128, 128, 128},
+			{175, 13, 224, 243, 193, 185, 249, 198, 255, 255, 128},
+			{73, 17, 171, 221, 161, 179, 236, 167, 255, 234, 128},
+		},
+		{
+			{1, 95, 247, 253, 212, 183, 255, 255, 128, 128, 128},
+			{239, 90, 244, 250, 211, 209, 255, 255, 128, 128, 128},
+			{155, 77, 195, 248, 188, 195, 255, 255, 128, 128, 128},
+		},
+		{
+			{1, 24, 239, 251, 218, 219, 255, 205, 128, 128, 128},
+			{201, 51, 219, 255, 196, 186, 128, 128, 128, 128, 128},
+			{69, 46, 190, 239, 201, 218, 255, 228, 128, 128, 128},
+		},
+		{
+			{1, 191, 251, 255, 255, 128, 128, 128, 128, 128, 128},
+			{223, 165, 249, 255, 213, 255, 128, 128, 128, 128, 128},
+			{141, 124, 248, 255, 255, 128, 128, 128, 128, 128, 128},
+		},
+		{
+			{1, 16, 248, 255, 255, 128, 128, 128, 128, 128, 128},
+			{190, 36, 230, 255, 236, 255, 128, 128, 128, 128, 128},
+			{149, 1, 255, 128, 128, 128, 128, 128, 128, 128, 128},
+		},
+		{
+			{1, 226, 255, 128, 128, 128, 128, 128, 128, 128, 128},
+			{247, 192, 255, 128, 128, 128, 128, 128, 128, 128, 128},
+			{240, 128, 255, 128, 128, 128, 128, 128, 128, 128, 128},
+		},
+		{
+			{1, 134, 252, 255, 255, 128, 128, 128, 128, 128, 128},
+			{213, 62, 250, 255, 255, 128, 128, 128, 128, 128, 128},
+			{55, 93, 255, 128, 128, 128, 128, 128, 128, 128, 128},
+		},
+		{
+			{128, 128, 128, 128, 128, 128, 128, 128, 128, 128, 128},
+			{128, 128, 128, 128, 128, 128, 128, 128, 128, 128, 128},
+			{128, 128, 128, 128, 128, 128, 128, 128, 128, 128, 128},
+		},
+	},
+	{
+		{
+			{202, 24, 213, 235, 186, 191, 220, 160, 240, 175, 255},
+			{126, 38, 182, 232, 169, 184, 228, 174, 255, 187, 128},
+			{61, 46, 138, 219, 151, 178, 240, 170, 255, 216, 128},
+		},
+		{
+			{1, 112, 230, 250, 199, 191, 247, 159, 255, 255, 128},
+			{166, 109, 228, 252, 211, 215, 255, 174, 128, 128, 128},
+			{39, 77, 162, 232, 172, 180, 245, 178, 255, 255, 128},
+		},
+		{
+			{1, 52, 220, 246, 198, 199, 249, 220, 255, 255, 128},
+			{124, 74, 191, 243, 183, 193, 250, 221, 255, 255, 128},
+			{24, 71, 130, 219, 154, 170, 243, 182, 255, 255, 128},
+		},
+		{
+			{1, 182, 225, 249, 219, 240, 255, 224, 128, 128, 128},
+			{149, 150, 226, 252, 216, 205, 255, 171, 128, 128, 128},
+			{28, 108, 170, 242, 183, 194, 254, 223, 255, 255, 128},
+		},
+		{
+			{1, 81, 230, 252, 204, 203, 255, 192, 128, 128, 128},
+			{123, 102, 209, 247, 188, 196, 255, 233, 128, 128, 128},
+			{20, 95, 153, 243, 164, 173, 255, 203, 128, 128, 128},
+		},
+		{
+			{1, 222, 248, 255, 216, 213, 128, 128, 128, 128, 128},
+			{168, 175, 246, 252, 235, 205, 255, 255, 128, 128, 128},
+			{47, 116, 215, 255, 211, 212, 255, 255, 128, 128, 128},
+		},
+		{
+			{1, 121, 236, 253, 212, 214, 255, 255, 128, 128, 128},
+			{141, 84, 213, 252, 201, 202, 255, 219, 128, 128, 128},
+			{42, 80, 160, 240, 162, 185, 255, 205, 128, 128, 128},
+		},
+		{
+			{1, 1, 255, 128, 128, 128, 128, 128, 128, 128, 128},
+			{244, 1, 255, 128, 128, 128, 128, 128, 128, 128, 128},
+			{238, 1, 255, 128, 128, 128, 128, 128, 128, 128, 128},
+		},
+	},
+}
+
+// Dequantization factors of DC and AC coefficients by quantizer index.
+var (
+	vp8DequantDC = [128]uint16{
+		4, 5, 6, 7, 8, 9, 10, 10,
+		11, 12, 13, 14, 15, 16, 17, 17,
+		18, 19, 20, 20, 21, 21, 22, 22,
+		23, 23, 24, 25, 25, 26, 27, 28,
+		29, 30, 31, 32, 33, 34, 35, 36,
+		37, 37, 38, 39, 40, 41, 42, 43,
+		44, 45, 46, 46, 47, 48, 49, 50,
+		51, 52, 53, 54, 55, 56, 57, 58,
+		59, 60, 61, 62, 63, 64, 65, 66,
+		67, 68, 69, 70, 71, 72, 73, 74,
+		75, 76, 76, 77, 78, 79, 80, 81,
+		82, 83, 84, 85, 86, 87, 88, 89,
+		91, 93, 95, 96, 98, 100, 101, 102,
+		104, 106, 108, 110, 112, 114, 116, 118,
+		122, 124, 126, 128, 130, 132, 134, 136,
+		138, 140, 143, 145, 148, 151, 154, 157,
+	}
+	vp8DequantAC = [128]uint16{
+		4, 5, 6, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16, 17, 18, 19,
+		20, 21, 22, 23, 24, 25, 26, 27,
+		28, 29, 30, 31, 32, 33, 34, 35,
+		36, 37, 38, 39, 40, 41, 42, 43,
+		44, 45, 46, 47, 48, 49, 50, 51,
+		52, 53, 54, 55, 56, 57, 58, 60,
+		62, 64, 66, 68, 70, 72, 74, 76,
+		78, 80, 82, 84, 86, 88, 90, 92,
+		94, 96, 98, 100, 102, 104, 106, 108,
+		110, 112, 114, 116, 119, 122, 125, 128,
+		131, 134, 137, 140, 143, 146, 149, 152,
+		155, 158, 161, 164, 167, 170, 173, 177,
+		181, 185, 189, 193, 197, 201, 205, 209,
+		213, 217, 221, 225, 229, 234, 239, 245,
+		249, 254, 259, 264, 269, 274, 279, 284,
+	}
+)
@@ -0,0 +1,674 @@
+// Package image provides a lossless WebP (VP8L) encoder.
+package image
+
+import (
+	"container/heap"
+	"fmt"
+	"image"
+	"math/bits"
+	"sort"
+)
+
+// Limits of the VP8L format and of this encoder.
+const (
+	vp8lMaxSize       = 1 << 14
+	vp8lPredictorBits = 4 // 16x16 tiles share a predictor
+	vp8lMinMatch      = 3
+	vp8lMaxMatch      = 4096
+	vp8lMaxDistance   = 1<<20 - 120
+	vp8lHashBits      = 16
+	vp8lChainDepth    = 32
+	vp8lMaxCodeLength = 15
+)
+
+// Alphabet sizes of the five prefix codes of an entropy-coded image.
+const (
+	vp8lGreenCodes    = 256 + 24
+	vp8lLiteralCodes  = 256
+	vp8lDistanceCodes = 40
+)
+
+// vp8lCodeLengthOrder is the order code length code lengths are written in.
+var vp8lCodeLengthOrder = [19]uint8{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// vp8lDistanceMap lists the (dy, 8 - dx) offsets of the 120 short distance
+// codes, packed as dy<<4 | (8 - dx).
+var vp8lDistanceMap = [120]uint8{
+	0x18, 0x07, 0x17, 0x19, 0x28, 0x06, 0x27, 0x29, 0x16, 0x1a,
+	0x26, 0x2a, 0x38, 0x05, 0x37, 0x39, 0x15, 0x1b, 0x36, 0x3a,
+	0x25, 0x2b, 0x48, 0x04, 0x47, 0x49, 0x14, 0x1c, 0x35, 0x3b,
+	0x46, 0x4a, 0x24, 0x2c, 0x58, 0x45, 0x4b, 0x34, 0x3c, 0x03,
+	0x57, 0x59, 0x13, 0x1d, 0x56, 0x5a, 0x23, 0x2d, 0x44, 0x4c,
+	0x55, 0x5b, 0x33, 0x3d, 0x68, 0x02, 0x67, 0x69, 0x12, 0x1e,
+	0x66, 0x6a, 0x22, 0x2e, 0x54, 0x5c, 0x43, 0x4d, 0x65, 0x6b,
+	0x32, 0x3e, 0x78, 0x01, 0x77, 0x79, 0x53, 0x5d, 0x11, 0x1f,
+	0x64, 0x6c, 0x42, 0x4e, 0x76, 0x7a, 0x21, 0x2f, 0x75, 0x7b,
+	0x31, 0x3f, 0x63, 0x6d, 0x52, 0x5e, 0x00, 0x74, 0x7c, 0x41,
+	0x4f, 0x10, 0x20, 0x62, 0x6e, 0x30, 0x73, 0x7d, 0x51, 0x5f,
+	0x40, 0x72, 0x7e, 0x61, 0x6f, 0x50, 0x71, 0x7f, 0x60, 0x70,
+}
+
+// bitWriter writes bits least significant first, as VP8L reads them.
+type bitWriter struct {
+	buf   []byte
+	bits  uint64
+	nBits uint
+}
+
+// write appends the n low bits of v.
+func (w *bitWriter) write(v uint32, n uint) {
+	w.bits |= uint64(v) << w.nBits
+	w.nBits += n
+	for w.nBits >= 8 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits >>= 8
+		w.nBits -= 8
+	}
+}
+
+// bytes flushes the partial byte and returns the output.
+func (w *bitWriter) bytes() []byte {
+	if w.nBits > 0 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits, w.nBits = 0, 0
+	}
+	return w.buf
+}
+
+// encodeVP8L returns the VP8L bitstream of img.
+func encodeVP8L(img *image.NRGBA) ([]byte, error) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	if width > vp8lMaxSize || height > vp8lMaxSize {
+		return nil, fmt.Errorf("image %dx%d exceeds the lossless WebP limit of %d", width, height, vp8lMaxSize)
+	}
+
+	argb := make([]uint32, width*height)
+	for y := 0; y < height; y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < width; x++ {
+			r, g, b, a := uint32(row[4*x]), uint32(row[4*x+1]), uint32(row[4*x+2]), uint32(row[4*x+3])
+			argb[y*width+x] = a<<24 | r<<16 | g<<8 | b
+		}
+	}
+
+	w := &bitWriter{}
+	w.write(0x2f, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	if img.Opaque() {
+		w.write(0, 1)
+	} else {
+		w.write(1, 1)
+	}
+	w.write(0, 3) // version
+	writeVP8LImage(w, argb, width, height)
+	return w.bytes(), nil
+}
+
+// encodeVP8LAlpha returns the alpha of img as a VP8L bitstream without
+// header, the alpha in green, for an ALPH chunk.
+func encodeVP8LAlpha(img *image.NRGBA) []byte {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	argb := make([]uint32, width*height)
+	for y := 0; y < height; y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < width; x++ {
+			argb[y*width+x] = 0xff000000 | uint32(row[4*x+3])<<8
+		}
+	}
+	w := &bitWriter{}
+	writeVP8LImage(w, argb, width, height)
+	return w.bytes()
+}
+
+// writeVP8LImage writes the subtract green and predictor transforms of
+// argb and the LZ77 and prefix coded result.
+func writeVP8LImage(w *bitWriter, argb []uint32, width, height int) {
+	subtractGreen(argb)
+	w.write(1, 1)
+	w.write(2, 2)
+
+	modes := predict(argb, width, height)
+	w.write(1, 1)
+	w.write(0, 2)
+	w.write(vp8lPredictorBits-2, 3)
+	encodeEntropyImage(w, modes, ceilDiv(width, 1<<vp8lPredictorBits), false)
+
+	w.write(0, 1) // no more transforms
+	encodeEntropyImage(w, argb, width, true)
+}
+
+// subtractGreen subtracts each pixel's green from its red and blue.
+func subtractGreen(argb []uint32) {
+	for i, p := range argb {
+		g := p >> 8 & 0xff
+		r := (p>>16 - g) & 0xff
+		b := (p - g) & 0xff
+		argb[i] = p&0xff00ff00 | r<<16 | b
+	}
+}
+
+// predict replaces argb with its residuals after the predictor transform
+// and returns the sub-image of the mode chosen per tile, the one with the
+// smallest residuals.
+func predict(argb []uint32, width, height int) []uint32 {
+	const size = 1 << vp8lPredictorBits
+	tilesX, tilesY := ceilDiv(width, size), ceilDiv(height, size)
+	modes := make([]uint32, tilesX*tilesY)
+	residuals := make([]uint32, len(argb))
+
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			x0, y0 := tx*size, ty*size
+			x1, y1 := min(x0+size, width), min(y0+size, height)
+			best, bestCost := 0, -1
+			for mode := 0; mode < 14; mode++ {
+				cost := 0
+				for y := y0; y < y1; y++ {
+					for x := x0; x < x1; x++ {
+						cost += residualCost(subPixels(argb[y*width+x], predictPixel(argb, width, x, y, mode)))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[ty*tilesX+tx] = 0xff000000 | uint32(best)<<8
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					i := y*width + x
+					residuals[i] = subPixels(argb[i], predictPixel(argb, width, x, y, best))
+				}
+			}
+		}
+	}
+	copy(argb, residuals)
+	return modes
+}
+
+// residualCost estimates the cost of coding a residual by the size of its
+// channels as signed bytes.
+func residualCost(d uint32) int {
+	cost := 0
+	for shift := 0; shift < 32; shift += 8 {
+		c := int(int8(d >> shift))
+		if c < 0 {
+			c = -c
+		}
+		cost += c
+	}
+	return cost
+}
+
+// predictPixel returns the prediction of the pixel at x, y under mode,
+// with the first row and column predicted as the decoder does.
+func predictPixel(argb []uint32, width, x, y, mode int) uint32 {
+	i := y*width + x
+	switch {
+	case x == 0 && y == 0:
+		return 0xff000000
+	case y == 0:
+		return argb[i-1]
+	case x == 0:
+		return argb[i-width]
+	}
+	l, t, tl := argb[i-1], argb[i-width], argb[i-width-1]
+	tr := argb[i-width+1] // the first pixel of this row at the right edge
+	switch mode {
+	case 0:
+		return 0xff000000
+	case 1:
+		return l
+	case 2:
+		return t
+	case 3:
+		return tr
+	case 4:
+		return tl
+	case 5:
+		return average(average(l, tr), t)
+	case 6:
+		return average(l, tl)
+	case 7:
+		return average(l, t)
+	case 8:
+		return average(tl, t)
+	case 9:
+		return average(t, tr)
+	case 10:
+		return average(average(l, tl), average(t, tr))
+	case 11:
+		return selectPixel(l, t, tl)
+	case 12:
+		return mapChannels(l, t, tl, func(a, b, c int) int { return a + b - c })
+	default:
+		avg := average(l, t)
+		return mapChannels(avg, tl, tl, func(a, b, _ int) int { return a + (a-b)/2 })
+	}
+}
+
+// subPixels subtracts b from a per channel, modulo 256.
+func subPixels(a, b uint32) uint32 {
+	var d uint32
+	for shift := 0; shift < 32; shift += 8 {
+		d |= (a>>shift - b>>shift) & 0xff << shift
+	}
+	return d
+}
+
+// average is the per-channel mean of a and b, rounded down.
+func average(a, b uint32) uint32 {
+	return (a^b)&0xfefefefe>>1 + a&b
+}
+
+// selectPixel returns l or t, whichever is closer to the gradient
+// l + t - tl, as predictor mode 11 does.
+func selectPixel(l, t, tl uint32) uint32 {
+	var toL, toT int
+	for shift := 0; shift < 32; shift += 8 {
+		c := int(tl >> shift & 0xff)
+		toL += absInt(c - int(t>>shift&0xff))
+		toT += absInt(c - int(l>>shift&0xff))
+	}
+	if toL < toT {
+		return l
+	}
+	return t
+}
+
+// mapChannels applies f to the channels of a, b and c and clamps the
+// results to 0-255.
+func mapChannels(a, b, c uint32, f func(a, b, c int) int) uint32 {
+	var p uint32
+	for shift := 0; shift < 32; shift += 8 {
+		v := f(int(a>>shift&0xff), int(b>>shift&0xff), int(c>>shift&0xff))
+		p |= uint32(max(0, min(255, v))) << shift
+	}
+	return p
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// vp8lToken is a literal pixel or, if length > 0, a backward reference.
+type vp8lToken struct {
+	argb     uint32
+	length   int
+	distance int // distance code, before prefix coding
+}
+
+// encodeEntropyImage writes pixels of the given width as an entropy-coded
+// image without color cache or meta prefix codes.
+func encodeEntropyImage(w *bitWriter, argb []uint32, width int, topLevel bool) {
+	w.write(0, 1) // no color cache
+	if topLevel {
+		w.write(0, 1) // one set of prefix codes
+	}
+	tokens := backwardReferences(argb, width)
+
+	green := make([]uint32, vp8lGreenCodes)
+	red := make([]uint32, vp8lLiteralCodes)
+	blue := make([]uint32, vp8lLiteralCodes)
+	alpha := make([]uint32, vp8lLiteralCodes)
+	dist := make([]uint32, vp8lDistanceCodes)
+	for _, t := range tokens {
+		if t.length == 0 {
+			green[t.argb>>8&0xff]++
+			red[t.argb>>16&0xff]++
+			blue[t.argb&0xff]++
+			alpha[t.argb>>24]++
+			continue
+		}
+		sym, _, _ := prefixCode(t.length)
+		green[256+sym]++
+		sym, _, _ = prefixCode(t.distance)
+		dist[sym]++
+	}
+
+	codes := [5]*prefixTree{}
+	for i, counts := range [][]uint32{green, red, blue, alpha, dist} {
+		codes[i] = newPrefixTree(counts, vp8lMaxCodeLength)
+		codes[i].writeTree(w)
+	}
+	for _, t := range tokens {
+		if t.length == 0 {
+			codes[0].writeSymbol(w, int(t.argb>>8&0xff))
+			codes[1].writeSymbol(w, int(t.argb>>16&0xff))
+			codes[2].writeSymbol(w, int(t.argb&0xff))
+			codes[3].writeSymbol(w, int(t.argb>>24))
+			continue
+		}
+		sym, extra, n := prefixCode(t.length)
+		codes[0].writeSymbol(w, 256+sym)
+		w.write(extra, n)
+		sym, extra, n = prefixCode(t.distance)
+		codes[4].writeSymbol(w, sym)
+		w.write(extra, n)
+	}
+}
+
+// prefixCode splits a length or distance code into its prefix symbol and
+// the extra bits that follow it.
+func prefixCode(v int) (symbol int, extra uint32, nBits uint) {
+	d := uint32(v - 1)
+	if d < 4 {
+		return int(d), 0, 0
+	}
+	h := uint(bits.Len32(d)) - 1
+	nBits = h - 1
+	return int(2*h + uint(d>>nBits&1)), d & (1<<nBits - 1), nBits
+}
+
+// backwardReferences finds repeats of earlier pixels greedily, trying the
+// previous pixel and the pixel above before a hash chain of older matches.
+func backwardReferences(argb []uint32, width int) []vp8lToken {
+	// The distance code of each short distance, the smallest if several map to it
+	shortCodes := make(map[int]int, len(vp8lDistanceMap))
+	for i := len(vp8lDistanceMap) - 1; i >= 0; i-- {
+		dy, dx := int(vp8lDistanceMap[i]>>4), 8-int(vp8lDistanceMap[i]&0xf)
+		shortCodes[max(dy*width+dx, 1)] = i + 1
+	}
+
+	n := len(argb)
+	head := make([]int32, 1<<vp8lHashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int32, n)
+	hash := func(i int) uint32 {
+		return (argb[i]*0x1e35a7bd ^ argb[i+1]*0x9e3779b1) >> (32 - vp8lHashBits)
+	}
+	insert := func(i int) {
+		if i+1 < n {
+			h := hash(i)
+			prev[i] = head[h]
+			head[h] = int32(i)
+		}
+	}
+	matchLength := func(i, j, limit int) int {
+		l := 0
+		for l < limit && argb[i+l] == argb[j+l] {
+			l++
+		}
+		return l
+	}
+
+	tokens := make([]vp8lToken, 0, n/2)
+	for i := 0; i < n; {
+		limit := min(n-i, vp8lMaxMatch)
+		bestLen, bestDist := 0, 0
+		for _, d := range [2]int{1, width} {
+			if d <= i {
+				if l := matchLength(i, i-d, limit); l > bestLen {
+					bestLen, bestDist = l, d
+				}
+			}
+		}
+		if i+1 < n && bestLen < limit {
+			for j, depth := head[hash(i)], 0; j >= 0 && depth < vp8lChainDepth; j, depth = prev[j], depth+1 {
+				d := i - int(j)
+				if d > vp8lMaxDistance {
+					break
+				}
+				if l := matchLength(i, int(j), limit); l > bestLen {
+					bestLen, bestDist = l, d
+					if l == limit {
+						break
+					}
+				}
+			}
+		}
+
+		if bestLen < vp8lMinMatch {
+			tokens = append(tokens, vp8lToken{argb: argb[i]})
+			insert(i)
+			i++
+			continue
+		}
+		code, ok := shortCodes[bestDist]
+		if !ok {
+			code = bestDist + len(vp8lDistanceMap)
+		}
+		tokens = append(tokens, vp8lToken{length: bestLen, distance: code})
+		for k := 0; k < bestLen; k++ {
+			insert(i + k)
+		}
+		i += bestLen
+	}
+	return tokens
+}
+
+// prefixTree is a canonical prefix code, written as VP8L code lengths.
+type prefixTree struct {
+	lengths []uint8
+	codes   []uint16 // bit-reversed, as they are written least significant first
+	symbols []int    // the used symbols, if at most two, for the simple form
+	single  bool     // one used symbol, which takes no bits
+}
+
+// newPrefixTree builds a length-limited prefix code for the symbol counts,
+// in the simple form if it has up to two symbols below 256.
+func newPrefixTree(counts []uint32, maxLength int) *prefixTree {
+	t := &prefixTree{}
+	var used []int
+	for sym, c := range counts {
+		if c > 0 {
+			used = append(used, sym)
+		}
+	}
+	if len(used) == 0 {
+		used = []int{0}
+	}
+	if len(used) <= 2 && used[len(used)-1] < 256 {
+		t.symbols = used
+		if len(used) == 2 {
+			t.lengths = make([]uint8, len(counts))
+			t.codes = make([]uint16, len(counts))
+			t.lengths[used[0]], t.lengths[used[1]] = 1, 1
+			t.codes[used[1]] = 1
+		}
+		return t
+	}
+
+	return newCanonicalTree(huffmanLengths(counts, maxLength))
+}
+
+// newCanonicalTree assigns canonical codes to lengths, shorter codes and
+// then smaller symbols first, as the decoder does.
+func newCanonicalTree(lengths []uint8) *prefixTree {
+	t := &prefixTree{lengths: lengths, codes: make([]uint16, len(lengths))}
+	var used []int
+	for sym, l := range lengths {
+		if l > 0 {
+			used = append(used, sym)
+		}
+	}
+	t.single = len(used) == 1
+	sort.SliceStable(used, func(i, j int) bool { return lengths[used[i]] < lengths[used[j]] })
+
+	code, length := uint32(0), uint8(0)
+	for _, sym := range used {
+		code <<= lengths[sym] - length
+		length = lengths[sym]
+		// Codes are read most significant bit first
+		t.codes[sym] = uint16(bits.Reverse32(code) >> (32 - length))
+		code++
+	}
+	return t
+}
+
+// writeTree writes the code in the simple form if it has up to two
+// symbols, else as run-length coded code lengths.
+func (t *prefixTree) writeTree(w *bitWriter) {
+	if t.symbols != nil {
+		w.write(1, 1)
+		w.write(uint32(len(t.symbols)-1), 1)
+		if t.symbols[0] < 2 {
+			w.write(0, 1)
+			w.write(uint32(t.symbols[0]), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(t.symbols[0]), 8)
+		}
+		if len(t.symbols) == 2 {
+			w.write(uint32(t.symbols[1]), 8)
+		}
+		return
+	}
+
+	tokens := codeLengthTokens(t.lengths)
+	counts := make([]uint32, len(vp8lCodeLengthOrder))
+	for _, tok := range tokens {
+		counts[tok.symbol]++
+	}
+	lengthCode := newCanonicalTree(huffmanLengths(counts, 7))
+
+	n := len(vp8lCodeLengthOrder)
+	for n > 4 && lengthCode.lengths[vp8lCodeLengthOrder[n-1]] == 0 {
+		n--
+	}
+	w.write(0, 1)
+	w.write(uint32(n-4), 4)
+	for _, sym := range vp8lCodeLengthOrder[:n] {
+		w.write(uint32(lengthCode.lengths[sym]), 3)
+	}
+	w.write(0, 1) // lengths for the whole alphabet follow
+	for _, tok := range tokens {
+		lengthCode.writeSymbol(w, tok.symbol)
+		w.write(tok.extra, tok.nBits)
+	}
+}
+
+// writeSymbol writes the code of sym.
+func (t *prefixTree) writeSymbol(w *bitWriter, sym int) {
+	if t.lengths == nil || t.single {
+		return
+	}
+	w.write(uint32(t.codes[sym]), uint(t.lengths[sym]))
+}
+
+// codeLengthToken is a code length, or a run of them with its extra bits.
+type codeLengthToken struct {
+	symbol int
+	extra  uint32
+	nBits  uint
+}
+
+// codeLengthTokens run-length codes lengths: 16 repeats the last nonzero
+// length 3-6 times, 17 and 18 give 3-10 and 11-138 zeros.
+func codeLengthTokens(lengths []uint8) []codeLengthToken {
+	var tokens []codeLengthToken
+	prev := uint8(8) // the decoder's initial repeated length
+	for i := 0; i < len(lengths); {
+		v := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == v {
+			run++
+		}
+		i += run
+
+		if v == 0 {
+			for run > 0 {
+				switch {
+				case run >= 11:
+					n := min(run, 138)
+					tokens = append(tokens, codeLengthToken{18, uint32(n - 11), 7})
+					run -= n
+				case run >= 3:
+					tokens = append(tokens, codeLengthToken{17, uint32(run - 3), 3})
+					run = 0
+				default:
+					tokens = append(tokens, codeLengthToken{symbol: 0})
+					run--
+				}
+			}
+			continue
+		}
+		if v != prev {
+			tokens = append(tokens, codeLengthToken{symbol: int(v)})
+			prev = v
+			run--
+		}
+		for run > 0 {
+			if run < 3 {
+				tokens = append(tokens, codeLengthToken{symbol: int(v)})
+				run--
+				continue
+			}
+			n := min(run, 6)
+			tokens = append(tokens, codeLengthToken{16, uint32(n - 3), 2})
+			run -= n
+		}
+	}
+	return tokens
+}
+
+// huffmanLengths returns Huffman code lengths for counts no longer than
+// maxLength, flattening the counts until the longest code fits.
+func huffmanLengths(counts []uint32, maxLength int) []uint8 {
+	lengths := make([]uint8, len(counts))
+	floor := uint32(1)
+	for {
+		h := huffmanHeap{}
+		for sym, c := range counts {
+			if c > 0 {
+				h = append(h, &huffmanNode{count: max(c, floor), symbol: sym})
+			}
+		}
+		if len(h) == 1 {
+			lengths[h[0].symbol] = 1
+			return lengths
+		}
+		heap.Init(&h)
+		for h.Len() > 1 {
+			a, b := heap.Pop(&h).(*huffmanNode), heap.Pop(&h).(*huffmanNode)
+			heap.Push(&h, &huffmanNode{count: a.count + b.count, symbol: min(a.symbol, b.symbol), left: a, right: b})
+		}
+
+		longest := 0
+		var walk func(n *huffmanNode, depth int)
+		walk = func(n *huffmanNode, depth int) {
+			if n.left == nil {
+				lengths[n.symbol] = uint8(depth)
+				longest = max(longest, depth)
+				return
+			}
+			walk(n.left, depth+1)
+			walk(n.right, depth+1)
+		}
+		walk(h[0], 0)
+		if longest <= maxLength {
+			return lengths
+		}
+		floor *= 2
+	}
+}
+
+// huffmanNode is a symbol or a subtree while building a Huffman code.
+type huffmanNode struct {
+	count       uint32
+	symbol      int // the smallest symbol below, to break ties stably
+	left, right *huffmanNode
+}
+
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].symbol < h[j].symbol
+}
+func (h huffmanHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x any)   { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
@@ -0,0 +1,80 @@
+// Package image provides WebP encoding, lossless or lossy, in pure Go.
+package image
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+
+	"github.com/disintegration/imaging"
+)
+
+// WebPOptions are the settings of a WebP file.
+type WebPOptions struct {
+	Lossless bool // keep every pixel exactly; Quality is ignored
+	Quality  int  // 0-100 for lossy files, as for JPEG
+}
+
+// riffChunk is a chunk of a RIFF container.
+type riffChunk struct {
+	id   string
+	data []byte
+}
+
+// EncodeWebP writes img to w as a WebP file: VP8L if lossless, else VP8 at
+// opts.Quality, with any transparency in a losslessly compressed alpha
+// chunk.
+func EncodeWebP(w io.Writer, img image.Image, opts WebPOptions) error {
+	nrgba := imaging.Clone(img)
+
+	if opts.Lossless {
+		data, err := encodeVP8L(nrgba)
+		if err != nil {
+			return err
+		}
+		return writeRIFF(w, riffChunk{"VP8L", data})
+	}
+
+	data, err := encodeVP8(nrgba, opts.Quality)
+	if err != nil {
+		return err
+	}
+	if nrgba.Opaque() {
+		return writeRIFF(w, riffChunk{"VP8 ", data})
+	}
+
+	// Extended format: canvas header, alpha, then the frame
+	const alphaFlag = 1 << 4
+	header := make([]byte, 10)
+	header[0] = alphaFlag
+	width, height := nrgba.Rect.Dx()-1, nrgba.Rect.Dy()-1
+	header[4], header[5], header[6] = byte(width), byte(width>>8), byte(width>>16)
+	header[7], header[8], header[9] = byte(height), byte(height>>8), byte(height>>16)
+
+	const alphaLossless = 1 // compression method, no filtering
+	alpha := append([]byte{alphaLossless}, encodeVP8LAlpha(nrgba)...)
+	return writeRIFF(w, riffChunk{"VP8X", header}, riffChunk{"ALPH", alpha}, riffChunk{"VP8 ", data})
+}
+
+// writeRIFF writes a RIFF WEBP file of chunks, each padded to an even size.
+func writeRIFF(w io.Writer, chunks ...riffChunk) error {
+	size := 4
+	for _, c := range chunks {
+		size += 8 + len(c.data) + len(c.data)&1
+	}
+
+	buf := make([]byte, 0, 8+size)
+	buf = append(buf, "RIFF"...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(size))
+	buf = append(buf, "WEBP"...)
+	for _, c := range chunks {
+		buf = append(buf, c.id...)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(c.data)))
+		buf = append(buf, c.data...)
+		if len(c.data)&1 != 0 {
+			buf = append(buf, 0)
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
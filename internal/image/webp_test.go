@@ -0,0 +1,190 @@
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/webp"
+)
+
+// testArtwork returns a gradient with noisy patches that fades in from
+// transparent on the left.
+func testArtwork(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	r := rand.New(rand.NewSource(1))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{uint8(x * 255 / width), uint8(y * 255 / height), 120, uint8(x * 255 / width)}
+			if (x/24+y/24)%3 == 0 {
+				c.R, c.G = uint8(r.Intn(256)), uint8(r.Intn(256))
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// encodeWebP encodes img and checks the RIFF/WEBP signature and the chunk
+// after it.
+func encodeWebP(t *testing.T, img image.Image, opts WebPOptions, chunk string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := EncodeWebP(&buf, img, opts); err != nil {
+		t.Fatalf("EncodeWebP failed: %v", err)
+	}
+	data := buf.Bytes()
+	if len(data) < 16 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		t.Fatalf("Expected a RIFF/WEBP signature, got % x", data[:min(len(data), 16)])
+	}
+	if size := int(data[4]) | int(data[5])<<8 | int(data[6])<<16 | int(data[7])<<24; size != len(data)-8 {
+		t.Errorf("Expected RIFF size %d, got %d", len(data)-8, size)
+	}
+	if string(data[12:16]) != chunk {
+		t.Errorf("Expected %q chunk, got %q", chunk, data[12:16])
+	}
+	return data
+}
+
+// psnr compares a decoded lossy WebP with the original. WebP stores studio
+// range YCbCr, while Go's color.YCbCr is full range, so convert here.
+func psnr(t *testing.T, decoded image.Image, want *image.NRGBA) float64 {
+	t.Helper()
+	ycbcr, ok := decoded.(*image.YCbCr)
+	if !ok {
+		t.Fatalf("Expected *image.YCbCr, got %T", decoded)
+	}
+	var sum float64
+	bounds := want.Bounds()
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			c := ycbcr.YCbCrAt(x, y)
+			l, cb, cr := 1.164*(float64(c.Y)-16), float64(c.Cb)-128, float64(c.Cr)-128
+			got := [3]float64{l + 1.596*cr, l - 0.813*cr - 0.391*cb, l + 2.018*cb}
+			o := want.NRGBAAt(x, y)
+			for i, v := range [3]uint8{o.R, o.G, o.B} {
+				d := math.Max(0, math.Min(255, got[i])) - float64(v)
+				sum += d * d
+			}
+		}
+	}
+	mse := sum / float64(3*bounds.Dx()*bounds.Dy())
+	return 10 * math.Log10(255*255/mse)
+}
+
+func TestEncodeWebPLossless(t *testing.T) {
+	for _, size := range []image.Point{{1, 1}, {3, 2}, {37, 19}, {300, 200}} {
+		img := testArtwork(size.X, size.Y)
+		data := encodeWebP(t, img, WebPOptions{Lossless: true}, "VP8L")
+
+		decoded, err := webp.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%v: decode failed: %v", size, err)
+		}
+		got, ok := decoded.(*image.NRGBA)
+		if !ok || got.Bounds() != img.Bounds() {
+			t.Fatalf("%v: expected NRGBA of %v, got %T", size, img.Bounds(), decoded)
+		}
+		if !bytes.Equal(got.Pix, img.Pix) {
+			t.Errorf("%v: expected identical pixels", size)
+		}
+	}
+}
+
+func TestEncodeWebPLossy(t *testing.T) {
+	// Chroma is stored at half resolution, so use a photo without noise
+	img := imaging.Clone(testPhoto(200, 150, 10))
+	var sizes []int
+	var scores []float64
+	for _, quality := range []int{30, 75, 95} {
+		data := encodeWebP(t, img, WebPOptions{Quality: quality}, "VP8 ")
+		decoded, err := webp.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Quality %d: decode failed: %v", quality, err)
+		}
+		if decoded.Bounds() != img.Bounds() {
+			t.Fatalf("Quality %d: expected %v, got %v", quality, img.Bounds(), decoded.Bounds())
+		}
+		sizes = append(sizes, len(data))
+		scores = append(scores, psnr(t, decoded, img))
+	}
+
+	if scores[0] < 25 || scores[2] < 35 {
+		t.Errorf("Expected PSNR of at least 25 dB at quality 30 and 35 dB at 95, got %.1f and %.1f", scores[0], scores[2])
+	}
+	for i := 1; i < len(sizes); i++ {
+		if sizes[i] <= sizes[i-1] || scores[i] <= scores[i-1] {
+			t.Errorf("Expected larger, better files as quality rises, got sizes %v, PSNR %v", sizes, scores)
+			break
+		}
+	}
+}
+
+func TestEncodeWebPLossyAlpha(t *testing.T) {
+	img := testArtwork(45, 30)
+	data := encodeWebP(t, img, WebPOptions{Quality: 80}, "VP8X")
+
+	decoded, err := webp.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	got, ok := decoded.(*image.NYCbCrA)
+	if !ok {
+		t.Fatalf("Expected *image.NYCbCrA, got %T", decoded)
+	}
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 45; x++ {
+			if a, want := got.A[got.AOffset(x, y)], img.NRGBAAt(x, y).A; a != want {
+				t.Fatalf("Expected lossless alpha %d at %d,%d, got %d", want, x, y, a)
+			}
+		}
+	}
+}
+
+func TestEncodeWebPTooLarge(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 16385, 1))
+	if err := EncodeWebP(&bytes.Buffer{}, img, WebPOptions{Lossless: true}); err == nil {
+		t.Error("Expected lossless error over 16384 pixels wide")
+	}
+	img = image.NewNRGBA(image.Rect(0, 0, 16384, 1))
+	if err := EncodeWebP(&bytes.Buffer{}, img, WebPOptions{Quality: 80}); err == nil {
+		t.Error("Expected lossy error over 16383 pixels wide")
+	}
+}
+
+func TestSaveImageWebP(t *testing.T) {
+	svc := NewService()
+	dir := t.TempDir()
+	img := testPhoto(64, 48, 8)
+
+	if err := svc.SaveImage(img, filepath.Join(dir, "lossy.png"), "webp", 80); err != nil {
+		t.Fatalf("SaveImage failed: %v", err)
+	}
+	if err := svc.SaveWebP(img, filepath.Join(dir, "lossless"), WebPOptions{Lossless: true}); err != nil {
+		t.Fatalf("SaveWebP failed: %v", err)
+	}
+
+	for _, name := range []string{"lossy.webp", "lossless.webp"} {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Expected %s: %v", name, err)
+		}
+		if string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+			t.Errorf("%s: expected a RIFF/WEBP signature, got % x", name, data[:12])
+		}
+		loaded, err := svc.LoadImage(path)
+		if err != nil {
+			t.Fatalf("%s: LoadImage failed: %v", name, err)
+		}
+		if loaded.Bounds().Dx() != 64 || loaded.Bounds().Dy() != 48 {
+			t.Errorf("%s: expected 64x48, got %v", name, loaded.Bounds())
+		}
+	}
+}
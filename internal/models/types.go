@@ -1,37 +1,200 @@
 // Package models defines shared data types for the image border application.
 package models
 
-// TextOverlay represents text to draw on image.
+// Overlay types.
+const (
+	OverlayText  = "text"  // default when type is empty
+	OverlayShape = "shape" // filled rect or ellipse
+	OverlayTable = "table" // grid of label/value cells
+	OverlayStack = "stack" // lays out other overlays one after another
+)
+
+// TextOverlay represents text or a shape to draw on image.
 type TextOverlay struct {
-	Text     string `json:"text"`
-	Position string `json:"position"` // format: "x,y"
-	FontSize int    `json:"fontsize"`
-	Color    string `json:"color"`
+	Type        string `json:"type,omitempty"` // text (default), shape, table or stack
+	Text        string `json:"text"`
+	Position    string `json:"position"` // format: "x,y"
+	FontSize    int    `json:"fontsize"`
+	Color       string `json:"color"`                 // color, gradient, or "auto" to pick LightColor or DarkColor by contrast
+	LightColor  string `json:"lightcolor,omitempty"`  // auto color pair, default white
+	DarkColor   string `json:"darkcolor,omitempty"`   // default black
+	Stroke      string `json:"stroke,omitempty"`      // outline color or gradient drawn around text, none if empty
+	StrokeWidth int    `json:"strokewidth,omitempty"` // outline width in pixels, default 1/20 of the font size
+	MaxWidth    int    `json:"maxwidth,omitempty"`    // wrap width in pixels, 0 = no wrapping
+	Align       string `json:"align,omitempty"`       // left (default), center, right; tables take one per column, e.g. "left,right"
+	Path        string `json:"path,omitempty"`        // "cx,cy,radius,startDeg[,cw|ccw]" curves text along a circle; replaces position
+	Shape       string `json:"shape,omitempty"`       // rect (default) or ellipse, shape overlays only
+	Width       int    `json:"width,omitempty"`       // shape size in pixels
+	Height      int    `json:"height,omitempty"`
+
+	// Table overlays: cell templates per row, column widths and row height in pixels
+	Rows      [][]string `json:"rows,omitempty"`
+	Columns   []int      `json:"columns,omitempty"`
+	RowHeight int        `json:"rowheight,omitempty"` // default 1.6 x font size
+	Border    string     `json:"border,omitempty"`    // grid line color, none if empty
+	Zebra     string     `json:"zebra,omitempty"`     // fill for every second row, none if empty
+
+	// Stack overlays: keys of the overlays to lay out, in order, from Position.
+	// Align is left/center/right for vertical stacks, top/middle/bottom for horizontal.
+	Children  []string `json:"children,omitempty"`
+	Direction string   `json:"direction,omitempty"` // vertical (default) or horizontal
+	Gap       int      `json:"gap,omitempty"`       // pixels between child boxes
 }
 
 // TemplateConfig represents parsed template configuration.
 type TemplateConfig struct {
-	Background string                 `json:"background,omitempty"`
-	Fields     map[string]TextOverlay `json:"-"`
-	FieldOrder []string               `json:"-"` // Preserves field order from JSON
-	Raw        map[string]interface{} `json:"-"`
+	Background      string                 `json:"background,omitempty"`
+	NormalizeNames  bool                   `json:"normalize_names,omitempty"`  // match field names regardless of Unicode composition
+	FilenamePattern string                 `json:"filename_pattern,omitempty"` // regex with named groups filling fields from file names
+	Fields          map[string]TextOverlay `json:"-"`
+	FieldOrder      []string               `json:"-"` // Preserves field order from JSON
+	Raw             map[string]interface{} `json:"-"`
 }
 
 // ProcessRequest represents batch processing request from frontend.
 type ProcessRequest struct {
-	ProductImages []string          `json:"productImages"`
-	FrameImage    string            `json:"frameImage"`
-	TemplatePath  string            `json:"templatePath"`
-	FieldValues   map[string]string `json:"fieldValues"`
-	OutputDir     string            `json:"outputDir"`
-	Format        string            `json:"format"` // png, jpg, webp
-	Quality       int               `json:"quality"`
+	ProductImages   []string          `json:"productImages"`
+	FrameImage      string            `json:"frameImage"`
+	TemplatePath    string            `json:"templatePath"`
+	FieldValues     map[string]string `json:"fieldValues"`
+	OutputDir       string            `json:"outputDir"`
+	Format          string            `json:"format"` // png, jpg, webp
+	Quality         int               `json:"quality"`
+	Lossless        bool              `json:"lossless,omitempty"`        // lossless WebP, ignoring Quality
+	DataSource      *DataSource       `json:"dataSource,omitempty"`      // per-image values overriding FieldValues
+	FilenamePattern string            `json:"filenamePattern,omitempty"` // overrides the template's filename_pattern
+	Precedence      []string          `json:"precedence,omitempty"`      // value sources, lowest priority first
+	Variants        []Variant         `json:"variants,omitempty"`        // outputs per product, one plain output if empty
+	Frames          []Frame           `json:"frames,omitempty"`          // frames chosen per image; FrameImage is the fallback
+	FrameMode       string            `json:"frameMode,omitempty"`       // match (default) or all
+	Workers         int               `json:"workers,omitempty"`         // products rendered at once, 0 = one per CPU within the memory budget
+	PixelBudget     int64             `json:"pixelBudget,omitempty"`     // decoded product pixels this batch holds at once, within the app's shared budget, 0 = no own limit
+	MaxImageWidth   int               `json:"maxImageWidth,omitempty"`   // largest product or frame accepted, 0 = 8192
+	MaxImageHeight  int               `json:"maxImageHeight,omitempty"`
+	DownscaleLarge  bool              `json:"downscaleLarge,omitempty"` // decode baseline JPEGs over the limits at reduced scale; progressive, CMYK and non-JPEG files are still rejected
+}
+
+// Frame modes.
+const (
+	FrameMatch = "match" // the first frame whose rules fit the image
+	FrameAll   = "all"   // one output per frame, rules ignored
+)
+
+// Frame orientations, compared with the product image.
+const (
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "landscape"
+	OrientationSquare    = "square"
+)
+
+// Frame is one frame of a batch with the rules an image must meet to use it.
+// A frame without rules fits every image.
+type Frame struct {
+	Path        string  `json:"path"`
+	Name        string  `json:"name,omitempty"`        // added to the output file name in "all" mode, the file name if empty
+	Orientation string  `json:"orientation,omitempty"` // portrait, landscape or square
+	MinAspect   float64 `json:"minAspect,omitempty"`   // product width / height, no bound if 0
+	MaxAspect   float64 `json:"maxAspect,omitempty"`
+	Field       string  `json:"field,omitempty"` // field such as "category" that must equal Value
+	Value       string  `json:"value,omitempty"` // compared ignoring case; several values separated by "|"
+}
+
+// Variant is one output per product with its own values, such as a
+// regular and a flash-sale version.
+type Variant struct {
+	Name         string            `json:"name"`                   // added to the output file name
+	FieldValues  map[string]string `json:"fieldValues"`            // over the request's FieldValues and every per-image source
+	TemplatePath string            `json:"templatePath,omitempty"` // the request's template if empty
+}
+
+// Field value sources, ordered by ProcessRequest.Precedence. By default
+// fields < filename < sidecar < datasource; sources left out of Precedence
+// keep that order below the listed ones. The shop profile is always lowest
+// and a variant's own FieldValues always highest.
+const (
+	ValuesFields     = "fields"     // the request's FieldValues
+	ValuesFilename   = "filename"   // captures of the file name pattern
+	ValuesSidecar    = "sidecar"    // JSON file beside the image
+	ValuesDataSource = "datasource" // the image's CSV/XLSX row
+)
+
+// SidecarValues shows the sidecar found for a product image.
+type SidecarValues struct {
+	File    string            `json:"file"`
+	Sidecar string            `json:"sidecar,omitempty"` // sidecar file name, empty if none
+	Values  map[string]string `json:"values"`            // values read from the sidecar
+	Applied map[string]string `json:"applied"`           // sidecar values not overridden by a higher source
+}
+
+// FilenameValues shows the field values a product file name yields.
+type FilenameValues struct {
+	File    string            `json:"file"`
+	Matched bool              `json:"matched"`
+	Values  map[string]string `json:"values"`
+}
+
+// Data source match modes.
+const (
+	MatchFilename = "filename" // column holds the image file name, with or without extension
+	MatchSKU      = "sku"      // column holds a SKU that starts the image file name
+	MatchGlob     = "glob"     // column holds a file name pattern such as "ghe-*.jpg"
+)
+
+// DataSource is a CSV or XLSX sheet with one row of field values per product.
+// The header row names the fields.
+type DataSource struct {
+	Path    string `json:"path"`              // .csv or .xlsx file
+	Sheet   string `json:"sheet,omitempty"`   // xlsx sheet name, the first sheet if empty
+	MatchBy string `json:"matchBy,omitempty"` // filename (default), sku or glob
+	Column  string `json:"column,omitempty"`  // match column, defaults to "file", "sku" or "pattern"
+
+	// RequireMatch stops a batch before processing, after the report, if
+	// any image has no row.
+	RequireMatch bool `json:"requireMatch,omitempty"`
+}
+
+// DataSourceReport tells how spreadsheet rows matched the product images.
+type DataSourceReport struct {
+	Rows            int      `json:"rows"`
+	MatchedImages   int      `json:"matchedImages"`
+	UnmatchedImages []string `json:"unmatchedImages"` // image file names with no row
+	UnmatchedRows   []string `json:"unmatchedRows"`   // "row 5 (SKU123)" for rows matching no image
 }
 
 // ProcessProgress represents progress update during batch processing.
 type ProcessProgress struct {
-	Current int    `json:"current"`
-	Total   int    `json:"total"`
-	File    string `json:"file"`
-	Success bool   `json:"success"`
+	Current    int               `json:"current"`
+	Total      int               `json:"total"`
+	File       string            `json:"file"`
+	Variant    string            `json:"variant,omitempty"`
+	Frame      string            `json:"frame,omitempty"` // frame file name
+	Success    bool              `json:"success"`
+	Warnings   []string          `json:"warnings,omitempty"`
+	AutoColors map[string]string `json:"autoColors,omitempty"` // overlay key, or table key and cell as in "specs[0,1]", -> color chosen for "auto"
+
+	// Decoded product pixels held by the workers and the budget they fit
+	PixelsInUse int64 `json:"pixelsInUse"`
+	PixelBudget int64 `json:"pixelBudget"`
+}
+
+// PreviewResult holds a preview image and rendering warnings.
+type PreviewResult struct {
+	Image      string            `json:"image"` // data URL
+	Warnings   []string          `json:"warnings"`
+	AutoColors map[string]string `json:"autoColors,omitempty"` // overlay key, or table key and cell as in "specs[0,1]", -> color chosen for "auto"
+}
+
+// OverlayBox describes where an overlay's text lands on the canvas.
+type OverlayBox struct {
+	Key        string   `json:"key"`
+	X          int      `json:"x"`
+	Y          int      `json:"y"`
+	Width      int      `json:"width"`
+	Height     int      `json:"height"`
+	Baseline   int      `json:"baseline"` // y of the first line's baseline
+	FontSize   float64  `json:"fontSize"`
+	Lines      []string `json:"lines"`
+	Clipped    bool     `json:"clipped"`              // box extends past the canvas edge
+	Missing    string   `json:"missing,omitempty"`    // characters no font could draw
+	Unmeasured bool     `json:"unmeasured,omitempty"` // text needs EXIF tags of each image, so it has no box
 }
@@ -0,0 +1,106 @@
+// Package template provides dynamic placeholders filled per image.
+package template
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+
+	"vibe-imageborder/internal/models"
+)
+
+// dynamicPrefixes start placeholders resolved per image by ResolveDynamic.
+var dynamicPrefixes = []string{"file.", "batch.", "image.", "date:", "exif."}
+
+// dateRegex matches [date:layout] placeholders using Go time layouts.
+var dateRegex = regexp.MustCompile(`\[date:([^\]]+)\]`)
+
+// ImageInfo describes the image being processed.
+type ImageInfo struct {
+	Path   string    // source image path
+	Index  int       // 1-based position in the batch
+	Total  int       // images in the batch
+	Width  int       // source image width in pixels
+	Height int       // source image height in pixels
+	Time   time.Time // batch start, for [date:...]
+
+	// EXIF tags of the source image by name, for [exif.DateTimeOriginal]
+	EXIF map[string]string
+}
+
+// Values returns the dynamic placeholder values for the image:
+// file.name, file.stem, batch.index, batch.total, image.width, image.height
+// and exif.<tag> for each EXIF tag. Names and tags are converted to NFC,
+// like template text, as macOS file names come decomposed.
+func (info ImageInfo) Values() map[string]string {
+	name := norm.NFC.String(filepath.Base(info.Path))
+	values := map[string]string{
+		"file.name":    name,
+		"file.stem":    strings.TrimSuffix(name, filepath.Ext(name)),
+		"batch.index":  strconv.Itoa(info.Index),
+		"batch.total":  strconv.Itoa(info.Total),
+		"image.width":  strconv.Itoa(info.Width),
+		"image.height": strconv.Itoa(info.Height),
+	}
+	for tag, value := range info.EXIF {
+		values["exif."+tag] = norm.NFC.String(value)
+	}
+	return values
+}
+
+// isDynamicField reports whether a placeholder is resolved per image.
+func isDynamicField(field string) bool {
+	for _, prefix := range dynamicPrefixes {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasUnfilled reports whether text has placeholders other than dynamic ones,
+// which ApplyValues leaves for ResolveDynamic.
+func hasUnfilled(text string) bool {
+	for _, match := range fieldRegex.FindAllStringSubmatch(text, -1) {
+		if !isDynamicField(match[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveDynamic fills dynamic placeholders for one image in overlays from
+// ApplyValues. Overlays and table rows left with unknown placeholders are
+// hidden, as ApplyValues does for unfilled fields. Overlays is not modified.
+func ResolveDynamic(overlays map[string]models.TextOverlay, info ImageInfo) map[string]models.TextOverlay {
+	values := info.Values()
+	fill := func(text string) string {
+		if !strings.Contains(text, "[") {
+			return text
+		}
+		text = dateRegex.ReplaceAllStringFunc(text, func(m string) string {
+			return info.Time.Format(dateRegex.FindStringSubmatch(m)[1])
+		})
+		return replacePlaceholders(text, values)
+	}
+
+	result := make(map[string]models.TextOverlay, len(overlays))
+	for key, overlay := range overlays {
+		overlay.Text = fill(overlay.Text)
+		if fieldRegex.MatchString(overlay.Text) {
+			continue
+		}
+		if overlay.Type == models.OverlayTable {
+			overlay.Rows = fillRows(overlay.Rows, fill, fieldRegex.MatchString)
+			if len(overlay.Rows) == 0 {
+				continue
+			}
+		}
+		result[key] = overlay
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vibe-imageborder/internal/models"
+)
+
+func TestResolveDynamic(t *testing.T) {
+	content := `{
+    "code": {"text": "[file.stem] ([batch.index]/[batch.total])", "position": "10,10"},
+    "size": {"text": "[image.width]x[image.height] [file.name]", "position": "10,50"},
+    "date": {"text": "[date:02/01/2006] [price]K", "position": "10,90"},
+    "bad": {"text": "[file.unknown]", "position": "10,130"},
+    "specs": {"type": "table", "position": "10,170", "rows": [["File", "[file.name]"], ["Ngày", "[date:2006]"], ["X", "[image.depth]"]]}
+}`
+	tmpFile := filepath.Join(t.TempDir(), "dynamic.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	config, err := ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	fields := ExtractFields(config)
+	if len(fields) != 1 || fields[0] != "price" {
+		t.Errorf("Expected dynamic placeholders to be skipped, got %v", fields)
+	}
+
+	// ApplyValues keeps overlays waiting on dynamic placeholders
+	overlays := ApplyValues(config, map[string]string{"price": "99"})
+	if len(overlays) != 5 {
+		t.Fatalf("Expected all overlays kept, got %d", len(overlays))
+	}
+
+	info := ImageInfo{
+		Path:   filepath.Join("photos", "ghe-go.jpg"),
+		Index:  3,
+		Total:  12,
+		Width:  1200,
+		Height: 800,
+		Time:   time.Date(2026, 3, 9, 10, 0, 0, 0, time.UTC),
+	}
+	resolved := ResolveDynamic(overlays, info)
+
+	expected := map[string]string{
+		"code": "ghe-go (3/12)",
+		"size": "1200x800 ghe-go.jpg",
+		"date": "09/03/2026 99K",
+	}
+	for key, text := range expected {
+		if got := resolved[key].Text; got != text {
+			t.Errorf("%s: expected %q, got %q", key, text, got)
+		}
+	}
+	if _, ok := resolved["bad"]; ok {
+		t.Error("Expected overlay with unknown placeholder to be hidden")
+	}
+	rows := resolved["specs"].Rows
+	if len(rows) != 2 || rows[0][1] != "ghe-go.jpg" || rows[1][1] != "2026" {
+		t.Errorf("Unexpected table rows %v", rows)
+	}
+
+	// The batch overlays are reused for the next image
+	if overlays["code"].Text != "[file.stem] ([batch.index]/[batch.total])" {
+		t.Errorf("Expected input overlays untouched, got %q", overlays["code"].Text)
+	}
+	if got := ResolveDynamic(overlays, ImageInfo{Path: "b.png", Index: 4, Total: 12})["code"].Text; got != "b (4/12)" {
+		t.Errorf("Expected next image resolved separately, got %q", got)
+	}
+}
+
+func TestResolveDynamicKeepsPlainOverlays(t *testing.T) {
+	overlays := map[string]models.TextOverlay{
+		"title": {Text: "Sale", Position: "0,0"},
+		"band":  {Type: models.OverlayShape, Position: "0,0", Width: 10, Height: 10},
+	}
+	resolved := ResolveDynamic(overlays, ImageInfo{})
+	if len(resolved) != 2 || resolved["title"].Text != "Sale" {
+		t.Errorf("Unexpected overlays %v", resolved)
+	}
+}
+
+func TestResolveDynamicEXIF(t *testing.T) {
+	overlays := map[string]models.TextOverlay{
+		"shot":  {Text: "Chụp lúc [exif.DateTimeOriginal]", Position: "0,0"},
+		"where": {Text: "[exif.GPSLatitude], [exif.GPSLongitude]", Position: "0,40"},
+	}
+	resolved := ResolveDynamic(overlays, ImageInfo{EXIF: map[string]string{"DateTimeOriginal": "2024:03:09 10:15:00"}})
+	if got := resolved["shot"].Text; got != "Chụp lúc 2024:03:09 10:15:00" {
+		t.Errorf("Unexpected text %q", got)
+	}
+	if _, ok := resolved["where"]; ok {
+		t.Error("Expected overlay to be hidden when the photo has no GPS tags")
+	}
+}
+
+func TestResolveDynamicNFC(t *testing.T) {
+	// macOS file names are NFD: "Áo" as A + combining acute
+	overlays := map[string]models.TextOverlay{
+		"name":   {Text: "[file.stem]", Position: "0,0"},
+		"artist": {Text: "[exif.Artist]", Position: "0,40"},
+	}
+	info := ImageInfo{
+		Path: "/photos/A\u0301o da\u0300i.jpg",
+		EXIF: map[string]string{"Artist": "Nguye\u0302\u0303n"},
+	}
+	resolved := ResolveDynamic(overlays, info)
+	if got := resolved["name"].Text; got != "\u00c1o d\u00e0i" {
+		t.Errorf("Expected NFC file stem, got %q", got)
+	}
+	if got := resolved["artist"].Text; got != "Nguy\u1ec5n" {
+		t.Errorf("Expected NFC EXIF value, got %q", got)
+	}
+}
@@ -0,0 +1,43 @@
+// Package template provides field values captured from product file names.
+package template
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CompileFilenamePattern compiles a file name pattern such as
+// `(?P<barcode>\w+)_(?P<price>\d+)K`. Each named group fills the field of
+// that name, so the pattern needs at least one.
+func CompileFilenamePattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filename pattern: %w", err)
+	}
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			return re, nil
+		}
+	}
+	return nil, fmt.Errorf("filename pattern has no named groups like (?P<price>...)")
+}
+
+// FilenameValues matches re against the file name of path without its
+// extension and returns the named groups that captured text, or nil if the
+// name does not match.
+func FilenameValues(re *regexp.Regexp, path string) map[string]string {
+	name := filepath.Base(path)
+	match := re.FindStringSubmatch(strings.TrimSuffix(name, filepath.Ext(name)))
+	if match == nil {
+		return nil
+	}
+	values := make(map[string]string)
+	for i, group := range re.SubexpNames() {
+		if group != "" && match[i] != "" {
+			values[group] = match[i]
+		}
+	}
+	return values
+}
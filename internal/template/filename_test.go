@@ -0,0 +1,74 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilenameValues(t *testing.T) {
+	re, err := CompileFilenamePattern(`^(?P<barcode>[A-Z0-9]+)_(?P<price>\d+)K_(?P<size_dai>\d+)x(?P<size_rong>\d+)x(?P<size_cao>\d+)(?:_(?P<note>\w+))?$`)
+	if err != nil {
+		t.Fatalf("CompileFilenamePattern failed: %v", err)
+	}
+
+	values := FilenameValues(re, filepath.Join("photos", "SKU123_450K_30x20x15.jpg"))
+	expected := map[string]string{"barcode": "SKU123", "price": "450", "size_dai": "30", "size_rong": "20", "size_cao": "15"}
+	if len(values) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	for field, value := range expected {
+		if values[field] != value {
+			t.Errorf("%s: expected %q, got %q", field, value, values[field])
+		}
+	}
+
+	if got := FilenameValues(re, "SKU9_99K_1x2x3_moi.png")["note"]; got != "moi" {
+		t.Errorf("Expected optional group captured, got %q", got)
+	}
+	if values := FilenameValues(re, "IMG_0001.jpg"); values != nil {
+		t.Errorf("Expected no match, got %v", values)
+	}
+}
+
+func TestCompileFilenamePatternErrors(t *testing.T) {
+	if _, err := CompileFilenamePattern(`(\w+)_(\d+)`); err == nil || !strings.Contains(err.Error(), "named groups") {
+		t.Errorf("Expected error for pattern without named groups, got %v", err)
+	}
+	if _, err := CompileFilenamePattern(`(?P<price>\d+`); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+func TestFilenamePatternRoundTrip(t *testing.T) {
+	content := `{
+    "filename_pattern": "(?P<barcode>\\w+)_(?P<price>\\d+)K",
+    "price": {"text": "[price]K", "position": "10,10"}
+}`
+	tmpFile := filepath.Join(t.TempDir(), "pattern.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	config, err := ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	if config.FilenamePattern != `(?P<barcode>\w+)_(?P<price>\d+)K` {
+		t.Fatalf("Unexpected pattern %q", config.FilenamePattern)
+	}
+	if _, ok := config.Fields["filename_pattern"]; ok {
+		t.Error("Expected filename_pattern not to be an overlay")
+	}
+
+	if err := SaveTemplate(tmpFile, config); err != nil {
+		t.Fatalf("SaveTemplate failed: %v", err)
+	}
+	reloaded, err := ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate after save failed: %v", err)
+	}
+	if reloaded.FilenamePattern != config.FilenamePattern {
+		t.Errorf("Expected pattern to round-trip, got %q", reloaded.FilenamePattern)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 
+	"golang.org/x/text/unicode/norm"
 	"vibe-imageborder/internal/models"
 )
 
@@ -63,6 +64,20 @@ func ParseTemplate(path string) (*models.TemplateConfig, error) {
 			continue
 		}
 
+		if key == "normalize_names" {
+			if on, ok := val.(bool); ok {
+				config.NormalizeNames = on
+			}
+			continue
+		}
+
+		if key == "filename_pattern" {
+			if pattern, ok := val.(string); ok {
+				config.FilenamePattern = pattern
+			}
+			continue
+		}
+
 		overlay, err := parseOverlay(val)
 		if err != nil {
 			continue // Skip non-overlay fields
@@ -89,9 +104,14 @@ func parseOverlay(val interface{}) (models.TextOverlay, error) {
 
 	overlay := models.TextOverlay{}
 
+	if kind, ok := m["type"].(string); ok {
+		overlay.Type = kind
+	}
+
+	// Shapes, tables and stacks have no text; plain text overlays need it
 	if text, ok := m["text"].(string); ok {
 		overlay.Text = text
-	} else {
+	} else if overlay.Type == "" || overlay.Type == models.OverlayText {
 		return overlay, fmt.Errorf("missing text field")
 	}
 
@@ -99,13 +119,8 @@ func parseOverlay(val interface{}) (models.TextOverlay, error) {
 		overlay.Position = pos
 	}
 
-	if fs, ok := m["fontsize"].(string); ok {
-		size, err := strconv.Atoi(fs)
-		if err != nil || size <= 0 {
-			overlay.FontSize = defaultFontSize
-		} else {
-			overlay.FontSize = size
-		}
+	if size, ok := intValue(m["fontsize"]); ok && size > 0 {
+		overlay.FontSize = size
 	} else {
 		overlay.FontSize = defaultFontSize
 	}
@@ -114,10 +129,131 @@ func parseOverlay(val interface{}) (models.TextOverlay, error) {
 		overlay.Color = color
 	}
 
+	if light, ok := m["lightcolor"].(string); ok {
+		overlay.LightColor = light
+	}
+
+	if dark, ok := m["darkcolor"].(string); ok {
+		overlay.DarkColor = dark
+	}
+
+	if stroke, ok := m["stroke"].(string); ok {
+		overlay.Stroke = stroke
+	}
+
+	if width, ok := intValue(m["strokewidth"]); ok && width > 0 {
+		overlay.StrokeWidth = width
+	}
+
+	if width, ok := intValue(m["maxwidth"]); ok && width > 0 {
+		overlay.MaxWidth = width
+	}
+
+	if align, ok := m["align"].(string); ok {
+		overlay.Align = align
+	}
+
+	if path, ok := m["path"].(string); ok {
+		overlay.Path = path
+	}
+
+	if shape, ok := m["shape"].(string); ok {
+		overlay.Shape = shape
+	}
+
+	if width, ok := intValue(m["width"]); ok && width > 0 {
+		overlay.Width = width
+	}
+
+	if height, ok := intValue(m["height"]); ok && height > 0 {
+		overlay.Height = height
+	}
+
+	if rows, ok := m["rows"].([]interface{}); ok {
+		for _, row := range rows {
+			cells, ok := row.([]interface{})
+			if !ok {
+				return overlay, fmt.Errorf("table row must be an array")
+			}
+			var parsed []string
+			for _, cell := range cells {
+				text, _ := cell.(string)
+				parsed = append(parsed, text)
+			}
+			overlay.Rows = append(overlay.Rows, parsed)
+		}
+	}
+
+	overlay.Columns = intList(m["columns"])
+
+	if rowHeight, ok := intValue(m["rowheight"]); ok && rowHeight > 0 {
+		overlay.RowHeight = rowHeight
+	}
+
+	if border, ok := m["border"].(string); ok {
+		overlay.Border = border
+	}
+
+	if zebra, ok := m["zebra"].(string); ok {
+		overlay.Zebra = zebra
+	}
+
+	if children, ok := m["children"].([]interface{}); ok {
+		for _, child := range children {
+			if key, ok := child.(string); ok {
+				overlay.Children = append(overlay.Children, key)
+			}
+		}
+	}
+
+	if direction, ok := m["direction"].(string); ok {
+		overlay.Direction = direction
+	}
+
+	if gap, ok := intValue(m["gap"]); ok && gap > 0 {
+		overlay.Gap = gap
+	}
+
 	return overlay, nil
 }
 
+// intList reads integers from a "200,300" string or a JSON array.
+// Invalid entries are skipped.
+func intList(val interface{}) []int {
+	var items []interface{}
+	switch v := val.(type) {
+	case string:
+		for _, part := range strings.Split(v, ",") {
+			items = append(items, part)
+		}
+	case []interface{}:
+		items = v
+	}
+
+	var result []int
+	for _, item := range items {
+		if n, ok := intValue(item); ok {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// intValue reads an integer stored either as a JSON string or a number.
+func intValue(val interface{}) (int, bool) {
+	switch v := val.(type) {
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		return n, err == nil
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
 // ExtractFields returns unique field names from template in order.
+// Reserved placeholders such as [shop.hotline] are filled by the app and
+// are not listed.
 func ExtractFields(config *models.TemplateConfig) []string {
 	seen := make(map[string]bool)
 	fields := []string{}
@@ -125,11 +261,13 @@ func ExtractFields(config *models.TemplateConfig) []string {
 	// Iterate in preserved order
 	for _, key := range config.FieldOrder {
 		overlay := config.Fields[key]
-		matches := fieldRegex.FindAllStringSubmatch(overlay.Text, -1)
-		for _, match := range matches {
-			if len(match) > 1 && !seen[match[1]] {
-				seen[match[1]] = true
-				fields = append(fields, match[1])
+		for _, text := range overlayTexts(overlay) {
+			matches := fieldRegex.FindAllStringSubmatch(normalizeText(text, config.NormalizeNames), -1)
+			for _, match := range matches {
+				if len(match) > 1 && !seen[match[1]] && !isReservedField(match[1]) {
+					seen[match[1]] = true
+					fields = append(fields, match[1])
+				}
 			}
 		}
 	}
@@ -137,24 +275,101 @@ func ExtractFields(config *models.TemplateConfig) []string {
 	return fields
 }
 
+// overlayTexts returns the overlay text followed by every table cell, row by row.
+func overlayTexts(overlay models.TextOverlay) []string {
+	texts := []string{overlay.Text}
+	for _, row := range overlay.Rows {
+		texts = append(texts, row...)
+	}
+	return texts
+}
+
 // ApplyValues replaces placeholders with actual values.
-// Skips overlays that have unfilled placeholders.
+// Template text and values are normalized to NFC first so decomposed input
+// (as pasted on macOS) renders with correctly placed diacritics. Placeholder
+// names and value keys are left as written unless NormalizeNames is set.
+// Skips overlays that have unfilled placeholders; table rows with unfilled
+// placeholders are dropped, and a table with no rows left is skipped.
+// Dynamic placeholders such as [file.name] are kept for ResolveDynamic.
 func ApplyValues(config *models.TemplateConfig, values map[string]string) map[string]models.TextOverlay {
 	result := make(map[string]models.TextOverlay)
+	normalized := NormalizeValues(values, config.NormalizeNames)
+	fill := func(text string) string {
+		return replacePlaceholders(normalizeText(text, config.NormalizeNames), normalized)
+	}
 
 	for key, overlay := range config.Fields {
 		newOverlay := overlay
-		newOverlay.Text = replacePlaceholders(overlay.Text, values)
-		
+		newOverlay.Text = fill(overlay.Text)
+
 		// Skip if text still contains unfilled placeholders like [price]
-		if !fieldRegex.MatchString(newOverlay.Text) {
-			result[key] = newOverlay
+		if hasUnfilled(newOverlay.Text) {
+			continue
+		}
+
+		if overlay.Type == models.OverlayTable {
+			newOverlay.Rows = fillRows(overlay.Rows, fill, hasUnfilled)
+			if len(newOverlay.Rows) == 0 {
+				continue
+			}
+		}
+		result[key] = newOverlay
+	}
+
+	return result
+}
+
+// fillRows fills placeholders in table cells and drops rows with a cell
+// still unfilled.
+func fillRows(rows [][]string, fill func(string) string, unfilled func(string) bool) [][]string {
+	var filled [][]string
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		complete := true
+		for i, cell := range row {
+			cells[i] = fill(cell)
+			if unfilled(cells[i]) {
+				complete = false
+			}
+		}
+		if complete {
+			filled = append(filled, cells)
 		}
 	}
+	return filled
+}
 
+// NormalizeValues returns values converted to NFC.
+// When names is true the field names are normalized too, so a decomposed
+// key like "gia\u0301" fills the [giá] placeholder.
+func NormalizeValues(values map[string]string, names bool) map[string]string {
+	result := make(map[string]string, len(values))
+	for field, value := range values {
+		if names {
+			field = norm.NFC.String(field)
+		}
+		result[field] = norm.NFC.String(value)
+	}
 	return result
 }
 
+// normalizeText converts text to NFC. Placeholder names are converted only
+// when names is true, matching the keys from NormalizeValues.
+func normalizeText(text string, names bool) string {
+	if names {
+		return norm.NFC.String(text)
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range fieldRegex.FindAllStringIndex(text, -1) {
+		b.WriteString(norm.NFC.String(text[last:loc[0]]))
+		b.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(norm.NFC.String(text[last:]))
+	return b.String()
+}
+
 // replacePlaceholders substitutes [field] with values.
 func replacePlaceholders(text string, values map[string]string) string {
 	result := text
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"sort"
 	"testing"
+
+	"vibe-imageborder/internal/models"
 )
 
 func TestParseTemplate(t *testing.T) {
@@ -149,3 +151,221 @@ func TestParseNonexistentFile(t *testing.T) {
 		t.Error("Expected error for nonexistent file, got nil")
 	}
 }
+
+func TestApplyValuesNormalizesNFC(t *testing.T) {
+	// Decomposed (NFD) "giá": base letter followed by U+0301 combining acute
+	nfd, nfc := "gia\u0301", "gi\u00e1"
+	content := "{\n\t\"decomposed\": {\n\t\t\"text\": \"Gia\u0301 [gia\u0301]K\",\n\t\t\"position\": \"10,10\"\n\t},\n" +
+		"\t\"composed\": {\n\t\t\"text\": \"Gi\u00e1 [gi\u00e1]K\",\n\t\t\"position\": \"10,60\"\n\t}\n}"
+
+	tmpFile := filepath.Join(t.TempDir(), "nfd.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	config, err := ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	fields := ExtractFields(config)
+	if len(fields) != 2 || fields[0] != nfd || fields[1] != nfc {
+		t.Fatalf("Expected field names as written, got %q", fields)
+	}
+
+	// Placeholders and keys in the same form match; text and values are composed
+	result := ApplyValues(config, map[string]string{nfd: "Ha\u0300 No\u0323\u0302i", nfc: "500"})
+	if got := result["decomposed"].Text; got != "Gi\u00e1 H\u00e0 N\u1ed9iK" {
+		t.Errorf("Expected NFD key to fill NFD placeholder with composed text, got %q", got)
+	}
+	if got := result["composed"].Text; got != "Gi\u00e1 500K" {
+		t.Errorf("Expected NFC key to fill NFC placeholder, got %q", got)
+	}
+
+	// Forms only cross-match when name normalization is enabled
+	if len(ApplyValues(config, map[string]string{nfd: "500"})) != 1 {
+		t.Error("Expected NFD key to fill only the NFD placeholder without normalize_names")
+	}
+
+	config.NormalizeNames = true
+	if fields := ExtractFields(config); len(fields) != 1 || fields[0] != nfc {
+		t.Errorf("Expected one composed field name, got %q", fields)
+	}
+	for _, key := range []string{nfd, nfc} {
+		result := ApplyValues(config, map[string]string{key: "500"})
+		if result["decomposed"].Text != "Gi\u00e1 500K" || result["composed"].Text != "Gi\u00e1 500K" {
+			t.Errorf("Expected key %q to fill both placeholders, got %v", key, result)
+		}
+	}
+}
+
+func TestParseNormalizeNamesOption(t *testing.T) {
+	content := `{"normalize_names": true, "a": {"text": "[a]"}}`
+
+	tmpFile := filepath.Join(t.TempDir(), "opt.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	config, err := ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	if !config.NormalizeNames {
+		t.Error("Expected normalize_names to be parsed")
+	}
+	if len(config.Fields) != 1 {
+		t.Errorf("Expected 1 field, got %d", len(config.Fields))
+	}
+}
+
+func TestParseShapeOverlay(t *testing.T) {
+	content := `{
+    "band": {"type": "shape", "shape": "ellipse", "position": "0,900", "width": "1080", "height": 180, "color": "linear(90deg,#ff0000,#ffcc00)"},
+    "broken": {"position": "0,0"}
+}`
+
+	tmpFile := filepath.Join(t.TempDir(), "shape.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	config, err := ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	if len(config.Fields) != 1 {
+		t.Fatalf("Expected only the shape overlay, got %v", config.FieldOrder)
+	}
+
+	band := config.Fields["band"]
+	if band.Type != models.OverlayShape || band.Shape != "ellipse" || band.Width != 1080 || band.Height != 180 {
+		t.Errorf("Unexpected shape overlay %+v", band)
+	}
+	if band.Color != "linear(90deg,#ff0000,#ffcc00)" {
+		t.Errorf("Expected gradient color kept, got %q", band.Color)
+	}
+	if _, ok := ApplyValues(config, nil)["band"]; !ok {
+		t.Error("Expected shape overlay kept by ApplyValues")
+	}
+}
+
+func TestParseAutoColorPair(t *testing.T) {
+	content := `{"price": {"text": "[price]", "color": "auto", "lightcolor": "#ffcc00", "darkcolor": "#333333"}}`
+
+	tmpFile := filepath.Join(t.TempDir(), "auto.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	config, err := ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	price := config.Fields["price"]
+	if price.Color != "auto" || price.LightColor != "#ffcc00" || price.DarkColor != "#333333" {
+		t.Errorf("Unexpected auto color overlay %+v", price)
+	}
+}
+
+func TestParseStroke(t *testing.T) {
+	content := `{"seal": {"text": "[seal]", "path": "200,200,120,0", "stroke": "#ffffff", "strokewidth": "4"}}`
+
+	tmpFile := filepath.Join(t.TempDir(), "stroke.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	config, err := ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	seal := config.Fields["seal"]
+	if seal.Stroke != "#ffffff" || seal.StrokeWidth != 4 {
+		t.Errorf("Unexpected stroke overlay %+v", seal)
+	}
+}
+
+func TestParseTableOverlay(t *testing.T) {
+	content := `{
+    "specs": {
+        "type": "table",
+        "position": "100,1400",
+        "fontsize": "36",
+        "columns": "200,300",
+        "align": "left,right",
+        "border": "#cccccc",
+        "rows": [["Dài", "[size_dai] cm"], ["Rộng", "[size_rong] cm"], ["Cao", "[size_cao] cm"]]
+    },
+    "price": {"text": "[price]K"}
+}`
+
+	tmpFile := filepath.Join(t.TempDir(), "table.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	config, err := ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	specs := config.Fields["specs"]
+	if specs.Type != models.OverlayTable || len(specs.Rows) != 3 || len(specs.Columns) != 2 || specs.Columns[1] != 300 {
+		t.Fatalf("Unexpected table overlay %+v", specs)
+	}
+
+	fields := ExtractFields(config)
+	expected := []string{"size_dai", "size_rong", "size_cao", "price"}
+	if len(fields) != len(expected) {
+		t.Fatalf("Expected fields %v, got %v", expected, fields)
+	}
+	for i, f := range expected {
+		if fields[i] != f {
+			t.Errorf("Field %d: expected %s, got %s", i, f, fields[i])
+		}
+	}
+
+	// Rows with unfilled placeholders are dropped
+	result := ApplyValues(config, map[string]string{"size_dai": "120", "size_cao": "75"})
+	rows := result["specs"].Rows
+	if len(rows) != 2 || rows[0][1] != "120 cm" || rows[1][0] != "Cao" {
+		t.Errorf("Unexpected filled rows %v", rows)
+	}
+	if _, ok := ApplyValues(config, nil)["specs"]; ok {
+		t.Error("Expected table with no filled rows to be skipped")
+	}
+}
+
+func TestParseStackOverlay(t *testing.T) {
+	content := `{
+    "info": {"type": "stack", "position": "100,1200", "direction": "vertical", "gap": "16", "children": ["name", "promo"]},
+    "name": {"text": "[name]", "position": "0,0"},
+    "promo": {"text": "[promo]", "position": "0,0"}
+}`
+
+	tmpFile := filepath.Join(t.TempDir(), "stack.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	config, err := ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	info, ok := config.Fields["info"]
+	if !ok {
+		t.Fatal("Expected stack overlay without text to be parsed")
+	}
+	if info.Type != models.OverlayStack || info.Gap != 16 || info.Direction != "vertical" || len(info.Children) != 2 || info.Children[1] != "promo" {
+		t.Errorf("Unexpected stack overlay %+v", info)
+	}
+
+	// The stack survives while a child is hidden, so the rest collapse
+	result := ApplyValues(config, map[string]string{"name": "Ghế gỗ"})
+	if _, ok := result["info"]; !ok {
+		t.Error("Expected stack to be kept")
+	}
+	if _, ok := result["promo"]; ok {
+		t.Error("Expected unfilled child to be hidden")
+	}
+}
@@ -0,0 +1,122 @@
+// Package template provides the shop profile shared by every template.
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ProfilePrefix starts placeholders filled from the shop profile, as in [shop.hotline].
+const ProfilePrefix = "shop."
+
+// LoadProfile reads the shop profile, a flat JSON object of names to values
+// such as {"hotline": "0909 123 456"}. A missing file is an empty profile.
+func LoadProfile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile: %w", err)
+	}
+
+	profile := map[string]string{}
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("invalid profile: %w", err)
+	}
+	return profile, nil
+}
+
+// SaveProfile writes the profile with sorted keys, creating its directory.
+// Names are trimmed and NFC normalized; a name that could not appear in a
+// placeholder is an error.
+func SaveProfile(path string, profile map[string]string) error {
+	cleaned := make(map[string]string, len(profile))
+	for name, value := range profile {
+		name = norm.NFC.String(strings.TrimSpace(name))
+		if name == "" || strings.ContainsAny(name, "[]") {
+			return fmt.Errorf("invalid profile field %q", name)
+		}
+		cleaned[name] = value
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", defaultIndent)
+	if err := enc.Encode(cleaned); err != nil {
+		return fmt.Errorf("failed to encode profile: %w", err)
+	}
+
+	cleanPath := filepath.Clean(path)
+	if err := os.MkdirAll(filepath.Dir(cleanPath), 0755); err != nil {
+		return fmt.Errorf("failed to create profile dir: %w", err)
+	}
+
+	// Write to a temp file first so a failed write never truncates the profile
+	tmp, err := os.CreateTemp(filepath.Dir(cleanPath), ".profile-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write profile: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write profile: %w", err)
+	}
+	if err := os.Rename(tmpPath, cleanPath); err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+	return nil
+}
+
+// ProfileValues returns the profile as placeholder values keyed "shop.<name>".
+func ProfileValues(profile map[string]string) map[string]string {
+	values := make(map[string]string, len(profile))
+	for name, value := range profile {
+		values[ProfilePrefix+name] = value
+	}
+	return values
+}
+
+// MergeValues returns defaults overridden by values. Neither map is modified.
+func MergeValues(defaults, values map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults)+len(values))
+	for field, value := range defaults {
+		merged[field] = value
+	}
+	for field, value := range values {
+		merged[field] = value
+	}
+	return merged
+}
+
+// LayerValues merges layers of values, lowest priority first. An empty value
+// never hides a value from a lower layer. No layer is modified.
+func LayerValues(layers ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, values := range layers {
+		for field, value := range values {
+			if _, ok := merged[field]; !ok || value != "" {
+				merged[field] = value
+			}
+		}
+	}
+	return merged
+}
+
+// isReservedField reports whether a placeholder is filled by the app rather
+// than typed in per batch.
+func isReservedField(field string) bool {
+	return strings.HasPrefix(field, ProfilePrefix) || isDynamicField(field)
+}
@@ -0,0 +1,100 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", "profile.json")
+
+	profile, err := LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile of missing file failed: %v", err)
+	}
+	if len(profile) != 0 {
+		t.Errorf("Expected empty profile, got %v", profile)
+	}
+
+	if err := SaveProfile(path, map[string]string{" hotline ": "0909 123 456", "website": "shop.vn?a=1&b=2"}); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+	profile, err = LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+	if profile["hotline"] != "0909 123 456" || profile["website"] != "shop.vn?a=1&b=2" {
+		t.Errorf("Unexpected profile %v", profile)
+	}
+
+	if err := SaveProfile(path, map[string]string{"bad]name": "x"}); err == nil {
+		t.Error("Expected error for name with brackets")
+	}
+	if err := SaveProfile(path, map[string]string{"  ": "x"}); err == nil {
+		t.Error("Expected error for empty name")
+	}
+}
+
+func TestServiceProfileValues(t *testing.T) {
+	dir := t.TempDir()
+	content := `{
+    "hotline": {"text": "Hotline: [shop.hotline]", "position": "10,10"},
+    "website": {"text": "[shop.website]", "position": "10,50"},
+    "email": {"text": "[shop.email]", "position": "10,90"},
+    "price": {"text": "[price]K", "position": "10,130"}
+}`
+	templatePath := filepath.Join(dir, "template.txt")
+	if err := os.WriteFile(templatePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	svc := NewService()
+	svc.SetProfilePath(filepath.Join(dir, "profile.json"))
+	if err := svc.SaveProfile(map[string]string{"hotline": "0909 123 456", "website": "shop.vn"}); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+
+	fields, err := svc.GetFields(templatePath)
+	if err != nil {
+		t.Fatalf("GetFields failed: %v", err)
+	}
+	if len(fields) != 1 || fields[0] != "price" {
+		t.Errorf("Expected only request fields, got %v", fields)
+	}
+
+	overlays, err := svc.GetOverlays(templatePath, map[string]string{"price": "99", "shop.website": "sale.shop.vn"})
+	if err != nil {
+		t.Fatalf("GetOverlays failed: %v", err)
+	}
+	if got := overlays["hotline"].Text; got != "Hotline: 0909 123 456" {
+		t.Errorf("Expected hotline from profile, got %q", got)
+	}
+	if got := overlays["website"].Text; got != "sale.shop.vn" {
+		t.Errorf("Expected request value to win over profile, got %q", got)
+	}
+	if _, ok := overlays["email"]; ok {
+		t.Error("Expected overlay with a field missing from the profile to be hidden")
+	}
+}
+
+func TestLayerValues(t *testing.T) {
+	// A flash-sale variant and the product's data source row both set price
+	fields := map[string]string{"price": "120", "name": "Ghế gỗ"}
+	row := map[string]string{"price": "99", "sku": "G-01", "name": ""}
+	variant := map[string]string{"price": "79", "badge": "FLASH SALE"}
+
+	merged := LayerValues(fields, row, nil, variant)
+	want := map[string]string{"price": "79", "name": "Ghế gỗ", "sku": "G-01", "badge": "FLASH SALE"}
+	if len(merged) != len(want) {
+		t.Errorf("Expected %v, got %v", want, merged)
+	}
+	for field, value := range want {
+		if merged[field] != value {
+			t.Errorf("Expected %s = %q, got %q", field, value, merged[field])
+		}
+	}
+	if row["price"] != "99" {
+		t.Error("Expected layers to be left unmodified")
+	}
+}
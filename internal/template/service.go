@@ -2,17 +2,43 @@
 package template
 
 import (
+	"fmt"
+
 	"vibe-imageborder/internal/models"
 )
 
 // Service handles template operations.
-type Service struct{}
+type Service struct {
+	profilePath string
+}
 
 // NewService creates new template service.
 func NewService() *Service {
 	return &Service{}
 }
 
+// SetProfilePath sets the shop profile file merged into every template's values.
+// An empty path disables the profile.
+func (s *Service) SetProfilePath(path string) {
+	s.profilePath = path
+}
+
+// GetProfile returns the shop profile (always fresh read).
+func (s *Service) GetProfile() (map[string]string, error) {
+	if s.profilePath == "" {
+		return map[string]string{}, nil
+	}
+	return LoadProfile(s.profilePath)
+}
+
+// SaveProfile replaces the shop profile.
+func (s *Service) SaveProfile(profile map[string]string) error {
+	if s.profilePath == "" {
+		return fmt.Errorf("no profile path configured")
+	}
+	return SaveProfile(s.profilePath, profile)
+}
+
 // LoadTemplate loads template from file (always fresh read).
 func (s *Service) LoadTemplate(path string) (*models.TemplateConfig, error) {
 	return ParseTemplate(path)
@@ -28,12 +54,23 @@ func (s *Service) GetFields(path string) ([]string, error) {
 }
 
 // GetOverlays returns text overlays with values applied.
+// Shop profile values fill [shop.*] placeholders unless values set them.
 func (s *Service) GetOverlays(path string, values map[string]string) (map[string]models.TextOverlay, error) {
 	config, err := s.LoadTemplate(path)
 	if err != nil {
 		return nil, err
 	}
-	return ApplyValues(config, values), nil
+	return s.ApplyValues(config, values)
+}
+
+// ApplyValues returns the overlays of a loaded template with values and the
+// shop profile applied, for callers filling one template many times.
+func (s *Service) ApplyValues(config *models.TemplateConfig, values map[string]string) (map[string]models.TextOverlay, error) {
+	profile, err := s.GetProfile()
+	if err != nil {
+		return nil, err
+	}
+	return ApplyValues(config, MergeValues(ProfileValues(profile), values)), nil
 }
 
 // GetBackground returns background color from template.
@@ -44,3 +81,25 @@ func (s *Service) GetBackground(path string) (string, error) {
 	}
 	return config.Background, nil
 }
+
+// SaveTemplate writes template config back to file.
+func (s *Service) SaveTemplate(path string, config *models.TemplateConfig) error {
+	return SaveTemplate(path, config)
+}
+
+// UpdateOverlay applies update to one overlay and persists the template.
+func (s *Service) UpdateOverlay(path, key string, update func(*models.TextOverlay)) error {
+	config, err := s.LoadTemplate(path)
+	if err != nil {
+		return err
+	}
+
+	overlay, ok := config.Fields[key]
+	if !ok {
+		return fmt.Errorf("overlay %q not found in template", key)
+	}
+	update(&overlay)
+	config.Fields[key] = overlay
+
+	return s.SaveTemplate(path, config)
+}
@@ -25,20 +25,24 @@ func TestServiceLoadTemplate(t *testing.T) {
 
 	svc := NewService()
 
-	// First load
 	config1, err := svc.LoadTemplate(tmpFile)
 	if err != nil {
 		t.Fatalf("LoadTemplate failed: %v", err)
 	}
+	if config1.Background != "#ffffff" {
+		t.Errorf("Expected #ffffff, got %s", config1.Background)
+	}
 
-	// Second load should return cached
+	// Templates are read fresh, so edits on disk show up on the next load
+	if err := os.WriteFile(tmpFile, []byte(`{"background": "#000000"}`), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
 	config2, err := svc.LoadTemplate(tmpFile)
 	if err != nil {
-		t.Fatalf("LoadTemplate (cached) failed: %v", err)
+		t.Fatalf("LoadTemplate (reload) failed: %v", err)
 	}
-
-	if config1 != config2 {
-		t.Error("Expected same pointer from cache")
+	if config2.Background != "#000000" {
+		t.Errorf("Expected #000000 after edit, got %s", config2.Background)
 	}
 }
 
@@ -96,12 +100,3 @@ func TestServiceGetOverlays(t *testing.T) {
 		t.Errorf("Expected TEST123, got %s", overlays["barcode"].Text)
 	}
 }
-
-func TestServiceClearCache(t *testing.T) {
-	svc := NewService()
-	svc.ClearCache()
-
-	if len(svc.cache) != 0 {
-		t.Error("Expected empty cache after ClearCache")
-	}
-}
@@ -0,0 +1,72 @@
+// Package template provides per-image field values from sidecar JSON files.
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxSidecarSize bounds the sidecar file read for one image.
+const maxSidecarSize = 1 << 20
+
+// SidecarPaths returns the sidecar files looked for beside an image, in
+// order: "ghe.fields.json", "ghe.jpg.fields.json", "ghe.json", "ghe.jpg.json".
+func SidecarPaths(imagePath string) []string {
+	stem := strings.TrimSuffix(imagePath, filepath.Ext(imagePath))
+	return []string{
+		stem + ".fields.json",
+		imagePath + ".fields.json",
+		stem + ".json",
+		imagePath + ".json",
+	}
+}
+
+// FindSidecar returns the first sidecar file that exists beside an image.
+func FindSidecar(imagePath string) (string, bool) {
+	for _, path := range SidecarPaths(imagePath) {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// LoadSidecar reads a sidecar, a flat JSON object of field values such as
+// {"barcode": "893456", "price": 450}. Numbers and booleans become text;
+// empty strings, null, nested objects and arrays are skipped.
+func LoadSidecar(path string) (map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sidecar: %w", err)
+	}
+	if info.Size() > maxSidecarSize {
+		return nil, fmt.Errorf("sidecar %s too large", filepath.Base(path))
+	}
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sidecar: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid sidecar %s: %w", filepath.Base(path), err)
+	}
+	values := make(map[string]string, len(raw))
+	for field, val := range raw {
+		switch v := val.(type) {
+		case string:
+			if v != "" {
+				values[field] = v
+			}
+		case float64:
+			values[field] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			values[field] = strconv.FormatBool(v)
+		}
+	}
+	return values, nil
+}
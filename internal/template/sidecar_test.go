@@ -0,0 +1,61 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindSidecar(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "ghe.jpg")
+
+	if _, ok := FindSidecar(image); ok {
+		t.Error("Expected no sidecar")
+	}
+
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("ghe.jpg.json", `{}`)
+	if path, ok := FindSidecar(image); !ok || filepath.Base(path) != "ghe.jpg.json" {
+		t.Errorf("Expected ghe.jpg.json, got %q", path)
+	}
+	// .fields.json is more specific and wins
+	write("ghe.fields.json", `{}`)
+	if path, _ := FindSidecar(image); filepath.Base(path) != "ghe.fields.json" {
+		t.Errorf("Expected ghe.fields.json, got %q", path)
+	}
+}
+
+func TestLoadSidecar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ghe.json")
+	content := `{"barcode": "893456", "price": 450, "weight": 1.5, "sale": true, "note": "", "tags": ["a"], "size": null}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write sidecar: %v", err)
+	}
+
+	values, err := LoadSidecar(path)
+	if err != nil {
+		t.Fatalf("LoadSidecar failed: %v", err)
+	}
+	expected := map[string]string{"barcode": "893456", "price": "450", "weight": "1.5", "sale": "true"}
+	if len(values) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	for field, value := range expected {
+		if values[field] != value {
+			t.Errorf("%s: expected %q, got %q", field, value, values[field])
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(`["not", "an", "object"]`), 0644); err != nil {
+		t.Fatalf("Failed to write sidecar: %v", err)
+	}
+	if _, err := LoadSidecar(path); err == nil {
+		t.Error("Expected error for non-object sidecar")
+	}
+}
@@ -0,0 +1,460 @@
+// Package template provides template serialization.
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"vibe-imageborder/internal/models"
+)
+
+// defaultIndent is used when the existing file gives no formatting hint.
+const defaultIndent = "    "
+
+// overlayKeyOrder is the key order used for overlay objects without a file on disk.
+var overlayKeyOrder = []string{"type", "shape", "text", "position", "width", "height", "fontsize", "color", "lightcolor", "darkcolor", "stroke", "strokewidth", "maxwidth", "align", "path", "columns", "rowheight", "border", "zebra", "direction", "gap", "children", "rows"}
+
+// fileLayout holds formatting details sniffed from an existing template file.
+type fileLayout struct {
+	indent    string
+	keys      []string
+	childKeys map[string][]string
+}
+
+// SaveTemplate writes config back to path as JSON.
+// Key order, indentation and unknown keys from Raw are preserved where possible.
+func SaveTemplate(path string, config *models.TemplateConfig) error {
+	if config == nil {
+		return fmt.Errorf("template config is nil")
+	}
+	cleanPath := filepath.Clean(path)
+
+	layout := fileLayout{indent: defaultIndent, childKeys: map[string][]string{}}
+	mode := os.FileMode(0644)
+	if data, err := os.ReadFile(cleanPath); err == nil {
+		layout = sniffLayout(data)
+		if info, err := os.Stat(cleanPath); err == nil {
+			mode = info.Mode().Perm()
+		}
+	}
+
+	data, err := marshalTemplate(config, layout)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a failed write never truncates the template
+	tmp, err := os.CreateTemp(filepath.Dir(cleanPath), ".template-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write template: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write template: %w", err)
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return fmt.Errorf("failed to set template permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, cleanPath); err != nil {
+		return fmt.Errorf("failed to save template: %w", err)
+	}
+	return nil
+}
+
+// marshalTemplate encodes config as indented JSON following layout.
+func marshalTemplate(config *models.TemplateConfig, layout fileLayout) ([]byte, error) {
+	if layout.indent == "" {
+		layout.indent = defaultIndent
+	}
+
+	values := make(map[string]interface{})
+	for key, val := range config.Raw {
+		values[key] = val
+	}
+	delete(values, "background")
+	if config.Background != "" {
+		values["background"] = config.Background
+	}
+	delete(values, "normalize_names")
+	if config.NormalizeNames {
+		values["normalize_names"] = true
+	}
+	delete(values, "filename_pattern")
+	if config.FilenamePattern != "" {
+		values["filename_pattern"] = config.FilenamePattern
+	}
+	for key, overlay := range config.Fields {
+		rawOverlay, _ := config.Raw[key].(map[string]interface{})
+		values[key] = overlayToMap(overlay, rawOverlay)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("{\n")
+	keys := topLevelOrder(config, layout.keys, values)
+	for i, key := range keys {
+		if err := writeMember(&buf, key, values[key], layout.indent, 1, layout.childKeys[key]); err != nil {
+			return nil, err
+		}
+		if i < len(keys)-1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// topLevelOrder decides the order of top-level keys.
+// Overlay slots from the existing file are refilled in FieldOrder sequence.
+func topLevelOrder(config *models.TemplateConfig, fileKeys []string, values map[string]interface{}) []string {
+	order := make([]string, 0, len(values))
+	emitted := make(map[string]bool)
+	emit := func(key string) {
+		if _, ok := values[key]; ok && !emitted[key] {
+			emitted[key] = true
+			order = append(order, key)
+		}
+	}
+
+	nextField := 0
+	nextOverlay := func() {
+		for nextField < len(config.FieldOrder) {
+			key := config.FieldOrder[nextField]
+			nextField++
+			if !emitted[key] {
+				emit(key)
+				return
+			}
+		}
+	}
+
+	for _, key := range fileKeys {
+		if _, isOverlay := config.Fields[key]; isOverlay {
+			nextOverlay()
+			continue
+		}
+		emit(key)
+	}
+
+	emit("background")
+	emit("normalize_names")
+	emit("filename_pattern")
+	for _, key := range config.FieldOrder {
+		emit(key)
+	}
+
+	rest := make([]string, 0)
+	for key := range values {
+		if !emitted[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	for _, key := range rest {
+		emit(key)
+	}
+	return order
+}
+
+// overlayToMap merges overlay values over its raw JSON object, keeping unknown keys.
+func overlayToMap(overlay models.TextOverlay, raw map[string]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(raw)+4)
+	for key, val := range raw {
+		m[key] = val
+	}
+
+	if overlay.Type != "" || m["type"] != nil {
+		m["type"] = overlay.Type
+	}
+	// Shapes and stacks have no font size, and an overlay read without one
+	// holds the parser's default: neither must leak into the file
+	sized := overlay.Type != models.OverlayShape && overlay.Type != models.OverlayStack
+	if _, had := raw["fontsize"]; raw != nil && !had && overlay.FontSize == defaultFontSize {
+		sized = false
+	}
+	plain := overlay.Type == "" || overlay.Type == models.OverlayText
+	if plain || overlay.Text != "" || m["text"] != nil {
+		m["text"] = overlay.Text
+	}
+	if overlay.Position != "" || m["position"] != nil {
+		m["position"] = overlay.Position
+	}
+	if sized || m["fontsize"] != nil {
+		setInt(m, raw, "fontsize", overlay.FontSize)
+	}
+	if overlay.Color != "" || m["color"] != nil {
+		m["color"] = overlay.Color
+	}
+	if overlay.LightColor != "" || m["lightcolor"] != nil {
+		m["lightcolor"] = overlay.LightColor
+	}
+	if overlay.DarkColor != "" || m["darkcolor"] != nil {
+		m["darkcolor"] = overlay.DarkColor
+	}
+	if overlay.Stroke != "" || m["stroke"] != nil {
+		m["stroke"] = overlay.Stroke
+	}
+	setInt(m, raw, "strokewidth", overlay.StrokeWidth)
+	setInt(m, raw, "maxwidth", overlay.MaxWidth)
+	if overlay.Align != "" || m["align"] != nil {
+		m["align"] = overlay.Align
+	}
+	if overlay.Path != "" || m["path"] != nil {
+		m["path"] = overlay.Path
+	}
+	if overlay.Shape != "" || m["shape"] != nil {
+		m["shape"] = overlay.Shape
+	}
+	setInt(m, raw, "width", overlay.Width)
+	setInt(m, raw, "height", overlay.Height)
+	if len(overlay.Rows) > 0 || m["rows"] != nil {
+		m["rows"] = overlay.Rows
+	}
+	setIntList(m, raw, "columns", overlay.Columns)
+	setInt(m, raw, "rowheight", overlay.RowHeight)
+	if overlay.Border != "" || m["border"] != nil {
+		m["border"] = overlay.Border
+	}
+	if overlay.Zebra != "" || m["zebra"] != nil {
+		m["zebra"] = overlay.Zebra
+	}
+	if overlay.Direction != "" || m["direction"] != nil {
+		m["direction"] = overlay.Direction
+	}
+	setInt(m, raw, "gap", overlay.Gap)
+	if len(overlay.Children) > 0 || m["children"] != nil {
+		m["children"] = overlay.Children
+	}
+	return m
+}
+
+// setInt stores a positive integer under key, as a string like the bundled
+// templates unless the file already used a number. Zero removes the key.
+func setInt(m, raw map[string]interface{}, key string, val int) {
+	if val <= 0 {
+		delete(m, key)
+		return
+	}
+	if _, isNumber := raw[key].(float64); isNumber {
+		m[key] = val
+	} else {
+		m[key] = strconv.Itoa(val)
+	}
+}
+
+// setIntList stores integers under key as a "200,300" string, or as an
+// array if the file already used one. An empty list removes the key.
+func setIntList(m, raw map[string]interface{}, key string, vals []int) {
+	if len(vals) == 0 {
+		delete(m, key)
+		return
+	}
+	if _, isArray := raw[key].([]interface{}); isArray {
+		m[key] = vals
+		return
+	}
+	parts := make([]string, len(vals))
+	for i, v := range vals {
+		parts[i] = strconv.Itoa(v)
+	}
+	m[key] = strings.Join(parts, ",")
+}
+
+// writeMember writes `"key": value` at the given depth.
+func writeMember(buf *bytes.Buffer, key string, val interface{}, indent string, depth int, childKeys []string) error {
+	buf.WriteString(strings.Repeat(indent, depth))
+	keyJSON, err := encodeJSON(key)
+	if err != nil {
+		return err
+	}
+	buf.Write(keyJSON)
+	buf.WriteString(": ")
+
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		valJSON, err := encodeIndented(val, strings.Repeat(indent, depth), indent)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", key, err)
+		}
+		buf.Write(valJSON)
+		return nil
+	}
+
+	if len(obj) == 0 {
+		buf.WriteString("{}")
+		return nil
+	}
+
+	buf.WriteString("{\n")
+	keys := objectOrder(obj, childKeys)
+	for i, k := range keys {
+		if err := writeMember(buf, k, obj[k], indent, depth+1, nil); err != nil {
+			return err
+		}
+		if i < len(keys)-1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(strings.Repeat(indent, depth))
+	buf.WriteByte('}')
+	return nil
+}
+
+// objectOrder returns keys of obj: file order first, then known overlay keys, then the rest sorted.
+func objectOrder(obj map[string]interface{}, fileKeys []string) []string {
+	keys := make([]string, 0, len(obj))
+	seen := make(map[string]bool)
+	add := func(k string) {
+		if _, ok := obj[k]; ok && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range fileKeys {
+		add(k)
+	}
+	for _, k := range overlayKeyOrder {
+		add(k)
+	}
+	rest := make([]string, 0)
+	for k := range obj {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	for _, k := range rest {
+		add(k)
+	}
+	return keys
+}
+
+// encodeJSON marshals v without HTML escaping so text stays readable.
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// encodeIndented marshals nested values (arrays) using the file indentation.
+// Table rows are written one compact row per line, stack children on one line.
+func encodeIndented(v interface{}, prefix, indent string) ([]byte, error) {
+	if keys, ok := v.([]string); ok {
+		return encodeJSON(keys)
+	}
+
+	var buf bytes.Buffer
+	if rows, ok := v.([][]string); ok && len(rows) > 0 {
+		buf.WriteString("[\n")
+		for i, row := range rows {
+			data, err := encodeJSON(row)
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString(prefix + indent)
+			buf.Write(data)
+			if i < len(rows)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(prefix + "]")
+		return buf.Bytes(), nil
+	}
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(prefix, indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// sniffLayout reads indentation and key order from existing template JSON.
+func sniffLayout(data []byte) fileLayout {
+	layout := fileLayout{indent: detectIndent(data), childKeys: map[string][]string{}}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return layout
+	}
+	for decoder.More() {
+		keyToken, err := decoder.Token()
+		if err != nil {
+			return layout
+		}
+		key, _ := keyToken.(string)
+		var val json.RawMessage
+		if err := decoder.Decode(&val); err != nil {
+			return layout
+		}
+		layout.keys = append(layout.keys, key)
+		if keys := objectKeys(val); keys != nil {
+			layout.childKeys[key] = keys
+		}
+	}
+	return layout
+}
+
+// objectKeys returns the key order of a JSON object, or nil if data is not an object.
+func objectKeys(data json.RawMessage) []string {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil
+	}
+	keys := []string{}
+	for decoder.More() {
+		keyToken, err := decoder.Token()
+		if err != nil {
+			return keys
+		}
+		key, _ := keyToken.(string)
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return keys
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// detectIndent returns the shortest leading whitespace of an indented member line.
+// Hand-edited templates mixing flush-left and indented keys still yield one level.
+func detectIndent(data []byte) string {
+	indent := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if !strings.HasPrefix(trimmed, `"`) {
+			continue
+		}
+		lead := line[:len(line)-len(trimmed)]
+		if lead == "" {
+			continue
+		}
+		if indent == "" || len(lead) < len(indent) {
+			indent = lead
+		}
+	}
+	if indent == "" {
+		return defaultIndent
+	}
+	return indent
+}
@@ -0,0 +1,244 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vibe-imageborder/internal/models"
+)
+
+func TestSaveTemplateRoundTrip(t *testing.T) {
+	content := `{
+"background": "#f1eeea",
+    "barcode": {
+        "text": "[barcode]",
+        "position": "90,1852",
+        "fontsize": "50",
+        "color": "white",
+        "note": "keep me"
+    },
+"price": {
+        "text": "[price]K",
+        "position": "110,1712",
+        "fontsize": "50",
+        "color": "white"
+    },
+    "version": 2
+}`
+
+	tmpDir := t.TempDir()
+	tmpFile := filepath.Join(tmpDir, "test.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	config, err := ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	overlay := config.Fields["price"]
+	overlay.Position = "120,1700"
+	config.Fields["price"] = overlay
+
+	if err := SaveTemplate(tmpFile, config); err != nil {
+		t.Fatalf("SaveTemplate failed: %v", err)
+	}
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to read saved file: %v", err)
+	}
+	saved := string(data)
+
+	expected := `{
+    "background": "#f1eeea",
+    "barcode": {
+        "text": "[barcode]",
+        "position": "90,1852",
+        "fontsize": "50",
+        "color": "white",
+        "note": "keep me"
+    },
+    "price": {
+        "text": "[price]K",
+        "position": "120,1700",
+        "fontsize": "50",
+        "color": "white"
+    },
+    "version": 2
+}
+`
+	if saved != expected {
+		t.Errorf("Unexpected output:\n%s", saved)
+	}
+
+	reloaded, err := ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate after save failed: %v", err)
+	}
+	if reloaded.Fields["price"].Position != "120,1700" {
+		t.Errorf("Expected position 120,1700, got %s", reloaded.Fields["price"].Position)
+	}
+	if len(reloaded.FieldOrder) != 2 || reloaded.FieldOrder[0] != "barcode" {
+		t.Errorf("Field order not preserved: %v", reloaded.FieldOrder)
+	}
+}
+
+func TestSaveTemplateWithoutFontSize(t *testing.T) {
+	content := `{
+    "price": {
+        "text": "[price]K",
+        "position": "110,1712"
+    }
+}`
+
+	tmpFile := filepath.Join(t.TempDir(), "nosize.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	svc := NewService()
+	err := svc.UpdateOverlay(tmpFile, "price", func(o *models.TextOverlay) {
+		o.Position = "120,1700"
+	})
+	if err != nil {
+		t.Fatalf("UpdateOverlay failed: %v", err)
+	}
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to read saved file: %v", err)
+	}
+	expected := strings.Replace(content, "110,1712", "120,1700", 1) + "\n"
+	if string(data) != expected {
+		t.Errorf("Expected only the position to change:\n%s", data)
+	}
+
+	// An edited font size is written
+	err = svc.UpdateOverlay(tmpFile, "price", func(o *models.TextOverlay) {
+		o.FontSize = 60
+	})
+	if err != nil {
+		t.Fatalf("UpdateOverlay failed: %v", err)
+	}
+	data, err = os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to read saved file: %v", err)
+	}
+	if !strings.Contains(string(data), `"fontsize": "60"`) {
+		t.Errorf("Expected the edited fontsize:\n%s", data)
+	}
+}
+
+func TestSaveTemplateNewFile(t *testing.T) {
+	config := &models.TemplateConfig{
+		Background: "#ffffff",
+		Fields: map[string]models.TextOverlay{
+			"b": {Text: "<b>[b]</b>", Position: "1,2", FontSize: 10, Color: "black"},
+			"a": {Text: "[a]", Position: "3,4", FontSize: 12, Color: "red"},
+			"c": {Type: models.OverlayShape, Position: "0,0", FontSize: 24, Color: "black", Width: 10, Height: 5},
+			"d": {Type: models.OverlayTable, Position: "0,0", FontSize: 20, Columns: []int{100, 200}, Rows: [][]string{{"Dài", "[dai]"}, {"Cao", "[cao]"}}},
+			"e": {Type: models.OverlayStack, Position: "5,5", FontSize: 24, Gap: 8, Children: []string{"a", "b"}},
+		},
+		FieldOrder: []string{"b", "a", "c", "d", "e"},
+	}
+
+	tmpFile := filepath.Join(t.TempDir(), "new.txt")
+	if err := SaveTemplate(tmpFile, config); err != nil {
+		t.Fatalf("SaveTemplate failed: %v", err)
+	}
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to read saved file: %v", err)
+	}
+	saved := string(data)
+
+	if strings.Index(saved, `"b"`) > strings.Index(saved, `"a"`) {
+		t.Errorf("Expected FieldOrder to be kept:\n%s", saved)
+	}
+	if !strings.Contains(saved, `"<b>[b]</b>"`) {
+		t.Errorf("Expected unescaped markup:\n%s", saved)
+	}
+	if !strings.Contains(saved, `"fontsize": "12"`) {
+		t.Errorf("Expected string fontsize:\n%s", saved)
+	}
+	if !strings.Contains(saved, `"type": "shape",
+        "position": "0,0",
+        "width": "10",
+        "height": "5",
+        "color": "black"
+    }`) {
+		t.Errorf("Expected shape without text or fontsize:\n%s", saved)
+	}
+	if !strings.Contains(saved, `"columns": "100,200",
+        "rows": [
+            ["Dài","[dai]"],
+            ["Cao","[cao]"]
+        ]`) {
+		t.Errorf("Expected table columns and one row per line:\n%s", saved)
+	}
+
+	if !strings.Contains(saved, `"type": "stack",
+        "position": "5,5",
+        "gap": "8",
+        "children": ["a","b"]
+    }`) {
+		t.Errorf("Expected stack without fontsize and children on one line:\n%s", saved)
+	}
+
+	config, err = ParseTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("ParseTemplate of saved file failed: %v", err)
+	}
+	if rows := config.Fields["d"].Rows; len(rows) != 2 || rows[1][1] != "[cao]" {
+		t.Errorf("Expected table rows to round-trip, got %v", rows)
+	}
+}
+
+func TestServiceUpdateOverlay(t *testing.T) {
+	content := `{
+  "barcode": {
+    "text": "[barcode]",
+    "position": "90,1852",
+    "fontsize": 50,
+    "color": "white"
+  }
+}`
+
+	tmpFile := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	svc := NewService()
+	err := svc.UpdateOverlay(tmpFile, "barcode", func(o *models.TextOverlay) {
+		o.Color = "#ff0000"
+	})
+	if err != nil {
+		t.Fatalf("UpdateOverlay failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(tmpFile)
+	if !strings.Contains(string(data), "\n  \"barcode\": {\n    \"text\"") {
+		t.Errorf("Expected two-space indentation kept:\n%s", data)
+	}
+	if !strings.Contains(string(data), `"fontsize": 50`) {
+		t.Errorf("Expected numeric fontsize kept:\n%s", data)
+	}
+
+	config, err := svc.LoadTemplate(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadTemplate failed: %v", err)
+	}
+	if config.Fields["barcode"].Color != "#ff0000" {
+		t.Errorf("Expected #ff0000, got %s", config.Fields["barcode"].Color)
+	}
+
+	if err := svc.UpdateOverlay(tmpFile, "missing", func(o *models.TextOverlay) {}); err == nil {
+		t.Error("Expected error for unknown overlay")
+	}
+}
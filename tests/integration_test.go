@@ -1,14 +1,17 @@
 package tests
 
 import (
+	"bytes"
 	"image"
 	"image/color"
 	"image/png"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	imgservice "vibe-imageborder/internal/image"
+	"vibe-imageborder/internal/models"
 	"vibe-imageborder/internal/template"
 )
 
@@ -168,6 +171,7 @@ func TestIntegration_OutputFormats(t *testing.T) {
 		{"png", 0},
 		{"jpg", 90},
 		{"jpg", 50},
+		{"webp", 80},
 	}
 
 	for _, f := range formats {
@@ -178,3 +182,49 @@ func TestIntegration_OutputFormats(t *testing.T) {
 		}
 	}
 }
+
+// Batch workers share one compositor and text renderer, so rendering in
+// parallel must give the same pixels as rendering one image at a time.
+func TestIntegration_ParallelComposite(t *testing.T) {
+	imageSvc := imgservice.NewService()
+	compositor := imgservice.NewCompositor(imageSvc)
+	renderer := imgservice.NewTextRenderer(imgservice.NewFontManager(os.DirFS("..")))
+
+	frame := image.NewRGBA(image.Rect(0, 0, 300, 300))
+	overlays := map[string]models.TextOverlay{
+		"name":  {Text: "Ghế gỗ sồi", Position: "20,40", FontSize: 28, Color: "#ffffff"},
+		"price": {Text: "450.000đ", Position: "20,250", FontSize: 32, Color: "auto"},
+	}
+	render := func(i int) []byte {
+		product := createTestImage(200+i*10, 200, color.RGBA{uint8(i * 30), 120, 80, 255})
+		result, err := compositor.CompositeWithText(product, frame, "#f1eeea", overlays, renderer)
+		if err != nil {
+			t.Errorf("Composite %d failed: %v", i, err)
+			return nil
+		}
+		return result.Image.(*image.RGBA).Pix
+	}
+
+	const n = 8
+	expected := make([][]byte, n)
+	for i := range expected {
+		expected[i] = render(i)
+	}
+
+	got := make([][]byte, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i] = render(i)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range got {
+		if !bytes.Equal(got[i], expected[i]) {
+			t.Errorf("Image %d differs when rendered in parallel", i)
+		}
+	}
+}
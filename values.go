@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"vibe-imageborder/internal/datasource"
+	"vibe-imageborder/internal/models"
+	"vibe-imageborder/internal/template"
+)
+
+// defaultPrecedence orders the field value sources, lowest priority first.
+var defaultPrecedence = []string{
+	models.ValuesFields,
+	models.ValuesFilename,
+	models.ValuesSidecar,
+	models.ValuesDataSource,
+}
+
+// imageValues holds the field value sources of a batch: the request's
+// shared FieldValues, the values that differ per product image and the
+// values of one output variant, which override them all.
+type imageValues struct {
+	shared  map[string]string
+	variant map[string]string            // the variant's own FieldValues, nil for the plain output
+	order   []string                     // value sources, lowest priority first
+	pattern *regexp.Regexp               // file name pattern with named groups
+	rows    map[string]map[string]string // data source values by image path
+}
+
+// imageLayers is what each source yields for one image.
+type imageLayers struct {
+	values  map[string]map[string]string // source -> values
+	sidecar string                       // sidecar path, empty if none
+}
+
+// loadDataSource reads req's data source and matches its rows to images.
+// It returns nil without a data source.
+func loadDataSource(req models.ProcessRequest, images []string) (*datasource.Result, error) {
+	if req.DataSource == nil {
+		return nil, nil
+	}
+	rows, err := datasource.Load(*req.DataSource, images)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data source: %w", err)
+	}
+	return rows, nil
+}
+
+// loadImageValues prepares the value sources of req with rows from
+// loadDataSource, which may be nil. The request's file name pattern wins
+// over the template's; config may be nil.
+func loadImageValues(req models.ProcessRequest, config *models.TemplateConfig, rows *datasource.Result) (*imageValues, error) {
+	order, err := precedence(req.Precedence)
+	if err != nil {
+		return nil, err
+	}
+	values := &imageValues{shared: req.FieldValues, order: order}
+
+	pattern := req.FilenamePattern
+	if pattern == "" && config != nil {
+		pattern = config.FilenamePattern
+	}
+	if pattern != "" {
+		re, err := template.CompileFilenamePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		values.pattern = re
+	}
+
+	if rows != nil {
+		values.rows = rows.Values
+	}
+	return values, nil
+}
+
+// precedence returns the full source order for a request's Precedence.
+// Sources it leaves out keep their default order below the listed ones.
+func precedence(listed []string) ([]string, error) {
+	known := make(map[string]bool, len(defaultPrecedence))
+	for _, source := range defaultPrecedence {
+		known[source] = true
+	}
+	seen := make(map[string]bool, len(listed))
+	for _, source := range listed {
+		if !known[source] {
+			return nil, fmt.Errorf("unknown value source in precedence: %s", source)
+		}
+		if seen[source] {
+			return nil, fmt.Errorf("value source %s listed twice in precedence", source)
+		}
+		seen[source] = true
+	}
+
+	order := make([]string, 0, len(defaultPrecedence))
+	for _, source := range defaultPrecedence {
+		if !seen[source] {
+			order = append(order, source)
+		}
+	}
+	return append(order, listed...), nil
+}
+
+// layers collects each source's values for one image.
+func (v *imageValues) layers(path string) (*imageLayers, error) {
+	layers := &imageLayers{values: map[string]map[string]string{
+		models.ValuesFields: v.shared,
+	}}
+	if v.pattern != nil {
+		layers.values[models.ValuesFilename] = template.FilenameValues(v.pattern, path)
+	}
+	if sidecar, ok := template.FindSidecar(path); ok {
+		values, err := template.LoadSidecar(sidecar)
+		if err != nil {
+			return nil, err
+		}
+		layers.values[models.ValuesSidecar] = values
+		layers.sidecar = sidecar
+	}
+	if row, ok := v.rows[path]; ok {
+		layers.values[models.ValuesDataSource] = row
+	}
+	return layers, nil
+}
+
+// forImage returns the merged field values of one image and whether the
+// image has values of its own, so the shared overlays cannot be reused.
+// Sources merge in precedence order and the variant's values go on top, so
+// a flash-sale price is kept over the row's regular one. An empty value,
+// such as a field left blank in the form, never hides a value from a lower
+// source.
+func (v *imageValues) forImage(path string) (map[string]string, bool, error) {
+	layers, err := v.layers(path)
+	if err != nil {
+		return nil, false, err
+	}
+	own := false
+	ordered := make([]map[string]string, 0, len(v.order)+1)
+	for _, source := range v.order {
+		values := layers.values[source]
+		if source != models.ValuesFields && len(values) > 0 {
+			own = true
+		}
+		ordered = append(ordered, values)
+	}
+	return template.LayerValues(append(ordered, v.variant)...), own, nil
+}
+
+// sidecarReport describes the sidecar of one image and which of its values
+// win over the other sources.
+func (v *imageValues) sidecarReport(path string) (models.SidecarValues, error) {
+	report := models.SidecarValues{
+		File:    filepath.Base(path),
+		Values:  map[string]string{},
+		Applied: map[string]string{},
+	}
+	layers, err := v.layers(path)
+	if err != nil {
+		return report, err
+	}
+	if layers.sidecar == "" {
+		return report, nil
+	}
+	report.Sidecar = filepath.Base(layers.sidecar)
+	report.Values = layers.values[models.ValuesSidecar]
+
+	// Sources after the sidecar in the order, and the variant, override it
+	above := false
+	overridden := make(map[string]bool)
+	for _, source := range v.order {
+		if above {
+			for field, value := range layers.values[source] {
+				if value != "" {
+					overridden[field] = true
+				}
+			}
+		}
+		above = above || source == models.ValuesSidecar
+	}
+	for field, value := range v.variant {
+		if value != "" {
+			overridden[field] = true
+		}
+	}
+	for field, value := range report.Values {
+		if !overridden[field] {
+			report.Applied[field] = value
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"vibe-imageborder/internal/datasource"
+	imgservice "vibe-imageborder/internal/image"
+	"vibe-imageborder/internal/models"
+	"vibe-imageborder/internal/template"
+)
+
+// batchVariant is one output of every product in a batch: a template filled
+// with the shared values, plus the per-image value sources.
+type batchVariant struct {
+	name     string                        // empty for the plain output
+	config   *models.TemplateConfig        // nil without a template
+	bgColor  string                        // template background
+	overlays map[string]models.TextOverlay // filled with the shared values
+	sources  *imageValues
+}
+
+// variantRequests returns one request per output of req: req itself when it
+// has no variants, else a copy per variant with the variant's FieldValues
+// over the shared ones and its template, if set. The variants are returned
+// alongside with safe names; the plain output has an empty one.
+func variantRequests(req models.ProcessRequest) ([]models.ProcessRequest, []models.Variant, error) {
+	if len(req.Variants) == 0 {
+		return []models.ProcessRequest{req}, []models.Variant{{}}, nil
+	}
+
+	reqs := make([]models.ProcessRequest, 0, len(req.Variants))
+	variants := make([]models.Variant, 0, len(req.Variants))
+	seen := make(map[string]bool, len(req.Variants))
+	for _, variant := range req.Variants {
+		name := safeFileName(variant.Name)
+		if name == "" {
+			return nil, nil, fmt.Errorf("variant name required")
+		}
+		if seen[strings.ToLower(name)] {
+			return nil, nil, fmt.Errorf("duplicate variant name: %s", variant.Name)
+		}
+		seen[strings.ToLower(name)] = true
+
+		vreq := req
+		vreq.Variants = nil
+		vreq.FieldValues = template.MergeValues(req.FieldValues, variant.FieldValues)
+		if variant.TemplatePath != "" {
+			vreq.TemplatePath = variant.TemplatePath
+		}
+		reqs = append(reqs, vreq)
+		variant.Name = name
+		variants = append(variants, variant)
+	}
+	return reqs, variants, nil
+}
+
+// safeFileName makes a variant or frame name safe to use in a file name.
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?* `, r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(name))
+}
+
+// prepareVariant loads the template of one output and its value sources,
+// with the data source rows already loaded for the batch. The output's own
+// variant values win over every per-image source.
+func (a *App) prepareVariant(req models.ProcessRequest, output models.Variant, rows *datasource.Result) (*batchVariant, error) {
+	variant := &batchVariant{name: output.Name}
+	if req.TemplatePath != "" {
+		config, err := a.templateSvc.LoadTemplate(req.TemplatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load template: %w", err)
+		}
+		variant.config, variant.bgColor = config, config.Background
+
+		variant.overlays, err = a.templateSvc.ApplyValues(config, req.FieldValues)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get template overlays: %w", err)
+		}
+		if err := imgservice.ValidateColors(variant.bgColor, variant.overlays); err != nil {
+			return nil, fmt.Errorf("invalid template colors: %w", err)
+		}
+	}
+
+	sources, err := loadImageValues(req, variant.config, rows)
+	if err != nil {
+		return nil, err
+	}
+	sources.variant = output.FieldValues
+	variant.sources = sources
+	return variant, nil
+}
+
+// prepareVariants prepares every output of req, sharing rows from
+// loadDataSource between them.
+func (a *App) prepareVariants(req models.ProcessRequest, rows *datasource.Result) ([]*batchVariant, error) {
+	reqs, outputs, err := variantRequests(req)
+	if err != nil {
+		return nil, err
+	}
+	variants := make([]*batchVariant, 0, len(reqs))
+	for i, vreq := range reqs {
+		variant, err := a.prepareVariant(vreq, outputs[i], rows)
+		if err != nil {
+			if outputs[i].Name != "" {
+				err = fmt.Errorf("variant %s: %w", outputs[i].Name, err)
+			}
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+	return variants, nil
+}
+
+// imageOverlays returns the variant's overlays for one image, refilled when
+// the image has values of its own, along with the image's field values.
+func (a *App) imageOverlays(variant *batchVariant, productPath string) (map[string]models.TextOverlay, map[string]string, error) {
+	values, own, err := variant.sources.forImage(productPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !own || variant.config == nil {
+		return variant.overlays, values, nil
+	}
+	overlays, err := a.templateSvc.ApplyValues(variant.config, values)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := imgservice.ValidateColors(variant.bgColor, overlays); err != nil {
+		return nil, nil, fmt.Errorf("invalid colors in image values: %w", err)
+	}
+	return overlays, values, nil
+}
+
+// outputLabel names one output in progress, warnings and failures, such as
+// "ghe.jpg [sale, portrait]". Empty variant and frame names are left out.
+func outputLabel(file string, names ...string) string {
+	var parts []string
+	for _, name := range names {
+		if name != "" {
+			parts = append(parts, name)
+		}
+	}
+	if len(parts) == 0 {
+		return file
+	}
+	return file + " [" + strings.Join(parts, ", ") + "]"
+}